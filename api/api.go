@@ -0,0 +1,325 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pellux-network/EDxDC/conf"
+	"github.com/pellux-network/EDxDC/edreader"
+	"github.com/pellux-network/EDxDC/mfd"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+ Module serving the game state and the rendered MFD pages over HTTP and WebSocket, for overlays and
+ companion apps. The virtual MFD is served at the root.
+*/
+
+const (
+	// DefaultListen is the address the server listens on if none is configured
+	DefaultListen = "127.0.0.1:8765"
+	// clientBuffer is the number of updates queued for a websocket client before it is dropped
+	clientBuffer = 16
+	writeTimeout = 5 * time.Second
+)
+
+// Update is the message pushed to websocket clients whenever the MFD content changes
+type Update struct {
+	Display mfd.Display   `json:"display"`
+	State   edreader.View `json:"state"`
+}
+
+// Server is the embedded HTTP server
+type Server struct {
+	http   *http.Server
+	mux    *http.ServeMux
+	reader *edreader.Reader
+	// allowedOrigins are the origins of pages on other hosts that may use the API
+	allowedOrigins []string
+	// allowedHosts are the host names, besides localhost, requests may be sent to
+	allowedHosts []string
+	upgrader     websocket.Upgrader
+
+	clientsLock sync.Mutex
+	clients     map[chan Update]struct{}
+}
+
+// New creates a server for the reader, listening on the configured address once started
+func New(cfg conf.APIConf, reader *edreader.Reader) *Server {
+	addr := cfg.Listen
+	if addr == "" {
+		addr = DefaultListen
+	}
+	s := &Server{
+		mux:            http.NewServeMux(),
+		reader:         reader,
+		allowedOrigins: cfg.AllowedOrigins,
+		allowedHosts:   slices.Clone(cfg.AllowedHosts),
+		clients:        map[chan Update]struct{}{},
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil && host != "" {
+		s.allowedHosts = append(s.allowedHosts, host)
+	}
+	s.upgrader.CheckOrigin = s.allowOrigin
+	s.mux.HandleFunc("GET /api/state", s.api(s.handleState))
+	s.mux.HandleFunc("GET /api/journal", s.api(jsonHandler(func() any { return s.reader.CurrentView().Journal })))
+	s.mux.HandleFunc("GET /api/cargo", s.api(jsonHandler(func() any { return s.reader.CurrentView().Cargo })))
+	s.mux.HandleFunc("GET /api/modules", s.api(jsonHandler(func() any { return s.reader.CurrentView().Modules })))
+	s.mux.HandleFunc("GET /api/status", s.api(jsonHandler(func() any { return s.reader.CurrentView().Status })))
+	s.mux.HandleFunc("GET /api/metrics", s.api(jsonHandler(func() any { return s.reader.Metrics() })))
+	s.mux.HandleFunc("GET /api/display", s.api(s.handleDisplay))
+	s.mux.HandleFunc("GET /api/pages", s.api(s.handlePages))
+	s.mux.HandleFunc("POST /api/select", s.api(s.handleSelect))
+	s.mux.HandleFunc("GET /api/ws", s.api(s.handleWebSocket))
+	s.mux.Handle("GET /", mfd.WebUI())
+	s.http = &http.Server{Addr: addr, Handler: s.checkHost(s.mux)}
+	return s
+}
+
+// Handle registers an additional handler on the server. Must be called before Start.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start starts listening and pushing updates to websocket clients
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return err
+	}
+	s.reader.AddDisplayListener(s.broadcast)
+	log.Info().Str("addr", ln.Addr().String()).Msg("API server listening")
+	go func() {
+		if err := s.http.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("API server failed")
+		}
+	}()
+	return nil
+}
+
+// Close stops the server and disconnects all clients
+func (s *Server) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	_ = s.http.Shutdown(ctx)
+
+	s.clientsLock.Lock()
+	defer s.clientsLock.Unlock()
+	for client := range s.clients {
+		close(client)
+		delete(s.clients, client)
+	}
+}
+
+// allowOrigin tells if the page a request comes from may use the API. Pages served by the server
+// itself or from localhost may, and those of the configured origins. Requests without an origin are
+// not made by pages of other sites.
+func (s *Server) allowOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if slices.ContainsFunc(s.allowedOrigins, func(allowed string) bool {
+		return strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin)
+	}) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	// The host was checked by checkHost, so a page of another site can not pose as one of the server
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	switch strings.ToLower(u.Hostname()) {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
+
+// allowHost tells if a request was sent to this server by a name it may be reached by. Other sites can
+// point their host name at this machine (DNS rebinding), which would make their pages the same origin
+// as the server. IP addresses can not be rebound, so those are always allowed.
+func (s *Server) allowHost(r *http.Request) bool {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.Trim(host, "[]"), ".")
+	if net.ParseIP(host) != nil || strings.EqualFold(host, "localhost") {
+		return true
+	}
+	return slices.ContainsFunc(s.allowedHosts, func(allowed string) bool {
+		return strings.EqualFold(allowed, host)
+	})
+}
+
+// checkHost refuses requests sent to host names the server may not be reached by
+func (s *Server) checkHost(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.allowHost(r) {
+			http.Error(w, "host not allowed", http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// api wraps an API handler, refusing requests from the pages of origins not allowed and letting the
+// pages of the allowed ones read the response
+func (s *Server) api(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.allowOrigin(r) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+		}
+		handler(w, r)
+	}
+}
+
+// deviceCharset reports whether the client asked for text as the device shows it, using ?charset=device
+func deviceCharset(r *http.Request) bool {
+	return r.URL.Query().Get("charset") == "device"
+}
+
+func (s *Server) currentDisplay(r *http.Request) mfd.Display {
+	display := s.reader.CurrentDisplay()
+	if deviceCharset(r) {
+		display = display.Translated()
+	}
+	return display
+}
+
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, Update{Display: s.currentDisplay(r), State: s.reader.CurrentView()})
+}
+
+func (s *Server) handleDisplay(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.currentDisplay(r))
+}
+
+func (s *Server) handlePages(w http.ResponseWriter, r *http.Request) {
+	previews := s.reader.PreviewPages()
+	if deviceCharset(r) {
+		for i := range previews {
+			previews[i].Page = previews[i].Page.Translated()
+		}
+	}
+	writeJSON(w, previews)
+}
+
+// handleSelect presses the soft button on the page given by ?page=. Like the other API handlers it is
+// wrapped by api, so that sites open in the browser cannot change pages.
+func (s *Server) handleSelect(w http.ResponseWriter, r *http.Request) {
+	page, err := strconv.ParseUint(r.URL.Query().Get("page"), 10, 32)
+	if err != nil {
+		http.Error(w, "invalid page", http.StatusBadRequest)
+		return
+	}
+	s.reader.SelectPage(uint32(page))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func jsonHandler(get func() any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, get())
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warn().Err(err).Msg("Failed to write API response")
+	}
+}
+
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Debug().Err(err).Msg("Websocket upgrade failed")
+		return
+	}
+	defer conn.Close()
+	log.Debug().Str("remote", r.RemoteAddr).Msg("Websocket client connected")
+
+	device := deviceCharset(r)
+	updates := make(chan Update, clientBuffer)
+	updates <- Update{Display: s.reader.CurrentDisplay(), State: s.reader.CurrentView()}
+	s.clientsLock.Lock()
+	s.clients[updates] = struct{}{}
+	s.clientsLock.Unlock()
+	defer s.removeClient(updates)
+
+	// Reading is required to notice the client going away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+				return
+			}
+			if device {
+				update.Display = update.Display.Translated()
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := conn.WriteJSON(update); err != nil {
+				log.Debug().Err(err).Msg("Websocket write failed")
+				return
+			}
+		case <-closed:
+			log.Debug().Str("remote", r.RemoteAddr).Msg("Websocket client disconnected")
+			return
+		}
+	}
+}
+
+func (s *Server) removeClient(updates chan Update) {
+	s.clientsLock.Lock()
+	defer s.clientsLock.Unlock()
+	if _, ok := s.clients[updates]; ok {
+		delete(s.clients, updates)
+		close(updates)
+	}
+}
+
+// broadcast queues an update for all websocket clients. Clients that fall too far behind are disconnected.
+func (s *Server) broadcast(display mfd.Display, view edreader.View) {
+	update := Update{Display: display, State: view}
+	s.clientsLock.Lock()
+	defer s.clientsLock.Unlock()
+	for client := range s.clients {
+		select {
+		case client <- update:
+		default:
+			log.Warn().Msg("Websocket client too slow, disconnecting")
+			delete(s.clients, client)
+			close(client)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pellux-network/EDxDC/conf"
+	"github.com/pellux-network/EDxDC/edreader"
+	"github.com/rs/zerolog"
+)
+
+const testJournal = "Journal.2025-01-01T100000.01.log"
+
+// startServer serves the API of a reader of a journal folder with a game session going on, once the
+// reader read it
+func startServer(t *testing.T, cfg conf.APIConf) (*httptest.Server, string) {
+	t.Helper()
+	return startServerWith(t, conf.Conf{Pages: map[string]bool{"location": true}}, cfg)
+}
+
+// startServerWith is startServer with the pages and commanders of the reader config
+func startServerWith(t *testing.T, readerCfg conf.Conf, cfg conf.APIConf) (*httptest.Server, string) {
+	t.Helper()
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() {
+		zerolog.SetGlobalLevel(zerolog.TraceLevel)
+		log.SetOutput(os.Stderr)
+	})
+	// The commodity names are read from the names folder
+	t.Chdir("..")
+
+	dir := t.TempDir()
+	journal := `{ "timestamp":"2025-01-01T10:00:00Z", "event":"Commander", "FID":"F1", "Name":"Jameson" }
+{ "timestamp":"2025-01-01T10:00:01Z", "event":"Location", "Docked":true, "StationName":"Abraham Lincoln", "StationType":"Orbis", "StarSystem":"Sol", "SystemAddress":10477373803, "Body":"Earth", "BodyID":3, "BodyType":"Planet" }
+`
+	if err := os.WriteFile(filepath.Join(dir, testJournal), []byte(journal), 0644); err != nil {
+		t.Fatal(err)
+	}
+	readerCfg.JournalsFolder = dir
+	readerCfg.Galaxy = conf.GalaxyConf{Providers: []string{"journal"}}
+	reader := edreader.New(readerCfg, edreader.Options{})
+	t.Cleanup(func() { reader.Close() })
+	s := New(cfg, reader)
+	reader.AddDisplayListener(s.broadcast)
+	go reader.Run(context.Background())
+
+	ts := httptest.NewServer(s.http.Handler)
+	t.Cleanup(func() {
+		ts.Close()
+		s.Close()
+	})
+	deadline := time.Now().Add(5 * time.Second)
+	for reader.CurrentView().Journal.StarSystem != "Sol" {
+		if time.Now().After(deadline) {
+			t.Fatal("the reader did not read the journal")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return ts, dir
+}
+
+// request makes a request of the path with the origin, if set
+func request(t *testing.T, ts *httptest.Server, method, path, origin string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestState(t *testing.T) {
+	ts, _ := startServer(t, conf.APIConf{})
+	resp := request(t, ts, http.MethodGet, "/api/state", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d", resp.StatusCode)
+	}
+	var update Update
+	if err := json.NewDecoder(resp.Body).Decode(&update); err != nil {
+		t.Fatal(err)
+	}
+	if update.State.Journal.Commander != "Jameson" || update.State.Journal.StarSystem != "Sol" {
+		t.Errorf("got commander %q in %q, wanted Jameson in Sol", update.State.Journal.Commander, update.State.Journal.StarSystem)
+	}
+	if len(update.Display.Pages) != 1 {
+		t.Errorf("got %d pages, wanted the location page", len(update.Display.Pages))
+	}
+}
+
+func TestOrigin(t *testing.T) {
+	ts, _ := startServer(t, conf.APIConf{AllowedOrigins: []string{"https://overlay.example.com/"}})
+	tests := []struct {
+		method, path, origin string
+		status               int
+		allowOrigin          string
+	}{
+		{http.MethodGet, "/api/state", "", http.StatusOK, ""},
+		{http.MethodGet, "/api/state", ts.URL, http.StatusOK, ts.URL},
+		{http.MethodGet, "/api/state", "http://localhost:3000", http.StatusOK, "http://localhost:3000"},
+		{http.MethodGet, "/api/state", "https://overlay.example.com", http.StatusOK, "https://overlay.example.com"},
+		{http.MethodGet, "/api/state", "https://evil.example.com", http.StatusForbidden, ""},
+		{http.MethodGet, "/api/state", "null", http.StatusForbidden, ""},
+		{http.MethodPost, "/api/select?page=0", ts.URL, http.StatusNoContent, ts.URL},
+		{http.MethodPost, "/api/select?page=0", "https://evil.example.com", http.StatusForbidden, ""},
+	}
+	for _, test := range tests {
+		resp := request(t, ts, test.method, test.path, test.origin)
+		if resp.StatusCode != test.status {
+			t.Errorf("%s %s from %q: got status %d, wanted %d", test.method, test.path, test.origin, resp.StatusCode, test.status)
+		}
+		if got := resp.Header.Get("Access-Control-Allow-Origin"); got != test.allowOrigin {
+			t.Errorf("%s %s from %q: got allowed origin %q, wanted %q", test.method, test.path, test.origin, got, test.allowOrigin)
+		}
+	}
+}
+
+func TestHost(t *testing.T) {
+	ts, _ := startServer(t, conf.APIConf{Listen: "edxdc.local:8765", AllowedHosts: []string{"Gaming-PC"}})
+	tests := []struct {
+		host, origin string
+		status       int
+	}{
+		{"127.0.0.1:8765", "", http.StatusOK},
+		{"[::1]:8765", "", http.StatusOK},
+		{"192.168.1.10:8765", "", http.StatusOK},
+		{"localhost:8765", "http://localhost:8765", http.StatusOK},
+		{"edxdc.local:8765", "", http.StatusOK},
+		{"gaming-pc:8765", "http://gaming-pc:8765", http.StatusOK},
+		{"gaming-pc.:8765", "", http.StatusOK},
+		// A site pointing its name at this machine can not use the API, with or without an origin
+		{"evil.example.com:8765", "", http.StatusForbidden},
+		{"evil.example.com:8765", "http://evil.example.com:8765", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/state", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Host = test.host
+		if test.origin != "" {
+			req.Header.Set("Origin", test.origin)
+		}
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("host %q from %q: got status %d, wanted %d", test.host, test.origin, resp.StatusCode, test.status)
+		}
+	}
+}
+
+// previewPages returns the pages the web UI previews
+func previewPages(t *testing.T, ts *httptest.Server) map[edreader.PageKey]edreader.PagePreview {
+	t.Helper()
+	resp := request(t, ts, http.MethodGet, "/api/pages?charset=device", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d", resp.StatusCode)
+	}
+	var previews []edreader.PagePreview
+	if err := json.NewDecoder(resp.Body).Decode(&previews); err != nil {
+		t.Fatal(err)
+	}
+	pages := map[edreader.PageKey]edreader.PagePreview{}
+	for _, preview := range previews {
+		pages[preview.Key] = preview
+	}
+	return pages
+}
+
+func TestPages(t *testing.T) {
+	ts, _ := startServerWith(t, conf.Conf{
+		Pages:      map[string]bool{"location": true},
+		Commanders: map[string]conf.CommanderConf{"Jameson": {Pages: map[string]bool{"cargo": true}}},
+	}, conf.APIConf{})
+
+	// All pages are previewed, enabled as they are for the active commander
+	pages := previewPages(t, ts)
+	for _, key := range []edreader.PageKey{edreader.PageDestination, edreader.PageLocation, edreader.PageCargo, edreader.PageRoute} {
+		preview, ok := pages[key]
+		if !ok {
+			t.Errorf("page %s not previewed", key)
+			continue
+		}
+		if preview.Enabled != (key == edreader.PageCargo) {
+			t.Errorf("page %s enabled: %v", key, preview.Enabled)
+		}
+	}
+	if id := pages[edreader.PageCargo].Page.ID; id != "cargo" {
+		t.Errorf("got cargo page %q, wanted all cargo", id)
+	}
+	if lines := pages[edreader.PageRoute].Page.Lines; len(lines) == 0 {
+		t.Error("the route page, which is not shown, was not rendered")
+	}
+
+	// The soft button of the web UI presses the one of the page shown
+	resp := request(t, ts, http.MethodPost, "/api/select?page=0", "")
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("select got status %d", resp.StatusCode)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for previewPages(t, ts)[edreader.PageCargo].Page.ID != "cargo:stolen" {
+		if time.Now().After(deadline) {
+			t.Fatal("the cargo page preview did not show the stolen cargo after the select")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	resp = request(t, ts, http.MethodPost, "/api/select?page=x", "")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("select of an invalid page got status %d", resp.StatusCode)
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	ts, dir := startServer(t, conf.APIConf{})
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/ws"
+
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Origin": {"https://evil.example.com"}}); err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("a page of another origin connected (%v)", err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Origin": {ts.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var update Update
+	if err := conn.ReadJSON(&update); err != nil {
+		t.Fatal(err)
+	}
+	if update.State.Journal.StarSystem != "Sol" {
+		t.Errorf("got %q on connecting, wanted the current state in Sol", update.State.Journal.StarSystem)
+	}
+
+	// Changes are pushed as the journal is written
+	f, err := os.OpenFile(filepath.Join(dir, testJournal), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{ "timestamp":"2025-01-01T10:01:00Z", "event":"FSDJump", "StarSystem":"Alpha Centauri", "SystemAddress":3932277478106, "StarPos":[3.03,-0.09,3.16] }` + "\n")
+	f.Close()
+	for update.State.Journal.StarSystem != "Alpha Centauri" {
+		if err := conn.ReadJSON(&update); err != nil {
+			t.Fatalf("the jump was not pushed: %v", err)
+		}
+	}
+}
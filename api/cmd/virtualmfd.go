@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/pellux-network/EDxDC/api"
+	"github.com/pellux-network/EDxDC/conf"
+	"github.com/pellux-network/EDxDC/edreader"
+	"github.com/pellux-network/EDxDC/logging"
+	"github.com/rs/zerolog/log"
+)
+
+// Runs the journal reader and the virtual MFD without the tray app or the device, e.g. for page
+// development on platforms without DirectOutput. Must be run from the repository root.
+func main() {
+	confPath := flag.String("conf", "main.conf", "Path to the config file")
+	listen := flag.String("listen", api.DefaultListen, "Address to serve the virtual MFD on")
+	flag.Parse()
+
+	cfg := conf.LoadOrCreateConf(*confPath)
+	baseDir := filepath.Dir(*confPath)
+	logging.Init(baseDir, cfg.Loglevel)
+
+	reader := edreader.New(cfg, edreader.Options{})
+	if err := reader.RegisterTemplatePages(baseDir); err != nil {
+		log.Error().Err(err).Msg("Failed to load page templates")
+	}
+	defer reader.Close()
+	go func() {
+		if err := reader.Run(context.Background()); err != nil {
+			log.Fatal().Err(err).Msg("Failed to read the journal")
+		}
+	}()
+
+	apiConf := cfg.API
+	apiConf.Listen = *listen
+	server := api.New(apiConf, reader)
+	if err := server.Start(); err != nil {
+		log.Fatal().Err(err).Msg("Failed to start server")
+	}
+	defer server.Close()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	<-sigCh
+}
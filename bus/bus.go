@@ -0,0 +1,239 @@
+package bus
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+/*
+ Module passing messages from the journal reader to the parts of EDxDC that act on them, such as the
+ pages, the MFD and the uploads. Handlers subscribe to the type of message they handle and get their
+ own queue, so a slow handler does not hold up the reader or the other handlers.
+*/
+
+// Policy tells what happens to a message published while the queue of a handler is full
+type Policy int
+
+const (
+	// DropOldest drops the oldest queued message to make room, for handlers that only need the latest
+	// message, such as a display
+	DropOldest Policy = iota
+	// DropNewest drops the message being published
+	DropNewest
+	// Block makes the publisher wait until there is room, for handlers that must see every message,
+	// such as uploads
+	Block
+)
+
+// Options configure a subscription
+type Options struct {
+	// Name identifies the handler in the logs
+	Name string
+	// Queue is the number of messages that can wait for the handler. With 0 the handler is called on
+	// the routine publishing the message, before Publish returns.
+	Queue int
+	// Policy applies when the queue is full
+	Policy Policy
+}
+
+// Bus passes published messages to the handlers subscribed to their type
+type Bus struct {
+	lock   sync.RWMutex
+	subs   map[reflect.Type][]*Subscription
+	closed bool
+}
+
+// New returns a bus without subscriptions
+func New() *Bus {
+	return &Bus{subs: map[reflect.Type][]*Subscription{}}
+}
+
+// Subscription is a handler subscribed to a type of message
+type Subscription struct {
+	bus     *Bus
+	typ     reflect.Type
+	opts    Options
+	handle  func(any)
+	queue   chan any
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+	dropped atomic.Uint64
+}
+
+// Subscribe calls the handler with every message of type T published on the bus. Subscribing to a
+// closed bus returns a subscription that never gets messages.
+func Subscribe[T any](b *Bus, opts Options, handler func(T)) *Subscription {
+	s := &Subscription{
+		bus:     b,
+		typ:     reflect.TypeFor[T](),
+		opts:    opts,
+		handle:  func(msg any) { handler(msg.(T)) },
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if s.opts.Name == "" {
+		s.opts.Name = s.typ.String()
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.closed {
+		close(s.done)
+		close(s.stopped)
+		return s
+	}
+	if opts.Queue > 0 {
+		s.queue = make(chan any, opts.Queue)
+		go s.run()
+	} else {
+		close(s.stopped)
+	}
+	b.subs[s.typ] = append(b.subs[s.typ], s)
+	return s
+}
+
+// Publish passes the message to the handlers subscribed to its type. It only waits for handlers
+// without a queue, and for full queues with the Block policy.
+func Publish[T any](b *Bus, msg T) {
+	b.lock.RLock()
+	subs := b.subs[reflect.TypeFor[T]()]
+	b.lock.RUnlock()
+	for _, s := range subs {
+		s.deliver(msg)
+	}
+}
+
+// Close stops the subscriptions, letting the handlers work off their queues first for up to the
+// timeout. Messages published afterwards are dropped.
+func (b *Bus) Close(timeout time.Duration) {
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		return
+	}
+	b.closed = true
+	var subs []*Subscription
+	for _, list := range b.subs {
+		subs = append(subs, list...)
+	}
+	b.subs = map[reflect.Type][]*Subscription{}
+	b.lock.Unlock()
+
+	for _, s := range subs {
+		s.once.Do(func() { close(s.done) })
+	}
+	deadline := time.After(timeout)
+	for _, s := range subs {
+		select {
+		case <-s.stopped:
+		case <-deadline:
+			log.Warn().Str("handler", s.opts.Name).Int("queued", len(s.queue)).Msg("Event handler did not finish in time, dropping its messages")
+			return
+		}
+	}
+}
+
+// Unsubscribe stops passing messages to the handler. Messages already queued are still handled.
+func (s *Subscription) Unsubscribe() {
+	b := s.bus
+	b.lock.Lock()
+	list := b.subs[s.typ]
+	for i, sub := range list {
+		if sub == s {
+			b.subs[s.typ] = append(list[:i:i], list[i+1:]...)
+			break
+		}
+	}
+	b.lock.Unlock()
+	s.once.Do(func() { close(s.done) })
+}
+
+// Dropped returns the number of messages dropped because the queue was full
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+func (s *Subscription) deliver(msg any) {
+	if s.queue == nil {
+		select {
+		case <-s.done:
+		default:
+			s.call(msg)
+		}
+		return
+	}
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+
+	switch s.opts.Policy {
+	case Block:
+		select {
+		case s.queue <- msg:
+		case <-s.done:
+		}
+		return
+	case DropNewest:
+		select {
+		case s.queue <- msg:
+		default:
+			s.drop()
+		}
+		return
+	}
+	for {
+		select {
+		case s.queue <- msg:
+			return
+		default:
+		}
+		select {
+		case <-s.queue:
+			s.drop()
+		default:
+		}
+	}
+}
+
+func (s *Subscription) drop() {
+	// A full queue drops many messages in a row, so only some are logged
+	if n := s.dropped.Add(1); n == 1 || n%1000 == 0 {
+		log.Warn().Str("handler", s.opts.Name).Uint64("dropped", n).Msg("Event handler falling behind, dropping messages")
+	}
+}
+
+func (s *Subscription) run() {
+	defer close(s.stopped)
+	for {
+		select {
+		case msg := <-s.queue:
+			s.call(msg)
+		case <-s.done:
+			// Work off what was queued before the bus was closed
+			for {
+				select {
+				case msg := <-s.queue:
+					s.call(msg)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// call runs the handler, so that a panicking handler does not take down the reader
+func (s *Subscription) call(msg any) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Interface("panic", r).Str("handler", s.opts.Name).Msg("Event handler failed")
+		}
+	}()
+	s.handle(msg)
+}
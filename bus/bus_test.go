@@ -0,0 +1,148 @@
+package bus
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type ping int
+type pong string
+
+func TestPublish(t *testing.T) {
+	b := New()
+	var inline []ping
+	Subscribe(b, Options{}, func(p ping) { inline = append(inline, p) })
+	var lock sync.Mutex
+	var queued []ping
+	Subscribe(b, Options{Queue: 10, Policy: Block}, func(p ping) {
+		lock.Lock()
+		defer lock.Unlock()
+		queued = append(queued, p)
+	})
+	pongs := 0
+	Subscribe(b, Options{}, func(pong) { pongs++ })
+
+	for i := range 5 {
+		Publish(b, ping(i))
+	}
+	if len(inline) != 5 {
+		t.Errorf("inline handler got %v before Publish returned", inline)
+	}
+	b.Close(time.Second)
+	if len(queued) != 5 || queued[4] != 4 {
+		t.Errorf("queued handler got %v, wanted all messages in order", queued)
+	}
+	if pongs != 0 {
+		t.Errorf("handler got %d messages of another type", pongs)
+	}
+
+	Publish(b, ping(5))
+	if len(inline) != 5 {
+		t.Error("message passed on after closing")
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	for _, test := range []struct {
+		policy Policy
+		want   []ping
+	}{
+		{DropOldest, []ping{0, 3, 4}},
+		{DropNewest, []ping{0, 1, 2}},
+		{Block, []ping{0, 1, 2, 3, 4}},
+	} {
+		b := New()
+		started := make(chan struct{})
+		release := make(chan struct{})
+		var got []ping
+		s := Subscribe(b, Options{Queue: 2, Policy: test.policy}, func(p ping) {
+			if p == 0 {
+				close(started)
+				<-release
+			}
+			got = append(got, p)
+		})
+		Publish(b, ping(0))
+		<-started
+		published := make(chan struct{})
+		go func() {
+			for i := 1; i < 5; i++ {
+				Publish(b, ping(i))
+			}
+			close(published)
+		}()
+
+		if test.policy == Block {
+			select {
+			case <-published:
+				t.Error("publishing to a full queue did not block")
+			case <-time.After(50 * time.Millisecond):
+			}
+		} else {
+			<-published
+		}
+		close(release)
+		<-published
+		b.Close(time.Second)
+
+		if len(got) != len(test.want) {
+			t.Errorf("policy %d: got %v, wanted %v", test.policy, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("policy %d: got %v, wanted %v", test.policy, got, test.want)
+				break
+			}
+		}
+		if dropped := s.Dropped(); dropped != uint64(5-len(test.want)) {
+			t.Errorf("policy %d: %d messages counted as dropped", test.policy, dropped)
+		}
+	}
+}
+
+func TestCloseUnblocksPublisher(t *testing.T) {
+	b := New()
+	release := make(chan struct{})
+	Subscribe(b, Options{Queue: 1, Policy: Block}, func(ping) { <-release })
+	published := make(chan struct{})
+	go func() {
+		for i := range 5 {
+			Publish(b, ping(i))
+		}
+		close(published)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	start := time.Now()
+	b.Close(50 * time.Millisecond)
+	if time.Since(start) > time.Second {
+		t.Error("Close waited for the stuck handler past the timeout")
+	}
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Error("publisher still blocked after closing")
+	}
+	close(release)
+}
+
+func TestUnsubscribeAndPanic(t *testing.T) {
+	b := New()
+	defer b.Close(time.Second)
+	calls := 0
+	s := Subscribe(b, Options{}, func(p ping) {
+		calls++
+		if p == 1 {
+			panic("handler bug")
+		}
+	})
+	Publish(b, ping(1))
+	Publish(b, ping(2))
+	s.Unsubscribe()
+	Publish(b, ping(3))
+	if calls != 2 {
+		t.Errorf("got %d calls, wanted 2", calls)
+	}
+}
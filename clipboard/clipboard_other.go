@@ -0,0 +1,10 @@
+//go:build !windows
+
+package clipboard
+
+import "errors"
+
+// Write replaces the content of the clipboard with the text. It is only supported on Windows.
+func Write(text string) error {
+	return errors.New("clipboard is not supported on this platform")
+}
@@ -0,0 +1,63 @@
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	cfUnicodeText = 13
+	gmemMoveable  = 0x0002
+)
+
+var (
+	user32   = syscall.NewLazyDLL("user32.dll")
+	kernel32 = syscall.NewLazyDLL("kernel32.dll")
+
+	openClipboard    = user32.NewProc("OpenClipboard")
+	closeClipboard   = user32.NewProc("CloseClipboard")
+	emptyClipboard   = user32.NewProc("EmptyClipboard")
+	setClipboardData = user32.NewProc("SetClipboardData")
+	globalAlloc      = kernel32.NewProc("GlobalAlloc")
+	globalFree       = kernel32.NewProc("GlobalFree")
+	globalLock       = kernel32.NewProc("GlobalLock")
+	globalUnlock     = kernel32.NewProc("GlobalUnlock")
+	moveMemory       = kernel32.NewProc("RtlMoveMemory")
+)
+
+// Write replaces the content of the clipboard with the text
+func Write(text string) error {
+	data, err := syscall.UTF16FromString(text)
+	if err != nil {
+		return err
+	}
+	if r, _, err := openClipboard.Call(0); r == 0 {
+		return fmt.Errorf("opening clipboard: %w", err)
+	}
+	defer closeClipboard.Call()
+
+	if r, _, err := emptyClipboard.Call(); r == 0 {
+		return fmt.Errorf("emptying clipboard: %w", err)
+	}
+	size := uintptr(len(data)) * unsafe.Sizeof(data[0])
+	mem, _, err := globalAlloc.Call(gmemMoveable, size)
+	if mem == 0 {
+		return fmt.Errorf("allocating clipboard memory: %w", err)
+	}
+	ptr, _, err := globalLock.Call(mem)
+	if ptr == 0 {
+		globalFree.Call(mem)
+		return fmt.Errorf("locking clipboard memory: %w", err)
+	}
+	moveMemory.Call(ptr, uintptr(unsafe.Pointer(&data[0])), size)
+	globalUnlock.Call(mem)
+
+	// The clipboard owns the memory once SetClipboardData succeeds
+	if r, _, _ := setClipboardData.Call(cfUnicodeText, mem); r == 0 {
+		globalFree.Call(mem)
+		return errors.New("setting clipboard data failed")
+	}
+	return nil
+}
@@ -3,18 +3,167 @@ package conf
 import (
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
-	"golang.org/x/sys/windows/registry"
 	"gopkg.in/yaml.v2"
 )
 
 // Conf is the app config
 type Conf struct {
 	JournalsFolder  string          `yaml:"journalsfolder"`
+	Watcher         WatcherConf     `yaml:"watcher"`
 	Pages           map[string]bool `yaml:"pages"`
 	CheckForUpdates bool            `yaml:"checkforupdates"`
 	Loglevel        string          `json:"loglevel" yaml:"loglevel"`
+	Scroll          ScrollConf      `yaml:"scroll"`
+	Marquee         MarqueeConf     `yaml:"marquee"`
+	Templates       []TemplateConf  `yaml:"templates"`
+	API             APIConf         `yaml:"api"`
+	FileOutput      FileOutputConf  `yaml:"fileoutput"`
+	MQTT            MQTTConf        `yaml:"mqtt"`
+	EDDN            EDDNConf        `yaml:"eddn"`
+	EDSM            EDSMConf        `yaml:"edsm"`
+	Inara           InaraConf       `yaml:"inara"`
+	Galaxy          GalaxyConf      `yaml:"galaxy"`
+	// Commanders overrides parts of the config for each commander, by commander name
+	Commanders map[string]CommanderConf `yaml:"commanders"`
+}
+
+// CommanderConf overrides parts of the config for one commander, for players with several accounts
+// writing to the same journal folder. The settings left out are taken from the rest of the config.
+type CommanderConf struct {
+	Pages map[string]bool `yaml:"pages"`
+	// EDSMAPIKey is the EDSM API key of the commander, used if EDSM upload is enabled
+	EDSMAPIKey string `yaml:"edsmapikey"`
+	// InaraAPIKey is the Inara API key of the commander, used if Inara upload is enabled
+	InaraAPIKey string `yaml:"inaraapikey"`
+}
+
+// WatcherConf sets how the journal folder is watched for changes
+type WatcherConf struct {
+	// Mode is auto, notify or poll. Auto polls folders on network shares, where change notifications
+	// are not reliable, and uses notifications otherwise. Folders mounted through Wine or from a share
+	// that is not recognized need poll.
+	Mode string `yaml:"mode"`
+	// Interval is how often the folder is polled, and Jitter is the most added to it at random
+	Interval time.Duration `yaml:"interval"`
+	Jitter   time.Duration `yaml:"jitter"`
+	// Debounce is how long changes wait to be read, so that files written together are read together.
+	// If it is not set, changes wait 50ms.
+	Debounce time.Duration `yaml:"debounce"`
+}
+
+// GalaxyConf sets where the system, body and station information on the pages comes from
+type GalaxyConf struct {
+	// Providers lists the sources in order of priority: offline, edsm, spansh and journal. Information
+	// missing from one source is filled in from the next ones.
+	Providers []string `yaml:"providers"`
+	// Database is the folder of the offline database that dumps are imported into with the
+	// galaxy-import command. By default it is the galaxy folder next to the config.
+	Database string `yaml:"database"`
+}
+
+// InaraConf controls keeping the commander's Inara profile up to date
+type InaraConf struct {
+	Enabled bool `yaml:"enabled"`
+	// APIKey is the personal API key from the Inara settings
+	APIKey string `yaml:"apikey"`
+	// Commander limits the upload to one commander, if set
+	Commander string `yaml:"commander"`
+	// URL overrides the API endpoint
+	URL string `yaml:"url"`
+}
+
+// EDSMConf controls uploading journal events to the commander's EDSM logbook
+type EDSMConf struct {
+	Upload bool `yaml:"upload"`
+	// Commander and APIKey are from the API key page of the EDSM settings. Only events of this
+	// commander are uploaded.
+	Commander string `yaml:"commander"`
+	APIKey    string `yaml:"apikey"`
+}
+
+// EDDNConf controls contributing market and exploration data to the Elite Dangerous Data Network
+type EDDNConf struct {
+	Enabled bool `yaml:"enabled"`
+	// TestMode sends messages to the test schemas, which EDDN checks but does not pass on
+	TestMode bool `yaml:"testmode"`
+	// URL overrides the upload endpoint
+	URL string `yaml:"url"`
+}
+
+// MQTTConf controls publishing game events and state to an MQTT broker
+type MQTTConf struct {
+	Enabled bool `yaml:"enabled"`
+	// Broker is the URL of the broker, such as tcp://localhost:1883 or ssl://broker:8883
+	Broker      string      `yaml:"broker"`
+	ClientID    string      `yaml:"clientid"`
+	Username    string      `yaml:"username"`
+	Password    string      `yaml:"password"`
+	TopicPrefix string      `yaml:"topicprefix"`
+	QoS         byte        `yaml:"qos"`
+	TLS         MQTTTLSConf `yaml:"tls"`
+}
+
+// MQTTTLSConf holds the TLS settings for the MQTT connection
+type MQTTTLSConf struct {
+	Enabled            bool   `yaml:"enabled"`
+	CAFile             string `yaml:"cafile"`
+	CertFile           string `yaml:"certfile"`
+	KeyFile            string `yaml:"keyfile"`
+	InsecureSkipVerify bool   `yaml:"insecureskipverify"`
+}
+
+// FileOutputConf controls writing the MFD content to mfd.json and text files for other tools
+type FileOutputConf struct {
+	Enabled bool `yaml:"enabled"`
+	// Dir defaults to the config directory
+	Dir       string        `yaml:"dir"`
+	PageFiles bool          `yaml:"pagefiles"`
+	Debounce  time.Duration `yaml:"debounce"`
+	// MaxDelay is the longest a change waits to be written while the MFD keeps changing
+	MaxDelay time.Duration `yaml:"maxdelay"`
+}
+
+// APIConf controls the embedded HTTP and WebSocket server
+type APIConf struct {
+	Enabled bool   `yaml:"enabled"`
+	Listen  string `yaml:"listen"`
+	// AllowedOrigins are the origins, such as https://overlay.example.com, of pages on other hosts
+	// that may use the API. Pages served by the API itself or from localhost always may. Overlays
+	// opened from local files have the origin null.
+	AllowedOrigins []string `yaml:"allowedorigins"`
+	// AllowedHosts are the host names, besides localhost and the one listened on, the server may be
+	// reached by, such as the name of the PC for tablets on the network. IP addresses always work.
+	AllowedHosts []string `yaml:"allowedhosts"`
+}
+
+// TemplateConf defines a custom page rendered from a Go text/template. The page is shown if its key
+// is enabled in the pages section.
+type TemplateConf struct {
+	Key  string `yaml:"key"`
+	Name string `yaml:"name"`
+	// ID is an optional template for what the page is showing. The scroll position is reset when it changes.
+	ID string `yaml:"id"`
+	// Text is the template itself. File may be used instead, relative to the config directory.
+	Text string `yaml:"text"`
+	File string `yaml:"file"`
+}
+
+// ScrollConf controls how pages longer than the display are scrolled
+type ScrollConf struct {
+	ResetOnRefresh bool          `yaml:"resetonrefresh"`
+	AutoScroll     bool          `yaml:"autoscroll"`
+	Dwell          time.Duration `yaml:"dwell"`
+}
+
+// MarqueeConf controls horizontal scrolling of lines longer than the display
+type MarqueeConf struct {
+	Enabled bool          `yaml:"enabled"`
+	Rate    time.Duration `yaml:"rate"`
 }
 
 // LoadOrCreateConf loads the config from the given path, or creates a default one if missing.
@@ -27,13 +176,64 @@ func LoadOrCreateConf(confPath string) Conf {
 		log.Warn().Str("path", confPath).Msg("Config file not found, creating default config.")
 		defaultYAML := `journalsfolder: "%USERPROFILE%\\Saved Games\\Frontier Developments\\Elite Dangerous"
 
+watcher:
+  mode: auto
+  interval: 1s
+  jitter: 250ms
+  debounce: 50ms
+
 pages:
   destination: true
   location: true
   cargo: true
+  route: false
 
 checkforupdates: true
 loglevel: info
+
+scroll:
+  resetonrefresh: false
+  autoscroll: false
+  dwell: 3s
+
+marquee:
+  enabled: true
+  rate: 400ms
+
+api:
+  enabled: false
+  listen: "127.0.0.1:8765"
+  allowedorigins: []
+  allowedhosts: []
+
+fileoutput:
+  enabled: false
+  dir: ""
+  pagefiles: false
+  debounce: 250ms
+  maxdelay: 2s
+
+mqtt:
+  enabled: false
+  broker: "tcp://localhost:1883"
+  username: ""
+  password: ""
+  topicprefix: edxdc
+  qos: 0
+eddn:
+  enabled: false
+  testmode: false
+edsm:
+  upload: false
+  commander: ""
+  apikey: ""
+inara:
+  enabled: false
+  apikey: ""
+  commander: ""
+galaxy:
+  providers: [offline, edsm, spansh, journal]
+commanders: {}
 `
 		if err := os.MkdirAll(filepath.Dir(confPath), 0755); err != nil {
 			log.Fatal().Err(err).Msg("Failed to create config directory")
@@ -68,6 +268,80 @@ loglevel: info
 
 // ExpandJournalFolderPath expands any env variables in the journal folder path.
 func (c Conf) ExpandJournalFolderPath() string {
-	exp, _ := registry.ExpandString(c.JournalsFolder)
-	return exp
+	return expandString(c.JournalsFolder)
+}
+
+// ForCommander returns the config with the overrides of the commander applied
+func (c Conf) ForCommander(name string) Conf {
+	cc, ok := c.commander(name)
+	if !ok {
+		return c
+	}
+	if len(cc.Pages) > 0 {
+		c.Pages = cc.Pages
+	}
+	if cc.EDSMAPIKey != "" {
+		c.EDSM.Commander = name
+		c.EDSM.APIKey = cc.EDSMAPIKey
+	}
+	if cc.InaraAPIKey != "" {
+		c.Inara.Commander = name
+		c.Inara.APIKey = cc.InaraAPIKey
+	}
+	return c
+}
+
+// PageSets returns the pages enabled in the config and the ones enabled for each commander
+func (c Conf) PageSets() []map[string]bool {
+	sets := []map[string]bool{c.Pages}
+	for _, name := range c.commanderNames() {
+		if pages := c.Commanders[name].Pages; len(pages) > 0 {
+			sets = append(sets, pages)
+		}
+	}
+	return sets
+}
+
+// EDSMUploads returns the EDSM upload config of every commander with an API key, if EDSM upload is
+// enabled
+func (c Conf) EDSMUploads() []EDSMConf {
+	if !c.EDSM.Upload {
+		return nil
+	}
+	var uploads []EDSMConf
+	if cc, _ := c.commander(c.EDSM.Commander); c.EDSM.Commander != "" && c.EDSM.APIKey != "" && cc.EDSMAPIKey == "" {
+		uploads = append(uploads, c.EDSM)
+	}
+	for _, name := range c.commanderNames() {
+		if key := c.Commanders[name].EDSMAPIKey; key != "" {
+			uploads = append(uploads, EDSMConf{Upload: true, Commander: name, APIKey: key})
+		}
+	}
+	return uploads
+}
+
+// commander looks up the overrides of a commander. Commander names are not case sensitive in the game.
+func (c Conf) commander(name string) (CommanderConf, bool) {
+	if name == "" {
+		return CommanderConf{}, false
+	}
+	if cc, ok := c.Commanders[name]; ok {
+		return cc, true
+	}
+	for n, cc := range c.Commanders {
+		if strings.EqualFold(n, name) {
+			return cc, true
+		}
+	}
+	return CommanderConf{}, false
+}
+
+// commanderNames returns the names of the commanders with overrides, sorted
+func (c Conf) commanderNames() []string {
+	names := make([]string, 0, len(c.Commanders))
+	for name := range c.Commanders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
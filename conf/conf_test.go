@@ -0,0 +1,147 @@
+package conf
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func testConf() Conf {
+	return Conf{
+		Pages: map[string]bool{"destination": true, "location": true},
+		EDSM:  EDSMConf{Upload: true, Commander: "Jameson", APIKey: "main-key"},
+		Inara: InaraConf{Enabled: true, APIKey: "inara-key"},
+		Commanders: map[string]CommanderConf{
+			"Jameson": {EDSMAPIKey: "jameson-key"},
+			"Alt":     {Pages: map[string]bool{"cargo": true}, InaraAPIKey: "alt-inara-key"},
+			"Trader":  {Pages: map[string]bool{"cargo": true, "location": true}, EDSMAPIKey: "trader-key"},
+		},
+	}
+}
+
+func TestForCommander(t *testing.T) {
+	tests := []struct {
+		name      string
+		pages     map[string]bool
+		edsm      EDSMConf
+		inaraCmdr string
+		inaraKey  string
+	}{
+		{
+			name:     "",
+			pages:    map[string]bool{"destination": true, "location": true},
+			edsm:     EDSMConf{Upload: true, Commander: "Jameson", APIKey: "main-key"},
+			inaraKey: "inara-key",
+		},
+		{
+			name:     "Unknown",
+			pages:    map[string]bool{"destination": true, "location": true},
+			edsm:     EDSMConf{Upload: true, Commander: "Jameson", APIKey: "main-key"},
+			inaraKey: "inara-key",
+		},
+		{
+			name:     "Jameson",
+			pages:    map[string]bool{"destination": true, "location": true},
+			edsm:     EDSMConf{Upload: true, Commander: "Jameson", APIKey: "jameson-key"},
+			inaraKey: "inara-key",
+		},
+		{
+			// Commander names are not case sensitive
+			name:      "ALT",
+			pages:     map[string]bool{"cargo": true},
+			edsm:      EDSMConf{Upload: true, Commander: "Jameson", APIKey: "main-key"},
+			inaraCmdr: "ALT",
+			inaraKey:  "alt-inara-key",
+		},
+		{
+			name:     "trader",
+			pages:    map[string]bool{"cargo": true, "location": true},
+			edsm:     EDSMConf{Upload: true, Commander: "trader", APIKey: "trader-key"},
+			inaraKey: "inara-key",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := testConf().ForCommander(test.name)
+			if diff := cmp.Diff(test.pages, c.Pages); diff != "" {
+				t.Errorf("pages differ (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(test.edsm, c.EDSM); diff != "" {
+				t.Errorf("EDSM config differs (-want +got):\n%s", diff)
+			}
+			if c.Inara.Commander != test.inaraCmdr || c.Inara.APIKey != test.inaraKey {
+				t.Errorf("got Inara key %q for %q, wanted %q for %q", c.Inara.APIKey, c.Inara.Commander, test.inaraKey, test.inaraCmdr)
+			}
+		})
+	}
+}
+
+func TestPageSets(t *testing.T) {
+	want := []map[string]bool{
+		{"destination": true, "location": true},
+		// Sorted by commander name, leaving out the commanders without pages of their own
+		{"cargo": true},
+		{"cargo": true, "location": true},
+	}
+	if diff := cmp.Diff(want, testConf().PageSets()); diff != "" {
+		t.Errorf("page sets differ (-want +got):\n%s", diff)
+	}
+}
+
+func TestEDSMUploads(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Conf)
+		want   []EDSMConf
+	}{
+		{
+			name:   "disabled",
+			change: func(c *Conf) { c.EDSM.Upload = false },
+		},
+		{
+			// The key of the commander overrides the one of the EDSM section
+			name: "commander keys",
+			want: []EDSMConf{
+				{Upload: true, Commander: "Jameson", APIKey: "jameson-key"},
+				{Upload: true, Commander: "Trader", APIKey: "trader-key"},
+			},
+		},
+		{
+			name:   "commander keys differing in case",
+			change: func(c *Conf) { c.EDSM.Commander = "JAMESON" },
+			want: []EDSMConf{
+				{Upload: true, Commander: "Jameson", APIKey: "jameson-key"},
+				{Upload: true, Commander: "Trader", APIKey: "trader-key"},
+			},
+		},
+		{
+			name:   "EDSM section and commander keys",
+			change: func(c *Conf) { c.EDSM.Commander = "Alt" },
+			want: []EDSMConf{
+				{Upload: true, Commander: "Alt", APIKey: "main-key"},
+				{Upload: true, Commander: "Jameson", APIKey: "jameson-key"},
+				{Upload: true, Commander: "Trader", APIKey: "trader-key"},
+			},
+		},
+		{
+			name:   "EDSM section only",
+			change: func(c *Conf) { c.Commanders = nil },
+			want:   []EDSMConf{{Upload: true, Commander: "Jameson", APIKey: "main-key"}},
+		},
+		{
+			name:   "EDSM section without commander",
+			change: func(c *Conf) { c.Commanders, c.EDSM.Commander = nil, "" },
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := testConf()
+			if test.change != nil {
+				test.change(&c)
+			}
+			if diff := cmp.Diff(test.want, c.EDSMUploads()); diff != "" {
+				t.Errorf("uploads differ (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
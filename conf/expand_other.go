@@ -0,0 +1,21 @@
+//go:build !windows
+
+package conf
+
+import (
+	"os"
+	"regexp"
+)
+
+var envVariable = regexp.MustCompile(`%(\w+)%`)
+
+// expandString expands %VARIABLE% style environment variables, like the Windows shell does
+func expandString(s string) string {
+	return envVariable.ReplaceAllStringFunc(s, func(v string) string {
+		value, ok := os.LookupEnv(v[1 : len(v)-1])
+		if !ok {
+			return v
+		}
+		return value
+	})
+}
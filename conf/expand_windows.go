@@ -0,0 +1,9 @@
+package conf
+
+import "golang.org/x/sys/windows/registry"
+
+// expandString expands %VARIABLE% style environment variables
+func expandString(s string) string {
+	exp, _ := registry.ExpandString(s)
+	return exp
+}
@@ -1,13 +1,16 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
+	"unicode"
 
 	"github.com/pellux-network/EDxDC/logging"
 	"github.com/rs/zerolog/log"
@@ -18,10 +21,15 @@ import (
 	_ "github.com/abemedia/go-winsparkle/dll" // Embed DLL.
 	"github.com/getlantern/systray"
 	"github.com/ncruces/zenity"
+	"github.com/pellux-network/EDxDC/api"
+	"github.com/pellux-network/EDxDC/bus"
 	"github.com/pellux-network/EDxDC/conf"
+	"github.com/pellux-network/EDxDC/eddn"
 	"github.com/pellux-network/EDxDC/edreader"
 	"github.com/pellux-network/EDxDC/edsm"
+	"github.com/pellux-network/EDxDC/inara"
 	"github.com/pellux-network/EDxDC/mfd"
+	"github.com/pellux-network/EDxDC/mqtt"
 )
 
 // TextLogFormatter gives me custom command-line formatting
@@ -77,6 +85,19 @@ func main() {
 	logging.Init(baseDir, appConf.Loglevel)
 	log.Info().Str("config", logging.CleanPath(confPath)).Msg("Loaded configuration")
 
+	// The import runs from the command line, without updates or the MFD
+	if len(os.Args) > 1 && os.Args[1] == "galaxy-import" {
+		if len(os.Args) < 3 {
+			log.Error().Msg("Usage: galaxy-import dumpfile...")
+			os.Exit(1)
+		}
+		if err := runGalaxyImport(galaxyDatabaseDir(baseDir, appConf), os.Args[2:]); err != nil {
+			log.Error().Err(err).Msg("Galaxy import failed")
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	if !isPortable {
 		// WinSparkle setup
 		winsparkle.SetAppcastURL("https://pellux-network.github.io/EDxDC/appcast.xml")
@@ -142,6 +163,8 @@ func onReady(quitCh chan struct{}, isPortable bool, conf conf.Conf) {
 	_ = zenity.Notify("EDxDC has started successfully.", zenity.Title("EDxDC"))
 
 	mCheckUpdate := systray.AddMenuItem("Check for Updates", "Check for updates to EDxDC")
+	mPlotRoute := systray.AddMenuItem("Plot Route...", "Plot a route with Spansh and follow it on the MFD")
+	mClearRoute := systray.AddMenuItem("Clear Route", "Stop following the plotted route")
 	mAbout := systray.AddMenuItem("About", "About EDxDC")
 	systray.AddSeparator()
 	mQuit := systray.AddMenuItem("Quit", "Quit EDxDC")
@@ -205,22 +228,130 @@ func onReady(quitCh chan struct{}, isPortable bool, conf conf.Conf) {
 
 		log.Info().Str("logfile", logging.CleanPath(logPath)).Msg("Logging to file")
 
-		// Calculate number of enabled pages
-		pageCount := 0
-		for _, enabled := range conf.Pages {
-			if enabled {
-				pageCount++
-			}
+		reader := edreader.New(conf, edreader.Options{
+			Display:        mfd.Write,
+			RouteFile:      filepath.Join(baseDir, "route.json"),
+			BackfillFile:   filepath.Join(baseDir, "history.json"),
+			SnapshotFile:   filepath.Join(baseDir, "state.json"),
+			GalaxyDatabase: galaxyDatabaseDir(baseDir, conf),
+		})
+		if err := reader.RegisterTemplatePages(baseDir); err != nil {
+			log.Error().Err(err).Msg("Failed to load page templates")
 		}
 
-		err := mfd.InitDevice(uint32(pageCount), edsm.ClearCache)
+		// Calculate number of enabled pages
+		pageCount := reader.EnabledPageCount()
+
+		mfd.SetScrollOptions(mfd.ScrollOptions{
+			ResetOnRefresh: conf.Scroll.ResetOnRefresh,
+			AutoScroll:     conf.Scroll.AutoScroll,
+			Dwell:          conf.Scroll.Dwell,
+		})
+		mfd.SetMarqueeOptions(mfd.MarqueeOptions{
+			Enabled: conf.Marquee.Enabled,
+			Rate:    conf.Marquee.Rate,
+		})
+		fileOutputDir := conf.FileOutput.Dir
+		if fileOutputDir == "" {
+			fileOutputDir = baseDir
+		}
+		mfd.SetFileOutputOptions(mfd.FileOutputOptions{
+			Enabled:   conf.FileOutput.Enabled,
+			Dir:       fileOutputDir,
+			PageFiles: conf.FileOutput.PageFiles,
+			Debounce:  conf.FileOutput.Debounce,
+			MaxDelay:  conf.FileOutput.MaxDelay,
+		})
+		err := mfd.InitDevice(uint32(pageCount), reader.SelectPage)
 		if err != nil {
 			log.Fatal().Err(err).Msg("Failed to initialize MFD device")
 		}
 		defer mfd.DeInitDevice()
 
-		edreader.Start(conf)
-		defer edreader.Stop()
+		if conf.API.Enabled {
+			server := api.New(conf.API, reader)
+			if err := server.Start(); err != nil {
+				log.Error().Err(err).Str("listen", conf.API.Listen).Msg("Failed to start API server")
+			} else {
+				defer server.Close()
+			}
+		}
+
+		if conf.MQTT.Enabled {
+			publisher, err := mqtt.New(conf.MQTT)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to set up MQTT publisher")
+			} else {
+				publisher.Start(reader)
+				defer publisher.Close()
+			}
+		}
+
+		if conf.EDDN.Enabled {
+			uploader, err := eddn.New(conf.EDDN, conf.ExpandJournalFolderPath(), filepath.Join(baseDir, "eddn-outbox.json"), AppVersion)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to set up EDDN uploader")
+			} else {
+				uploader.Start(reader)
+				defer uploader.Close()
+			}
+		}
+
+		// One uploader for each commander with an EDSM API key
+		for _, edsmConf := range conf.EDSMUploads() {
+			uploader, err := edsm.NewJournalUploader(edsmConf, edsmFile(baseDir, "edsm-outbox", edsmConf, conf.EDSM), edsmFile(baseDir, "edsm-upload", edsmConf, conf.EDSM), AppVersion)
+			if err != nil {
+				log.Error().Err(err).Str("commander", edsmConf.Commander).Msg("Failed to set up EDSM journal upload")
+				continue
+			}
+			uploader.Start()
+			defer uploader.Close()
+			edreader.Subscribe(reader, bus.Options{Name: "EDSM journal upload", Queue: edreader.ListenerQueue, Policy: bus.Block}, func(e edreader.JournalEvent) {
+				if e.Replay {
+					return
+				}
+				uploader.HandleLine(edsm.JournalLine{
+					File:        e.File,
+					Number:      e.Line,
+					Event:       e.Event.EventName(),
+					Line:        e.Event.Line(),
+					Commander:   e.State.Commander,
+					GameVersion: e.State.GameVersion,
+					GameBuild:   e.State.GameBuild,
+				})
+			})
+		}
+
+		if conf.Inara.Enabled {
+			uploader, err := inara.New(conf, filepath.Join(baseDir, "inara-outbox.json"), AppVersion)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to set up Inara upload")
+			} else {
+				uploader.Start(reader)
+				defer uploader.Close()
+			}
+		}
+
+		// Started once the listeners above are added, and closed before them, so they get all the
+		// events read
+		defer reader.Close()
+		go func() {
+			if err := reader.Run(context.Background()); err != nil {
+				log.Fatal().Err(err).Msg("Failed to read the journal")
+			}
+		}()
+
+		// Handle route menu clicks
+		go func() {
+			for {
+				select {
+				case <-mPlotRoute.ClickedCh:
+					plotRoute(reader)
+				case <-mClearRoute.ClickedCh:
+					reader.ClearRoute()
+				}
+			}
+		}()
 
 		log.Info().Msg("Main event loop started")
 
@@ -235,6 +366,20 @@ func onReady(quitCh chan struct{}, isPortable bool, conf conf.Conf) {
 	}()
 }
 
+// edsmFile returns the path of an EDSM upload file. The commander of the edsm section uses the
+// plain file name, so the upload state is kept when other commanders are added.
+func edsmFile(baseDir, name string, upload, main conf.EDSMConf) string {
+	if !strings.EqualFold(upload.Commander, main.Commander) {
+		name += "-" + strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return r
+			}
+			return '_'
+		}, upload.Commander)
+	}
+	return filepath.Join(baseDir, name+".json")
+}
+
 func onExit() {
 	log.Info().Msg("Application exiting, cleaning up")
 	defer winsparkle.Cleanup()
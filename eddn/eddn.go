@@ -0,0 +1,199 @@
+package eddn
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pellux-network/EDxDC/conf"
+	"github.com/pellux-network/EDxDC/edreader"
+	"github.com/pellux-network/EDxDC/outbox"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+ Module contributing data from the journal to the Elite Dangerous Data Network (https://eddn.edcd.io),
+ following the schemas and rules at https://github.com/EDCD/EDDN/tree/live/schemas.
+
+ Messages are queued in an outbox file and retried until EDDN accepts or rejects them.
+*/
+
+const (
+	// DefaultURL is the EDDN upload endpoint
+	DefaultURL = "https://eddn.edcd.io:4430/upload/"
+	// SoftwareName identifies EDxDC to EDDN listeners
+	SoftwareName = "EDxDC"
+
+	schemaBase = "https://eddn.edcd.io/schemas/"
+
+	SchemaJournal            = "journal/1"
+	SchemaCommodity          = "commodity/3"
+	SchemaNavRoute           = "navroute/1"
+	SchemaFSSDiscoveryScan   = "fssdiscoveryscan/1"
+	SchemaApproachSettlement = "approachsettlement/1"
+	SchemaCodexEntry         = "codexentry/1"
+
+	// outboxLimit bounds the outbox while EDDN is unreachable for a long time
+	outboxLimit   = 1000
+	uploadTimeout = 30 * time.Second
+)
+
+// Envelope is a message as uploaded to EDDN
+type Envelope struct {
+	SchemaRef string `json:"$schemaRef"`
+	Header    Header `json:"header"`
+	Message   any    `json:"message"`
+}
+
+// Header identifies the uploader and the game the message comes from
+type Header struct {
+	UploaderID      string `json:"uploaderID"`
+	SoftwareName    string `json:"softwareName"`
+	SoftwareVersion string `json:"softwareVersion"`
+	GameVersion     string `json:"gameversion"`
+	GameBuild       string `json:"gamebuild"`
+}
+
+// Uploader sends journal data to EDDN
+type Uploader struct {
+	url             string
+	testMode        bool
+	softwareVersion string
+	journalFolder   string
+	client          *http.Client
+	sender          *outbox.Sender
+}
+
+// New creates an uploader reading the game's extra files from the journal folder and queueing messages
+// in the outbox file
+func New(cfg conf.EDDNConf, journalFolder, outboxPath, softwareVersion string) (*Uploader, error) {
+	queue, err := outbox.Open(outboxPath, outboxLimit)
+	if err != nil {
+		return nil, err
+	}
+	u := &Uploader{
+		url:             cfg.URL,
+		testMode:        cfg.TestMode,
+		softwareVersion: softwareVersion,
+		journalFolder:   journalFolder,
+		client:          &http.Client{Timeout: uploadTimeout},
+	}
+	if u.url == "" {
+		u.url = DefaultURL
+	}
+	u.sender = &outbox.Sender{
+		Name:  "EDDN",
+		Queue: queue,
+		Send:  u.upload,
+	}
+	return u, nil
+}
+
+// Start starts uploading new journal events of the reader, and any messages left in the outbox. The
+// messages are queued to the outbox as the reader passes the events on, and uploaded from the routine
+// of the sender, so that the reader does not wait for EDDN.
+func (u *Uploader) Start(reader *edreader.Reader) {
+	log.Info().Bool("testmode", u.testMode).Int("queued", u.sender.Queue.Len()).Msg("Starting EDDN uploader")
+	u.sender.Start()
+	reader.AddJournalListener(u.HandleEvent)
+}
+
+// Close stops uploading. Messages not yet uploaded are kept for the next start.
+func (u *Uploader) Close() {
+	u.sender.Close()
+}
+
+// HandleEvent queues the messages for a journal event, if EDDN accepts it
+func (u *Uploader) HandleEvent(event string, line []byte, state edreader.Journalstate) {
+	if state.Commander == "" {
+		// Nothing is sent before the game has told us who is playing
+		return
+	}
+	schema, message, err := u.messageFor(event, line, state)
+	if err != nil {
+		log.Debug().Err(err).Str("event", event).Msg("Not sending event to EDDN")
+		return
+	}
+	if message == nil {
+		return
+	}
+
+	envelope := Envelope{
+		SchemaRef: u.schemaRef(schema, state.Game),
+		Header: Header{
+			UploaderID:      state.Commander,
+			SoftwareName:    SoftwareName,
+			SoftwareVersion: u.softwareVersion,
+			GameVersion:     state.GameVersion,
+			GameBuild:       state.GameBuild,
+		},
+		Message: message,
+	}
+	if err := u.sender.Push(envelope); err != nil {
+		log.Error().Err(err).Str("event", event).Msg("Failed to queue EDDN message")
+	}
+}
+
+// messageFor builds the message for the event, or returns a nil message for events EDDN does not take
+func (u *Uploader) messageFor(event string, line []byte, state edreader.Journalstate) (string, any, error) {
+	switch event {
+	case "Docked", "FSDJump", "Location", "CarrierJump", "Scan", "SAASignalsFound":
+		message, err := journalMessage(line, state)
+		return SchemaJournal, message, err
+	case "CodexEntry":
+		message, err := codexEntryMessage(line, state)
+		return SchemaCodexEntry, message, err
+	case "FSSDiscoveryScan":
+		message, err := fssDiscoveryScanMessage(line, state)
+		return SchemaFSSDiscoveryScan, message, err
+	case "ApproachSettlement":
+		message, err := approachSettlementMessage(line, state)
+		return SchemaApproachSettlement, message, err
+	case "NavRoute":
+		message, err := navRouteMessage(u.journalFolder, state)
+		return SchemaNavRoute, message, err
+	case "Market":
+		message, err := commodityMessage(u.journalFolder, line, state)
+		return SchemaCommodity, message, err
+	}
+	return "", nil, nil
+}
+
+// schemaRef returns the reference of the schema, pointing to the test schema in test mode or when
+// playing a beta of the game
+func (u *Uploader) schemaRef(schema string, game edreader.Game) string {
+	ref := schemaBase + schema
+	if u.testMode || strings.Contains(strings.ToLower(game.GameVersion), "beta") {
+		ref += "/test"
+	}
+	return ref
+}
+
+// upload sends a single message to EDDN
+func (u *Uploader) upload(messages []json.RawMessage) error {
+	for _, message := range messages {
+		resp, err := u.client.Post(u.url, "application/json", bytes.NewReader(message))
+		if err != nil {
+			return err
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			log.Trace().Msg("Uploaded message to EDDN")
+		case resp.StatusCode == http.StatusBadRequest,
+			resp.StatusCode == http.StatusRequestEntityTooLarge,
+			resp.StatusCode == http.StatusUpgradeRequired:
+			// The message or its schema is invalid, sending it again will not help
+			return outbox.Permanent(fmt.Errorf("EDDN rejected message: %s: %s", resp.Status, strings.TrimSpace(string(body))))
+		default:
+			return fmt.Errorf("EDDN upload failed: %s", resp.Status)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package eddn
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pellux-network/EDxDC/conf"
+	"github.com/pellux-network/EDxDC/edreader"
+)
+
+func testState() edreader.Journalstate {
+	state := edreader.Journalstate{Commander: "Jameson"}
+	state.StarSystem = "Shinrarta Dezhra"
+	state.Location.SystemAddress = 3932277478106
+	state.StarPos = []float64{55.71875, 17.59375, 27.15625}
+	state.GameVersion = "4.0.0.1904"
+	state.GameBuild = "r308767/r0 "
+	state.Odyssey = true
+	state.Horizons = true
+	return state
+}
+
+func TestJournalMessageStripsPersonalFields(t *testing.T) {
+	line := []byte(`{ "timestamp":"2025-01-01T00:00:00Z", "event":"FSDJump", "StarSystem":"Shinrarta Dezhra", "SystemAddress":3932277478106, "StarPos":[55.71875,17.59375,27.15625], "SystemEconomy":"$economy_HighTech;", "SystemEconomy_Localised":"High Tech", "JumpDist":12.5, "FuelUsed":1.2, "FuelLevel":30.1, "BoostUsed":0, "Wanted":true, "Factions":[ { "Name":"Pilots Federation Local Branch", "MyReputation":100.0, "HomeSystem":"Shinrarta Dezhra", "Happiness_Localised":"Elated" } ] }`)
+	message, err := journalMessage(line, testState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"SystemEconomy_Localised", "JumpDist", "FuelUsed", "FuelLevel", "BoostUsed", "Wanted"} {
+		if _, ok := message[field]; ok {
+			t.Errorf("%s was not removed", field)
+		}
+	}
+	faction := message["Factions"].([]any)[0].(map[string]any)
+	for _, field := range []string{"MyReputation", "HomeSystem", "Happiness_Localised"} {
+		if _, ok := faction[field]; ok {
+			t.Errorf("faction %s was not removed", field)
+		}
+	}
+	if message["SystemAddress"].(json.Number).String() != "3932277478106" {
+		t.Errorf("SystemAddress changed to %v", message["SystemAddress"])
+	}
+	if message["odyssey"] != true || message["horizons"] != true {
+		t.Errorf("game flags missing: %v", message)
+	}
+}
+
+func TestJournalMessageAddsSystem(t *testing.T) {
+	line := []byte(`{ "timestamp":"2025-01-01T00:00:00Z", "event":"Scan", "ScanType":"Detailed", "BodyName":"Shinrarta Dezhra A 1", "BodyID":2, "SystemAddress":3932277478106 }`)
+	message, err := journalMessage(line, testState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message["StarSystem"] != "Shinrarta Dezhra" {
+		t.Errorf("got StarSystem %v", message["StarSystem"])
+	}
+	if pos, ok := message["StarPos"].([]float64); !ok || len(pos) != 3 {
+		t.Errorf("got StarPos %v", message["StarPos"])
+	}
+
+	// A scan from a system the player is no longer in cannot be augmented
+	other := []byte(`{ "timestamp":"2025-01-01T00:00:00Z", "event":"Scan", "BodyName":"Sol", "BodyID":0, "SystemAddress":10477373803 }`)
+	if _, err := journalMessage(other, testState()); err == nil {
+		t.Error("got a message for a different system")
+	}
+}
+
+func TestCodexEntryMessage(t *testing.T) {
+	line := []byte(`{ "timestamp":"2025-01-01T00:00:00Z", "event":"CodexEntry", "EntryID":2100301, "Name":"$Codex_Ent_Cone_Name;", "Name_Localised":"Bark Mounds", "SubCategory":"$Codex_SubCategory_Organic_Structures;", "Category":"$Codex_Category_Biology;", "Region":"$Codex_RegionName_18;", "System":"Shinrarta Dezhra", "SystemAddress":3932277478106, "BodyID":2, "Latitude":10.5, "Longitude":-20.25, "IsNewEntry":true, "NewTraitsDiscovered":true }`)
+	schema, message, err := (&Uploader{}).messageFor("CodexEntry", line, testState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema != SchemaCodexEntry {
+		t.Errorf("got schema %s, wanted %s", schema, SchemaCodexEntry)
+	}
+	entry := message.(map[string]any)
+	for _, field := range []string{"Name_Localised", "IsNewEntry", "NewTraitsDiscovered"} {
+		if _, ok := entry[field]; ok {
+			t.Errorf("%s was not removed", field)
+		}
+	}
+	// The position on the body is part of the entry
+	for _, field := range []string{"EntryID", "Latitude", "Longitude", "BodyID"} {
+		if _, ok := entry[field]; !ok {
+			t.Errorf("%s was removed", field)
+		}
+	}
+	if pos, ok := entry["StarPos"].([]float64); !ok || len(pos) != 3 {
+		t.Errorf("got StarPos %v", entry["StarPos"])
+	}
+	if entry["odyssey"] != true || entry["horizons"] != true {
+		t.Errorf("game flags missing: %v", entry)
+	}
+}
+
+func TestCommodityMessage(t *testing.T) {
+	dir := t.TempDir()
+	market := `{ "timestamp":"2025-01-01T00:00:00Z", "event":"Market", "MarketID":128666762, "StationName":"Jameson Memorial", "StationType":"Orbis", "StarSystem":"Shinrarta Dezhra", "Items":[
+		{ "id":128049202, "Name":"$gold_name;", "Name_Localised":"Gold", "Category":"$MARKET_category_metals;", "BuyPrice":45000, "SellPrice":44000, "MeanPrice":47000, "StockBracket":2, "DemandBracket":0, "Stock":120, "Demand":1 },
+		{ "id":128066403, "Name":"$drones_name;", "Name_Localised":"Limpet", "Category":"$MARKET_category_nonmarketable;", "BuyPrice":101, "SellPrice":0, "MeanPrice":101, "StockBracket":3, "DemandBracket":0, "Stock":9999, "Demand":0 } ] }`
+	if err := os.WriteFile(filepath.Join(dir, FileMarket), []byte(market), 0644); err != nil {
+		t.Fatal(err)
+	}
+	line := []byte(`{ "timestamp":"2025-01-01T00:00:00Z", "event":"Market", "MarketID":128666762, "StationName":"Jameson Memorial", "StarSystem":"Shinrarta Dezhra" }`)
+	message, err := commodityMessage(dir, line, testState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(message.Commodities) != 1 {
+		t.Fatalf("got %d commodities, wanted 1", len(message.Commodities))
+	}
+	if c := message.Commodities[0]; c.Name != "gold" || c.Stock != 120 || c.BuyPrice != 45000 {
+		t.Errorf("got %+v", c)
+	}
+
+	stale := []byte(`{ "timestamp":"2025-01-01T00:00:00Z", "event":"Market", "MarketID":1 }`)
+	if _, err := commodityMessage(dir, stale, testState()); err == nil {
+		t.Error("got a message from the market file of another station")
+	}
+}
+
+func TestUpload(t *testing.T) {
+	received := make(chan Envelope, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var envelope Envelope
+		if err := json.Unmarshal(body, &envelope); err != nil {
+			http.Error(w, "FAIL: invalid JSON", http.StatusBadRequest)
+			return
+		}
+		received <- envelope
+		io.WriteString(w, "OK")
+	}))
+	defer server.Close()
+
+	u, err := New(conf.EDDNConf{TestMode: true, URL: server.URL}, t.TempDir(), filepath.Join(t.TempDir(), "outbox.json"), "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.sender.Start()
+	defer u.Close()
+
+	line := []byte(`{ "timestamp":"2025-01-01T00:00:00Z", "event":"FSSDiscoveryScan", "Progress":0.5, "BodyCount":14, "NonBodyCount":3, "SystemName":"Shinrarta Dezhra", "SystemAddress":3932277478106 }`)
+	u.HandleEvent("FSSDiscoveryScan", line, testState())
+	// Events EDDN has no schema for are ignored
+	u.HandleEvent("Music", []byte(`{ "event":"Music" }`), testState())
+
+	select {
+	case envelope := <-received:
+		if envelope.SchemaRef != "https://eddn.edcd.io/schemas/fssdiscoveryscan/1/test" {
+			t.Errorf("got schema %s", envelope.SchemaRef)
+		}
+		if envelope.Header.UploaderID != "Jameson" || envelope.Header.SoftwareName != SoftwareName || envelope.Header.GameVersion != "4.0.0.1904" {
+			t.Errorf("got header %+v", envelope.Header)
+		}
+		message := envelope.Message.(map[string]any)
+		if _, ok := message["Progress"]; ok {
+			t.Error("Progress was not removed")
+		}
+		if _, ok := message["StarPos"]; !ok {
+			t.Error("StarPos was not added")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("nothing uploaded")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for u.sender.Queue.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := u.sender.Queue.Len(); n != 0 {
+		t.Errorf("%d messages left in the outbox", n)
+	}
+	select {
+	case envelope := <-received:
+		t.Errorf("unexpected upload %+v", envelope)
+	default:
+	}
+}
@@ -0,0 +1,294 @@
+package eddn
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pellux-network/EDxDC/edreader"
+)
+
+const (
+	// FileMarket is written by the game when the commodity market is opened
+	FileMarket = "Market.json"
+	// FileNavRoute is written by the game when a route is plotted
+	FileNavRoute = "NavRoute.json"
+
+	categoryNonMarketable = "$MARKET_category_nonmarketable;"
+)
+
+// personalFields are removed from journal/1 messages, as required by the schema
+var personalFields = []string{
+	"ActiveFine", "BoostUsed", "CockpitBreach", "FuelLevel", "FuelUsed", "JumpDist",
+	"Latitude", "Longitude", "Wanted",
+}
+
+// codexPersonalFields are removed from codexentry/1 messages, as required by the schema
+var codexPersonalFields = []string{"IsNewEntry", "NewTraitsDiscovered"}
+
+// personalFactionFields are removed from every entry of Factions
+var personalFactionFields = []string{"HappiestSystem", "HomeSystem", "MyReputation", "SquadronFaction"}
+
+// decode parses a journal line, keeping numbers as written so large IDs are not rounded
+func decode(data []byte) (map[string]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var message map[string]any
+	if err := decoder.Decode(&message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// stripLocalised removes the localised copies of names, which depend on the player's language
+func stripLocalised(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if strings.HasSuffix(key, "_Localised") {
+				delete(v, key)
+				continue
+			}
+			stripLocalised(value)
+		}
+	case []any:
+		for _, value := range v {
+			stripLocalised(value)
+		}
+	}
+}
+
+// addGame adds the flags telling which game expansion the message comes from
+func addGame(message map[string]any, game edreader.Game) {
+	message["horizons"] = game.Horizons
+	message["odyssey"] = game.Odyssey
+}
+
+// addSystem adds the system name and coordinates, which EDDN requires but some events lack. It fails if
+// the event is not about the system the player is in, or its coordinates are not known yet.
+func addSystem(message map[string]any, nameKey string, state edreader.Journalstate) error {
+	address, ok := message["SystemAddress"].(json.Number)
+	if !ok {
+		return errors.New("event has no SystemAddress")
+	}
+	if address.String() != fmt.Sprint(state.Location.SystemAddress) {
+		return fmt.Errorf("event is for system %s, but the player is in %d", address, state.Location.SystemAddress)
+	}
+	if _, ok := message[nameKey]; !ok {
+		if state.StarSystem == "" {
+			return errors.New("system name not known")
+		}
+		message[nameKey] = state.StarSystem
+	}
+	if _, ok := message["StarPos"]; !ok {
+		if len(state.StarPos) != 3 {
+			return errors.New("system coordinates not known")
+		}
+		message["StarPos"] = state.StarPos
+	}
+	return nil
+}
+
+// journalMessage builds a journal/1 message from the event
+func journalMessage(line []byte, state edreader.Journalstate) (map[string]any, error) {
+	message, err := decode(line)
+	if err != nil {
+		return nil, err
+	}
+	stripLocalised(message)
+	for _, field := range personalFields {
+		delete(message, field)
+	}
+	if factions, ok := message["Factions"].([]any); ok {
+		for _, faction := range factions {
+			if faction, ok := faction.(map[string]any); ok {
+				for _, field := range personalFactionFields {
+					delete(faction, field)
+				}
+			}
+		}
+	}
+	if err := addSystem(message, "StarSystem", state); err != nil {
+		return nil, err
+	}
+	addGame(message, state.Game)
+	return message, nil
+}
+
+// fssDiscoveryScanMessage builds a fssdiscoveryscan/1 message from the FSSDiscoveryScan event
+func fssDiscoveryScanMessage(line []byte, state edreader.Journalstate) (map[string]any, error) {
+	message, err := decode(line)
+	if err != nil {
+		return nil, err
+	}
+	stripLocalised(message)
+	delete(message, "Progress")
+	if err := addSystem(message, "SystemName", state); err != nil {
+		return nil, err
+	}
+	addGame(message, state.Game)
+	return message, nil
+}
+
+// codexEntryMessage builds a codexentry/1 message from the CodexEntry event
+func codexEntryMessage(line []byte, state edreader.Journalstate) (map[string]any, error) {
+	message, err := decode(line)
+	if err != nil {
+		return nil, err
+	}
+	stripLocalised(message)
+	for _, field := range codexPersonalFields {
+		delete(message, field)
+	}
+	if err := addSystem(message, "System", state); err != nil {
+		return nil, err
+	}
+	addGame(message, state.Game)
+	return message, nil
+}
+
+// approachSettlementMessage builds an approachsettlement/1 message from the ApproachSettlement event
+func approachSettlementMessage(line []byte, state edreader.Journalstate) (map[string]any, error) {
+	message, err := decode(line)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := message["Latitude"]; !ok {
+		// Written when approaching some settlements without a position, which the schema does not allow
+		return nil, errors.New("settlement has no position")
+	}
+	stripLocalised(message)
+	if err := addSystem(message, "StarSystem", state); err != nil {
+		return nil, err
+	}
+	addGame(message, state.Game)
+	return message, nil
+}
+
+// navRouteMessage builds a navroute/1 message from the NavRoute.json written with the NavRoute event
+func navRouteMessage(journalFolder string, state edreader.Journalstate) (map[string]any, error) {
+	data, err := os.ReadFile(filepath.Join(journalFolder, FileNavRoute))
+	if err != nil {
+		return nil, err
+	}
+	message, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if route, _ := message["Route"].([]any); len(route) == 0 {
+		return nil, errors.New("route is empty")
+	}
+	stripLocalised(message)
+	addGame(message, state.Game)
+	return message, nil
+}
+
+// marketFile is the content of Market.json
+type marketFile struct {
+	Timestamp            string `json:"timestamp"`
+	MarketID             int64
+	StationName          string
+	StationType          string
+	CarrierDockingAccess string
+	StarSystem           string
+	Items                []marketItem
+}
+
+type marketItem struct {
+	Name          string
+	Category      string
+	BuyPrice      int64
+	SellPrice     int64
+	MeanPrice     int64
+	StockBracket  int64
+	DemandBracket int64
+	Stock         int64
+	Demand        int64
+}
+
+// CommodityMessage is a commodity/3 message
+type CommodityMessage struct {
+	SystemName           string      `json:"systemName"`
+	StationName          string      `json:"stationName"`
+	StationType          string      `json:"stationType,omitempty"`
+	CarrierDockingAccess string      `json:"carrierDockingAccess,omitempty"`
+	MarketID             int64       `json:"marketId"`
+	Horizons             bool        `json:"horizons"`
+	Odyssey              bool        `json:"odyssey"`
+	Timestamp            string      `json:"timestamp"`
+	Commodities          []Commodity `json:"commodities"`
+}
+
+// Commodity is a single entry of a commodity/3 message
+type Commodity struct {
+	Name          string `json:"name"`
+	MeanPrice     int64  `json:"meanPrice"`
+	BuyPrice      int64  `json:"buyPrice"`
+	Stock         int64  `json:"stock"`
+	StockBracket  int64  `json:"stockBracket"`
+	SellPrice     int64  `json:"sellPrice"`
+	Demand        int64  `json:"demand"`
+	DemandBracket int64  `json:"demandBracket"`
+}
+
+// commodityMessage builds a commodity/3 message from the Market.json written with the Market event
+func commodityMessage(journalFolder string, line []byte, state edreader.Journalstate) (*CommodityMessage, error) {
+	data, err := os.ReadFile(filepath.Join(journalFolder, FileMarket))
+	if err != nil {
+		return nil, err
+	}
+	var market marketFile
+	if err := json.Unmarshal(data, &market); err != nil {
+		return nil, err
+	}
+	var event marketFile
+	if err := json.Unmarshal(line, &event); err != nil {
+		return nil, err
+	}
+	if market.MarketID != event.MarketID {
+		return nil, fmt.Errorf("%s is for market %d, not %d", FileMarket, market.MarketID, event.MarketID)
+	}
+
+	message := &CommodityMessage{
+		SystemName:           market.StarSystem,
+		StationName:          market.StationName,
+		StationType:          market.StationType,
+		CarrierDockingAccess: market.CarrierDockingAccess,
+		MarketID:             market.MarketID,
+		Horizons:             state.Horizons,
+		Odyssey:              state.Odyssey,
+		Timestamp:            market.Timestamp,
+		Commodities:          []Commodity{},
+	}
+	for _, item := range market.Items {
+		if strings.EqualFold(item.Category, categoryNonMarketable) {
+			continue
+		}
+		message.Commodities = append(message.Commodities, Commodity{
+			Name:          commodityName(item.Name),
+			MeanPrice:     item.MeanPrice,
+			BuyPrice:      item.BuyPrice,
+			Stock:         item.Stock,
+			StockBracket:  item.StockBracket,
+			SellPrice:     item.SellPrice,
+			Demand:        item.Demand,
+			DemandBracket: item.DemandBracket,
+		})
+	}
+	if len(message.Commodities) == 0 {
+		return nil, errors.New("market has no commodities")
+	}
+	return message, nil
+}
+
+// commodityName turns the journal's symbol such as "$gold_name;" into the name EDDN uses
+func commodityName(symbol string) string {
+	name := strings.TrimPrefix(symbol, "$")
+	name = strings.TrimSuffix(name, ";")
+	name = strings.TrimSuffix(name, "_name")
+	return strings.ToLower(name)
+}
@@ -0,0 +1,302 @@
+package edreader
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pellux-network/EDxDC/journal"
+	"github.com/pellux-network/EDxDC/logging"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+ The journal file being written only has what happened since the game started. What was established
+ before, such as the ranks, the ship or the names of fleet carriers, is looked up in the older journal
+ files when the reader starts, newest first, until it is known for the commander. What was learned is
+ cached in a snapshot file, so that only the journals written since have to be read the next time.
+
+ Visited systems are not looked up: the reader keeps no list of them, only the current location, and
+ what is known about a system comes from the galaxy providers.
+*/
+
+// backfillVersion changes when the cache file can no longer be read by this version
+const backfillVersion = 1
+
+// history is what the older journals tell about a commander. Nil fields are not known yet.
+type history struct {
+	Name          string            `json:"name"`
+	Ranks         *Ranks            `json:"ranks,omitempty"`
+	Loadout       json.RawMessage   `json:"loadout,omitempty"`
+	CargoCapacity int               `json:"cargoCapacity,omitempty"`
+	Location      *Location         `json:"location,omitempty"`
+	Materials     map[string]int    `json:"materials,omitempty"`
+	Carriers      map[string]string `json:"carriers,omitempty"`
+}
+
+// complete tells if the history has all the state the backfill looks for
+func (h *history) complete() bool {
+	return h.Ranks != nil && h.Loadout != nil && h.Location != nil && h.Materials != nil
+}
+
+// fill sets what the history does not know yet from an older history
+func (h *history) fill(older *history) {
+	if h.Name == "" {
+		h.Name = older.Name
+	}
+	if h.Ranks == nil {
+		h.Ranks = older.Ranks
+	}
+	if h.Loadout == nil {
+		h.Loadout = older.Loadout
+		h.CargoCapacity = older.CargoCapacity
+	}
+	if h.Location == nil {
+		h.Location = older.Location
+	}
+	if h.Materials == nil {
+		h.Materials = older.Materials
+	}
+	for id, name := range older.Carriers {
+		if _, ok := h.Carriers[id]; !ok {
+			h.Carriers[id] = name
+		}
+	}
+}
+
+// backfillSnapshot is the cached result of the backfill
+type backfillSnapshot struct {
+	Version int `json:"version"`
+	// Files are the sizes of the journal files the commanders were learned from, by file name
+	Files      map[string]int64    `json:"files"`
+	Commanders map[string]*history `json:"commanders"`
+}
+
+// backfill learns the commanders from the journal files older than the current one, and sets up
+// their state before the current journal is read
+func (r *Reader) backfill(current string) {
+	start := time.Now()
+	snapshot := r.loadBackfill()
+	needed := fileCommander(current)
+
+	commanders := map[string]*history{}
+	sizes := map[string]int64{}
+	read := 0
+	merged := false
+	mergeSnapshot := func() {
+		if !merged {
+			mergeHistories(commanders, snapshot.Commanders)
+			merged = true
+		}
+	}
+	files, err := journal.Files(r.folder)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to list older journals")
+	}
+	for _, file := range files {
+		name := filepath.Base(file.Path)
+		if file.Path == current {
+			continue
+		}
+		info, err := os.Stat(file.Path)
+		if err != nil {
+			continue
+		}
+		if size, ok := snapshot.Files[name]; ok && size == info.Size() {
+			// Everything from here on is in the snapshot, unless an earlier backfill stopped early
+			sizes[name] = size
+			mergeSnapshot()
+			continue
+		}
+		if backfillComplete(commanders, needed) {
+			continue
+		}
+		histories, last, err := readHistory(file.Path)
+		if err != nil {
+			log.Warn().Err(err).Str("filename", logging.CleanPath(file.Path)).Msg("Failed to read older journal")
+			continue
+		}
+		read++
+		sizes[name] = info.Size()
+		mergeHistories(commanders, histories)
+		if needed == "" {
+			// The game did not tell the commander yet; it is likely the one who played last
+			needed = last
+		}
+	}
+	mergeSnapshot()
+
+	for fid, h := range commanders {
+		// The state snapshot knows the commanders better than the older journals
+		if _, known := r.commanders[fid]; !known {
+			r.restoreHistory(fid, h)
+		}
+	}
+	log.Info().Int("journals", read).Int("commanders", len(commanders)).Dur("took", time.Since(start)).Msg("Read older journals")
+	if read > 0 {
+		r.saveBackfill(backfillSnapshot{Version: backfillVersion, Files: sizes, Commanders: commanders})
+	}
+}
+
+// backfillComplete tells if the state of the commander is known. Without a commander, the newest
+// journals are read until one is found.
+func backfillComplete(commanders map[string]*history, fid string) bool {
+	h, ok := commanders[fid]
+	return ok && h.complete()
+}
+
+// mergeHistories fills the histories with what the older ones know
+func mergeHistories(histories, older map[string]*history) {
+	for fid, o := range older {
+		h, ok := histories[fid]
+		if !ok {
+			h = &history{Carriers: map[string]string{}}
+			histories[fid] = h
+		}
+		h.fill(o)
+	}
+}
+
+// restoreHistory sets up the state of a commander from the history
+func (r *Reader) restoreHistory(fid string, h *history) {
+	c := r.commander(fid)
+	c.Name = h.Name
+	c.state.Commander = h.Name
+	c.state.CommanderFID = fid
+	if h.Ranks != nil {
+		c.Ranks = *h.Ranks
+	}
+	c.loadout = h.Loadout
+	c.cargoCapacity = h.CargoCapacity
+	if h.Location != nil {
+		c.state.Location = *h.Location
+	}
+	c.Materials = maps.Clone(h.Materials)
+	maps.Copy(c.carriers, h.Carriers)
+}
+
+// readHistory reads what a journal file tells about the commanders playing in it. It returns the
+// Frontier ID of the last one as well.
+func readHistory(path string) (map[string]*history, string, error) {
+	histories := map[string]*history{}
+	states := map[string]*Journalstate{}
+	var h *history
+	var state *Journalstate
+	last := ""
+	_, err := journal.ReadLines(path, func(l journal.Line) {
+		e, err := journal.Decode(l.Data)
+		if err != nil {
+			return
+		}
+		if fid := sessionCommander(e); fid != "" {
+			if h = histories[fid]; h == nil {
+				h = &history{Carriers: map[string]string{}}
+				histories[fid] = h
+				states[fid] = &Journalstate{}
+			}
+			state = states[fid]
+			last = fid
+		}
+		if h == nil {
+			// Lines before the game told the commander
+			return
+		}
+		reducers.Dispatch(state, e)
+		switch e := e.(type) {
+		case *journal.Commander:
+			h.Name = e.Name
+		case *journal.LoadGame:
+			h.Name = e.Commander
+		case *journal.Rank:
+			ranks := e.Ranks
+			h.Ranks = &ranks
+		case *journal.Promotion:
+			if h.Ranks != nil {
+				applyPromotion(h.Ranks, e)
+			}
+		case *journal.Loadout:
+			h.Loadout = bytes.Clone(e.Line())
+			h.CargoCapacity = 0
+			if e.CargoCapacity != nil {
+				h.CargoCapacity = *e.CargoCapacity
+			}
+		case *journal.Materials:
+			h.Materials = materialCounts(e)
+		case *journal.MaterialCollected:
+			addMaterial(h.Materials, e.Name, e.Count)
+		case *journal.MaterialDiscarded:
+			addMaterial(h.Materials, e.Name, -e.Count)
+		case *journal.ReceiveText:
+			if e.Channel == "npc" && strings.HasSuffix(e.Message, "docking_granted;") {
+				if name, id := ExtractFleetCarrierNameID(e.From); id != "" {
+					h.Carriers[id] = name
+				}
+			}
+		}
+	})
+	for fid, state := range states {
+		if state.Location.SystemAddress != 0 {
+			location := state.Location
+			histories[fid].Location = &location
+		}
+	}
+	return histories, last, err
+}
+
+// fileCommander returns the Frontier ID of the commander the journal file is written for, if the
+// game told it yet
+func fileCommander(path string) string {
+	fid := ""
+	journal.ReadLines(path, func(l journal.Line) {
+		if fid != "" || !bytes.Contains(l.Data, []byte(`"FID"`)) {
+			return
+		}
+		if e, err := journal.Decode(l.Data); err == nil {
+			fid = sessionCommander(e)
+		}
+	})
+	return fid
+}
+
+func (r *Reader) loadBackfill() backfillSnapshot {
+	snapshot := backfillSnapshot{}
+	if r.backfillFile == "" {
+		return snapshot
+	}
+	data, err := os.ReadFile(r.backfillFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn().Err(err).Msg("Failed to read journal history cache")
+		}
+		return snapshot
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil || snapshot.Version != backfillVersion {
+		log.Info().Err(err).Msg("Ignoring outdated journal history cache")
+		return backfillSnapshot{}
+	}
+	return snapshot
+}
+
+// saveBackfill writes the cache file, replacing it only once it is written completely
+func (r *Reader) saveBackfill(snapshot backfillSnapshot) {
+	if r.backfillFile == "" {
+		return
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to encode journal history cache")
+		return
+	}
+	tmp := r.backfillFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Warn().Err(err).Msg("Failed to save journal history cache")
+		return
+	}
+	if err := os.Rename(tmp, r.backfillFile); err != nil {
+		log.Warn().Err(err).Msg("Failed to save journal history cache")
+	}
+}
@@ -0,0 +1,92 @@
+package edreader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Journal.2025-01-01T090000.01.log")
+	lines := `{ "timestamp":"2025-01-01T09:00:00Z", "event":"Fileheader", "part":1 }
+{ "timestamp":"2025-01-01T09:00:01Z", "event":"Commander", "FID":"F1", "Name":"Jameson" }
+{ "timestamp":"2025-01-01T09:00:02Z", "event":"Materials", "Raw":[ { "Name":"iron", "Count":10 } ], "Manufactured":[], "Encoded":[ { "Name":"shieldpatternanalysis", "Count":2 } ] }
+{ "timestamp":"2025-01-01T09:00:03Z", "event":"Rank", "Combat":3, "Trade":5, "Explore":7, "Soldier":0, "Exobiologist":1, "Empire":2, "Federation":4, "CQC":0 }
+{ "timestamp":"2025-01-01T09:00:04Z", "event":"Loadout", "Ship":"python", "CargoCapacity":64, "Modules":[] }
+{ "timestamp":"2025-01-01T09:00:05Z", "event":"Location", "StarSystem":"Sol", "SystemAddress":10477373803, "Body":"Earth", "BodyID":3, "BodyType":"Planet" }
+{ "timestamp":"2025-01-01T09:00:06Z", "event":"MaterialCollected", "Category":"Raw", "Name":"iron", "Count":3 }
+{ "timestamp":"2025-01-01T09:00:07Z", "event":"Promotion", "Explore":8 }
+{ "timestamp":"2025-01-01T09:00:08Z", "event":"ReceiveText", "From":"Jameson's Rest K7Q-1HT", "Message":"$STATION_docking_granted;", "Channel":"npc" }
+{ "timestamp":"2025-01-01T10:00:00Z", "event":"Commander", "FID":"F2", "Name":"Alt" }
+{ "timestamp":"2025-01-01T10:00:01Z", "event":"Rank", "Combat":1, "Trade":1, "Explore":1, "Soldier":0, "Exobiologist":0, "Empire":0, "Federation":0, "CQC":0 }
+`
+	if err := os.WriteFile(path, []byte(lines), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	histories, last, err := readHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last != "F2" {
+		t.Errorf("got last commander %q, wanted F2", last)
+	}
+	f1 := histories["F1"]
+	if f1 == nil {
+		t.Fatalf("got commanders %v, wanted F1", histories)
+	}
+	if f1.Name != "Jameson" {
+		t.Errorf("got name %q, wanted Jameson", f1.Name)
+	}
+	if f1.Ranks == nil || f1.Ranks.Combat != 3 || f1.Ranks.Explore != 8 {
+		t.Errorf("got ranks %+v, wanted the promotion to explorer rank 8", f1.Ranks)
+	}
+	if f1.Loadout == nil || f1.CargoCapacity != 64 {
+		t.Errorf("got cargo capacity %d, wanted the one of the loadout", f1.CargoCapacity)
+	}
+	if f1.Location == nil || f1.Location.StarSystem != "Sol" {
+		t.Errorf("got location %+v, wanted Sol", f1.Location)
+	}
+	if diff := cmp.Diff(map[string]int{"iron": 13, "shieldpatternanalysis": 2}, f1.Materials); diff != "" {
+		t.Errorf("materials differ (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]string{"K7Q-1HT": "Jameson's Rest"}, f1.Carriers); diff != "" {
+		t.Errorf("carriers differ (-want +got):\n%s", diff)
+	}
+	if !f1.complete() {
+		t.Error("the history of F1 is not complete")
+	}
+
+	// The second commander only played for a moment
+	f2 := histories["F2"]
+	if f2 == nil || f2.Ranks == nil || f2.Ranks.Combat != 1 || f2.Location != nil || f2.Materials != nil {
+		t.Errorf("got %+v for F2, wanted only the name and ranks", f2)
+	}
+}
+
+func TestBackfillComplete(t *testing.T) {
+	ranks := Ranks{}
+	location := Location{}
+	complete := &history{Ranks: &ranks, Loadout: []byte(`{}`), Location: &location, Materials: map[string]int{}}
+	partial := &history{Ranks: &ranks, Location: &location, Materials: map[string]int{}}
+	commanders := map[string]*history{"F1": complete, "F2": partial}
+
+	tests := []struct {
+		fid  string
+		want bool
+	}{
+		{"F1", true},
+		// The loadout is not known yet
+		{"F2", false},
+		{"F3", false},
+		// Without a commander, journals are read until one is found
+		{"", false},
+	}
+	for _, test := range tests {
+		if got := backfillComplete(commanders, test.fid); got != test.want {
+			t.Errorf("backfillComplete(%q) = %v, wanted %v", test.fid, got, test.want)
+		}
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"os"
 	"strings"
+	"sync"
 
+	"github.com/pellux-network/EDxDC/bus"
 	"github.com/rs/zerolog/log"
 )
 
@@ -29,7 +31,18 @@ type CargoLine struct {
 	NameLocalized string `json:"Name_Localised"`
 }
 
-func (cl CargoLine) displayname() string {
+// stolenCount returns the total number of stolen items in the cargo hold
+func (c Cargo) stolenCount() int {
+	stolen := 0
+	for _, line := range c.Inventory {
+		stolen += line.Stolen
+	}
+	return stolen
+}
+
+// DisplayName returns the human readable name of the commodity
+func (cl CargoLine) DisplayName() string {
+	namesOnce.Do(initNameMap)
 	name := cl.Name
 	displayName, ok := names[strings.ToLower(name)]
 	if ok {
@@ -39,29 +52,24 @@ func (cl CargoLine) displayname() string {
 }
 
 var (
-	names        map[string]string
-	currentCargo Cargo
+	names     map[string]string
+	namesOnce sync.Once
 )
 
-func init() {
-	log.Debug().Msg("Initializing cargo name map...")
-	initNameMap()
-}
-
-func handleCargoFile(file string) {
+func (r *Reader) handleCargoFile(file string) {
 	data, err := os.ReadFile(file)
 	if err != nil {
 		log.Debug().Str("file", file).Msg("No cargo file found")
-		currentCargo = Cargo{}
+		bus.Publish(r.events, CargoEvent{})
 		return
 	}
 	var cargo Cargo
 	if err := json.Unmarshal(data, &cargo); err != nil {
 		log.Error().Err(err).Str("file", file).Msg("Failed to unmarshal cargo file")
-		currentCargo = Cargo{}
+		bus.Publish(r.events, CargoEvent{})
 		return
 	}
-	currentCargo = cargo
+	bus.Publish(r.events, CargoEvent{Cargo: cargo})
 }
 
 func mapCommodities(data [][]string, symbolIdx, nameIdx int) {
@@ -74,6 +82,7 @@ func mapCommodities(data [][]string, symbolIdx, nameIdx int) {
 }
 
 func initNameMap() {
+	log.Debug().Msg("Initializing cargo name map...")
 	commodity := readCsvFile(commodityNameFile)
 	rareCommodity := readCsvFile(rareCommodityNameFile)
 
@@ -0,0 +1,257 @@
+package edreader
+
+import (
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/pellux-network/EDxDC/journal"
+	"github.com/rs/zerolog/log"
+)
+
+// Commander is what the reader knows about a commander besides the journal state
+type Commander struct {
+	Name     string    `json:"name"`
+	FID      string    `json:"fid"`
+	Ranks    Ranks     `json:"ranks"`
+	Missions []Mission `json:"missions"`
+	// Materials counts the materials by name. The game lists them when it loads; collected and
+	// discarded materials are counted in between.
+	Materials map[string]int `json:"materials"`
+}
+
+// Ranks are the ranks of a commander, as indexes into the rank names
+type Ranks = journal.Ranks
+
+// Mission is a mission the commander has accepted
+type Mission struct {
+	ID                 int64     `json:"id"`
+	Name               string    `json:"name"`
+	Faction            string    `json:"faction,omitempty"`
+	DestinationSystem  string    `json:"destinationSystem,omitempty"`
+	DestinationStation string    `json:"destinationStation,omitempty"`
+	Expiry             time.Time `json:"expiry,omitzero"`
+}
+
+// commander holds the state of one commander. Players with several accounts have the journals of all
+// of them in the same folder, so the reader keeps their state apart.
+type commander struct {
+	Commander
+	// state is the journal state, unless the commander is the active one, which uses Reader.state
+	state Journalstate
+	// loadout is the last Loadout event, describing the current ship
+	loadout       []byte
+	cargoCapacity int
+	carriers      map[string]string // map[fcID]fcName
+}
+
+// commander returns the state of the commander with the Frontier ID, creating it when first seen.
+// Journal lines read before the game told the commander are kept under the empty ID.
+func (r *Reader) commander(fid string) *commander {
+	c, ok := r.commanders[fid]
+	if !ok {
+		c = &commander{Commander: Commander{FID: fid}, carriers: map[string]string{}}
+		r.commanders[fid] = c
+	}
+	return c
+}
+
+// activeCommander returns the state of the commander shown on the pages
+func (r *Reader) activeCommander() *commander {
+	return r.commander(r.active)
+}
+
+// commanderView returns what is known about the active commander, for the view
+func (r *Reader) commanderView() Commander {
+	c := r.activeCommander().Commander
+	c.Missions = slices.Clone(c.Missions)
+	c.Materials = maps.Clone(c.Materials)
+	return c
+}
+
+// stateOf returns the journal state of the commander
+func (r *Reader) stateOf(fid string) Journalstate {
+	if fid == r.active {
+		return r.state
+	}
+	return r.commander(fid).state
+}
+
+// setState stores the journal state of the commander
+func (r *Reader) setState(fid string, state Journalstate) {
+	if fid == r.active {
+		r.state = state
+		return
+	}
+	r.commander(fid).state = state
+}
+
+// activate makes the commander the one shown on the pages, as a game session of it started
+func (r *Reader) activate(fid string) {
+	if fid == r.active {
+		return
+	}
+	previous := r.state
+	if r.active == "" {
+		// Nothing read before the first session is about a commander
+		delete(r.commanders, "")
+	} else {
+		r.activeCommander().state = previous
+	}
+	r.active = fid
+	r.state = r.commander(fid).state
+	// The splash screen is about EDxDC starting, not about the commander
+	r.state.ShowSplashScreen = previous.ShowSplashScreen
+	r.state.SplashScreenStartTime = previous.SplashScreenStartTime
+	log.Info().Str("commander", r.state.Commander).Msg("Switched to commander")
+}
+
+// startSession returns the journal state to go on reading with when a game session of the commander
+// starts. The lines before the session start, such as the file header, are about the same game client.
+func (r *Reader) startSession(state Journalstate, fid string) Journalstate {
+	if fid == state.CommanderFID {
+		return state
+	}
+	if state.CommanderFID != "" {
+		r.setState(state.CommanderFID, state)
+	} else {
+		delete(r.commanders, "")
+	}
+	if _, known := r.commanders[fid]; !known {
+		if state.CommanderFID == "" {
+			// First time the commander is seen: what was read so far is about them
+			return state
+		}
+		return Journalstate{Game: state.Game}
+	}
+	game := state.Game
+	state = r.stateOf(fid)
+	state.Game = game
+	return state
+}
+
+// sessionCommander returns the Frontier ID of the commander if the event starts a game session
+func sessionCommander(e journal.Event) string {
+	switch e := e.(type) {
+	case *journal.Commander:
+		return e.FID
+	case *journal.LoadGame:
+		return e.FID
+	}
+	return ""
+}
+
+func (r *Reader) eCommanderInfo(state Journalstate, _ journal.Event) {
+	c := r.commander(state.CommanderFID)
+	c.Name = state.Commander
+}
+
+func (r *Reader) eRank(state Journalstate, e *journal.Rank) {
+	r.commander(state.CommanderFID).Ranks = e.Ranks
+}
+
+func (r *Reader) ePromotion(state Journalstate, e *journal.Promotion) {
+	applyPromotion(&r.commander(state.CommanderFID).Ranks, e)
+}
+
+func applyPromotion(ranks *Ranks, e *journal.Promotion) {
+	for _, p := range []struct {
+		rank     *int
+		promoted *int
+	}{
+		{&ranks.Combat, e.Combat},
+		{&ranks.Trade, e.Trade},
+		{&ranks.Explore, e.Explore},
+		{&ranks.Soldier, e.Soldier},
+		{&ranks.Exobiologist, e.Exobiologist},
+		{&ranks.Empire, e.Empire},
+		{&ranks.Federation, e.Federation},
+		{&ranks.CQC, e.CQC},
+	} {
+		if p.promoted != nil {
+			*p.rank = *p.promoted
+		}
+	}
+}
+
+func (r *Reader) eMaterials(state Journalstate, e *journal.Materials) {
+	r.commander(state.CommanderFID).Materials = materialCounts(e)
+}
+
+func (r *Reader) eMaterialCollected(state Journalstate, e *journal.MaterialCollected) {
+	addMaterial(r.commander(state.CommanderFID).Materials, e.Name, e.Count)
+}
+
+func (r *Reader) eMaterialDiscarded(state Journalstate, e *journal.MaterialDiscarded) {
+	addMaterial(r.commander(state.CommanderFID).Materials, e.Name, -e.Count)
+}
+
+func materialCounts(e *journal.Materials) map[string]int {
+	materials := map[string]int{}
+	for _, list := range [][]journal.MaterialCount{e.Raw, e.Manufactured, e.Encoded} {
+		for _, m := range list {
+			materials[m.Name] += m.Count
+		}
+	}
+	return materials
+}
+
+// addMaterial counts collected or discarded materials. Before the game listed the materials, the
+// counts are not known.
+func addMaterial(materials map[string]int, name string, count int) {
+	if materials == nil {
+		return
+	}
+	materials[name] = max(materials[name]+count, 0)
+	if materials[name] == 0 {
+		delete(materials, name)
+	}
+}
+
+// eMissions replaces the missions with the active ones the game lists when it loads, keeping what
+// is known about them from when they were accepted
+func (r *Reader) eMissions(state Journalstate, e *journal.Missions) {
+	c := r.commander(state.CommanderFID)
+	missions := make([]Mission, 0, len(e.Active))
+	for _, active := range e.Active {
+		i := slices.IndexFunc(c.Missions, func(m Mission) bool { return m.ID == active.MissionID })
+		if i >= 0 {
+			missions = append(missions, c.Missions[i])
+			continue
+		}
+		missions = append(missions, Mission{
+			ID:     active.MissionID,
+			Name:   active.Name,
+			Expiry: e.Timestamp.Add(time.Duration(active.Expires) * time.Second),
+		})
+	}
+	c.Missions = missions
+}
+
+func (r *Reader) eMissionAccepted(state Journalstate, e *journal.MissionAccepted) {
+	c := r.commander(state.CommanderFID)
+	mission := Mission{
+		ID:                 e.MissionID,
+		Name:               e.LocalisedName,
+		Faction:            e.Faction,
+		DestinationSystem:  e.DestinationSystem,
+		DestinationStation: e.DestinationStation,
+	}
+	if mission.Name == "" {
+		mission.Name = e.Name
+	}
+	if e.Expiry != nil {
+		mission.Expiry = *e.Expiry
+	}
+	c.Missions = append(removeMission(c.Missions, e.MissionID), mission)
+}
+
+// endMission removes a mission that was completed, failed or abandoned
+func (r *Reader) endMission(state Journalstate, id int64) {
+	c := r.commander(state.CommanderFID)
+	c.Missions = removeMission(c.Missions, id)
+}
+
+func removeMission(missions []Mission, id int64) []Mission {
+	return slices.DeleteFunc(missions, func(m Mission) bool { return m.ID == id })
+}
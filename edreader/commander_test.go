@@ -0,0 +1,137 @@
+package edreader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pellux-network/EDxDC/journal"
+)
+
+// journalLine is a line written to a journal file of the test folder
+type journalLine struct {
+	file string
+	line string
+}
+
+func TestCommanderSessions(t *testing.T) {
+	const otherJournal = "Journal.2025-01-01T110000.01.log"
+	var (
+		alt      = journalLine{testJournal, `{ "timestamp":"2025-01-01T10:01:00Z", "event":"Commander", "FID":"F2", "Name":"Alt" }`}
+		altJump  = journalLine{testJournal, `{ "timestamp":"2025-01-01T10:01:01Z", "event":"Location", "StarSystem":"Achenar", "SystemAddress":164098653 }`}
+		bench    = journalLine{testJournal, `{ "timestamp":"2025-01-01T10:02:00Z", "event":"LoadGame", "FID":"F1", "Commander":"Bench" }`}
+		header   = journalLine{otherJournal, `{ "timestamp":"2025-01-01T11:00:00Z", "event":"Fileheader", "part":1, "gameversion":"4.1.0.100", "build":"r1" }`}
+		altOther = journalLine{otherJournal, `{ "timestamp":"2025-01-01T11:00:01Z", "event":"Commander", "FID":"F2", "Name":"Alt" }`}
+		altMove  = journalLine{otherJournal, `{ "timestamp":"2025-01-01T11:00:02Z", "event":"Location", "StarSystem":"Lave", "SystemAddress":570,  "Body":"Lave", "BodyID":0, "BodyType":"Star" }`}
+	)
+	tests := []struct {
+		name   string
+		lines  []journalLine
+		active string
+		// systems are the systems the commanders are in, by Frontier ID
+		systems map[string]string
+	}{
+		{
+			name:    "same commander",
+			lines:   []journalLine{bench},
+			active:  "Bench",
+			systems: map[string]string{"F1": "Sol"},
+		},
+		{
+			name:    "other commander",
+			lines:   []journalLine{alt, altJump},
+			active:  "Alt",
+			systems: map[string]string{"F1": "Sol", "F2": "Achenar"},
+		},
+		{
+			name:    "back to the first commander",
+			lines:   []journalLine{alt, altJump, bench},
+			active:  "Bench",
+			systems: map[string]string{"F1": "Sol", "F2": "Achenar"},
+		},
+		{
+			name:    "other game client",
+			lines:   []journalLine{header, altOther, altMove},
+			active:  "Alt",
+			systems: map[string]string{"F1": "Sol", "F2": "Lave"},
+		},
+		{
+			// Only a new session switches the pages to its commander
+			name:    "first game client going on",
+			lines:   []journalLine{header, altOther, altMove, {testJournal, `{ "timestamp":"2025-01-01T11:00:03Z", "event":"Location", "StarSystem":"Alioth", "SystemAddress":1109989017963 }`}},
+			active:  "Alt",
+			systems: map[string]string{"F1": "Alioth", "F2": "Lave"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newTestReader(t)
+			for _, l := range test.lines {
+				path := filepath.Join(r.folder, l.file)
+				f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+				f.WriteString(l.line + "\n")
+				f.Close()
+				r.update(journal.FolderChange{Files: map[string]bool{l.file: true}}, false)
+			}
+
+			if r.state.Commander != test.active {
+				t.Errorf("got commander %q, wanted %q", r.state.Commander, test.active)
+			}
+			systems := map[string]string{}
+			for fid := range r.commanders {
+				systems[fid] = r.stateOf(fid).StarSystem
+			}
+			if diff := cmp.Diff(test.systems, systems); diff != "" {
+				t.Errorf("systems of the commanders differ (-want +got):\n%s", diff)
+			}
+			// Each game client goes on with the commander playing in it
+			for file, fid := range r.fileCommanders {
+				if state := r.stateOf(fid); filepath.Base(file) == otherJournal && state.GameVersion != "4.1.0.100" {
+					t.Errorf("got game version %q for %s, wanted the one of its game client", state.GameVersion, fid)
+				}
+			}
+		})
+	}
+}
+
+func TestStartSession(t *testing.T) {
+	r := &Reader{commanders: map[string]*commander{}}
+	game := Game{GameVersion: "4.1.0.100"}
+
+	// What was read before the first commander is known is about them
+	state := Journalstate{Game: game}
+	state.StarSystem = "Sol"
+	state = r.startSession(state, "F1")
+	if state.StarSystem != "Sol" {
+		t.Errorf("got %q for the first commander, wanted the state read before", state.StarSystem)
+	}
+	state.CommanderFID = "F1"
+	r.activate("F1")
+	r.state = state
+
+	// A new commander starts from nothing but the game client
+	state = r.startSession(state, "F2")
+	if state.StarSystem != "" || state.Game != game {
+		t.Errorf("got %q in game %+v for a new commander, wanted nothing but the game", state.StarSystem, state.Game)
+	}
+	if r.stateOf("F1").StarSystem != "Sol" {
+		t.Errorf("the first commander is in %q, wanted Sol", r.stateOf("F1").StarSystem)
+	}
+	state.CommanderFID = "F2"
+	state.StarSystem = "Achenar"
+	r.setState("F2", state)
+
+	// Known commanders go on from their state, in the game client of the session
+	state.Game = Game{GameVersion: "4.2.0.1"}
+	state = r.startSession(state, "F1")
+	if state.StarSystem != "Sol" || state.GameVersion != "4.2.0.1" {
+		t.Errorf("got %q in game %q for the first commander, wanted Sol in 4.2.0.1", state.StarSystem, state.GameVersion)
+	}
+	if r.stateOf("F2").StarSystem != "Achenar" {
+		t.Errorf("the second commander is in %q, wanted Achenar", r.stateOf("F2").StarSystem)
+	}
+}
@@ -0,0 +1,97 @@
+package edreader
+
+import (
+	"maps"
+	"reflect"
+)
+
+// Dependency is a part of the state that pages are rendered from
+type Dependency uint
+
+const (
+	// DependsLocation is where the commander is, from the journal, and the fleet carriers known there
+	DependsLocation Dependency = 1 << iota
+	// DependsDestination is the target selected in the game or for the FSD, and the arrival there
+	DependsDestination
+	// DependsCargo is the cargo hold and its capacity
+	DependsCargo
+	// DependsStatus is the status of the ship from Status.json
+	DependsStatus
+	// DependsSystem is what the galaxy providers, such as EDSM, tell about the systems
+	DependsSystem
+	// DependsRoute is the plotted route being followed
+	DependsRoute
+	// DependsJournal is anything else read from the journal, such as the ranks and missions
+	DependsJournal
+	// DependsAll is everything, for pages that may show anything
+	DependsAll = ^Dependency(0)
+)
+
+// depends returns the state the page is rendered from
+func (d PageDef) depends() Dependency {
+	if d.Depends == 0 {
+		return DependsAll
+	}
+	return d.Depends
+}
+
+// pageState is the state the pages are rendered from, taken before and after it may change to tell
+// which pages need to be rendered again
+type pageState struct {
+	active        string
+	splash        bool
+	location      Location
+	carriers      map[string]string
+	destination   Destination
+	target        EDSMTarget
+	arrived       bool
+	cargo         Cargo
+	modules       ModulesInfo
+	cargoCapacity int
+	status        Status
+	galaxy        uint64
+}
+
+func (r *Reader) pageState() pageState {
+	c := r.activeCommander()
+	return pageState{
+		active:        r.active,
+		splash:        r.state.ShowSplashScreen,
+		location:      r.state.Location,
+		carriers:      maps.Clone(c.carriers),
+		destination:   r.state.Destination,
+		target:        r.state.EDSMTarget,
+		arrived:       r.state.ArrivedAtFSDTarget,
+		cargo:         r.cargo,
+		modules:       r.modules,
+		cargoCapacity: c.cargoCapacity,
+		status:        r.status,
+		galaxy:        r.journalGalaxy.Version(),
+	}
+}
+
+// changed returns the parts of the state that differ from the state taken later
+func (s pageState) changed(later pageState) Dependency {
+	if s.active != later.active {
+		// Another commander may have other pages
+		return DependsAll
+	}
+	var d Dependency
+	if !reflect.DeepEqual(s.location, later.location) || !maps.Equal(s.carriers, later.carriers) {
+		d |= DependsLocation
+	}
+	// The splash screen is shown on the destination page
+	if s.destination != later.destination || s.target != later.target || s.arrived != later.arrived || s.splash != later.splash {
+		d |= DependsDestination
+	}
+	if !reflect.DeepEqual(s.cargo, later.cargo) || !reflect.DeepEqual(s.modules, later.modules) || s.cargoCapacity != later.cargoCapacity {
+		d |= DependsCargo
+	}
+	if !reflect.DeepEqual(s.status, later.status) {
+		d |= DependsStatus
+	}
+	if s.galaxy != later.galaxy {
+		d |= DependsSystem
+	}
+	return d
+}
@@ -0,0 +1,135 @@
+package edreader
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pellux-network/EDxDC/journal"
+	"github.com/pellux-network/EDxDC/mfd"
+)
+
+func TestPageStateChanged(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(s *pageState)
+		want   Dependency
+	}{
+		{"nothing", func(s *pageState) {}, 0},
+		{"commander", func(s *pageState) { s.active = "F2" }, DependsAll},
+		{"location", func(s *pageState) { s.location.StarSystem = "Achenar" }, DependsLocation},
+		{"carrier", func(s *pageState) { s.carriers["K7Q-1HT"] = "Achenar" }, DependsLocation},
+		{"destination", func(s *pageState) { s.destination.Name = "Achenar" }, DependsDestination},
+		{"arrival", func(s *pageState) { s.arrived = true }, DependsDestination},
+		{"splash screen", func(s *pageState) { s.splash = false }, DependsDestination},
+		{"cargo", func(s *pageState) { s.cargo.Inventory[0].Count++ }, DependsCargo},
+		{"cargo capacity", func(s *pageState) { s.cargoCapacity = 64 }, DependsCargo},
+		{"status", func(s *pageState) { s.status.Flags = 0 }, DependsStatus},
+		{"galaxy", func(s *pageState) { s.galaxy++ }, DependsSystem},
+		{"location and status", func(s *pageState) {
+			s.location.StarSystem = "Achenar"
+			s.status.Flags = 0
+		}, DependsLocation | DependsStatus},
+	}
+	state := func() pageState {
+		s := pageState{
+			active:        "F1",
+			splash:        true,
+			carriers:      map[string]string{},
+			cargo:         Cargo{Count: 2, Inventory: []CargoLine{{Name: "gold", Count: 2}}},
+			cargoCapacity: 32,
+			status:        Status{Flags: 16842765},
+		}
+		s.location.StarSystem = "Sol"
+		return s
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			later := state()
+			test.change(&later)
+			if got := state().changed(later); got != test.want {
+				t.Errorf("got %b, wanted %b", got, test.want)
+			}
+		})
+	}
+}
+
+// renderedPages updates the reader and returns the keys of the pages it rendered
+func renderedPages(t *testing.T, r *Reader, update func()) []PageKey {
+	t.Helper()
+	var keys []PageKey
+	pages := slices.Clone(r.pages)
+	defer copy(r.pages, pages)
+	for i, pageDef := range pages {
+		r.pages[i].Render = func(r *Reader, page *mfd.Page, state Journalstate) {
+			keys = append(keys, pageDef.Key)
+			pageDef.Render(r, page, state)
+		}
+	}
+	rendered := r.Metrics().PagesRendered
+	update()
+	if n := r.Metrics().PagesRendered - rendered; n != uint64(len(keys)) {
+		t.Errorf("counted %d pages rendered, wanted %d", n, len(keys))
+	}
+	return keys
+}
+
+func TestRenderMFDCache(t *testing.T) {
+	r := newTestReader(t)
+	if len(r.pageCache) != 3 {
+		t.Fatalf("got %d pages rendered by the first update, wanted 3", len(r.pageCache))
+	}
+
+	keys := renderedPages(t, r, func() {
+		status := `{ "Flags":16842761, "Fuel":{ "FuelMain":16.0 }, "Destination":{ "System":10477373803, "Body":3, "Name":"Earth" } }`
+		if err := os.WriteFile(filepath.Join(r.folder, FileStatus), []byte(status), 0644); err != nil {
+			t.Fatal(err)
+		}
+		r.update(journal.FolderChange{Files: map[string]bool{FileStatus: true}}, false)
+	})
+	if len(keys) != 0 {
+		t.Errorf("Status.json rendered pages %v again, which do not show it", keys)
+	}
+
+	keys = renderedPages(t, r, func() {
+		appendJournal(t, r, `{ "timestamp":"2025-01-01T10:00:02Z", "event":"FSDTarget", "Name":"Alpha Centauri", "SystemAddress":3932277478106 }`)
+	})
+	if diff := cmp.Diff([]PageKey{"destination"}, keys); diff != "" {
+		t.Errorf("FSDTarget rendered the pages (-want +got):\n%s", diff)
+	}
+
+	keys = renderedPages(t, r, func() {
+		appendJournal(t, r, `{ "timestamp":"2025-01-01T10:00:03Z", "event":"Music", "MusicTrack":"Exploration" }`)
+	})
+	if len(keys) != 0 {
+		t.Errorf("an event no page shows rendered pages %v again", keys)
+	}
+}
+
+func TestPreviewPagesCache(t *testing.T) {
+	r := newTestReader(t)
+	var previews []PagePreview
+	keys := renderedPages(t, r, func() { previews = r.PreviewPages() })
+	if diff := cmp.Diff([]PageKey{"route"}, keys); diff != "" {
+		t.Errorf("the first preview rendered the pages (-want +got):\n%s", diff)
+	}
+	if len(previews) != len(r.pages) {
+		t.Errorf("got %d pages previewed, wanted all %d", len(previews), len(r.pages))
+	}
+
+	keys = renderedPages(t, r, func() { r.PreviewPages() })
+	if len(keys) != 0 {
+		t.Errorf("the preview rendered pages %v again, which did not change", keys)
+	}
+
+	// The route page is not shown, but rendered again for the next preview once the location changed
+	keys = renderedPages(t, r, func() {
+		appendJournal(t, r, `{ "timestamp":"2025-01-01T10:01:00Z", "event":"FSDJump", "StarSystem":"Alpha Centauri", "SystemAddress":3932277478106, "StarPos":[3.03,-0.09,3.16] }`)
+		r.PreviewPages()
+	})
+	if !slices.Contains(keys, "route") || slices.Contains(keys[:len(keys)-1], "route") {
+		t.Errorf("the jump rendered the pages %v, wanted the route page rendered once, by the preview", keys)
+	}
+}
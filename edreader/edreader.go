@@ -1,15 +1,18 @@
 package edreader
 
 import (
-	"os"
+	"context"
+	"fmt"
 	"path/filepath"
 	"sync"
 	"time"
 
-	"github.com/fsnotify/fsnotify"
 	"github.com/google/go-cmp/cmp"
+	"github.com/pellux-network/EDxDC/bus"
 	"github.com/pellux-network/EDxDC/conf"
-	"github.com/pellux-network/EDxDC/edsm"
+	"github.com/pellux-network/EDxDC/galaxy"
+	"github.com/pellux-network/EDxDC/galaxydb"
+	"github.com/pellux-network/EDxDC/journal"
 	"github.com/pellux-network/EDxDC/logging"
 	"github.com/pellux-network/EDxDC/mfd"
 	"github.com/rs/zerolog/log"
@@ -28,7 +31,12 @@ const (
 type PageDef struct {
 	Key         PageKey
 	DisplayName string
-	Render      func(*mfd.Page, Journalstate)
+	// Depends is the state the page is rendered from. The page is rendered again only when that
+	// changed; pages that do not tell are rendered whenever anything changed.
+	Depends Dependency
+	Render  func(*Reader, *mfd.Page, Journalstate)
+	// Select is called when the soft button is clicked while this page is shown. It may be nil.
+	Select func(*Reader, *Journalstate)
 }
 
 // Registry of all possible pages
@@ -36,149 +44,413 @@ var PageRegistry = []PageDef{
 	{
 		Key:         PageDestination,
 		DisplayName: "Destination",
-		Render:      RenderDestinationPage, // This function contains the dynamic logic
+		Depends:     DependsDestination | DependsLocation | DependsSystem,
+		Render:      (*Reader).RenderDestinationPage, // This function contains the dynamic logic
+		Select:      (*Reader).SelectDestinationPage,
 	},
 	{
 		Key:         PageLocation,
 		DisplayName: "Location",
-		Render:      RenderLocationPage,
+		Depends:     DependsLocation | DependsSystem,
+		Render:      (*Reader).RenderLocationPage,
+		Select:      (*Reader).SelectLocationPage,
 	},
 	{
 		Key:         PageCargo,
 		DisplayName: "Cargo",
-		Render:      RenderCargoPage,
+		Depends:     DependsCargo,
+		Render:      (*Reader).RenderCargoPage,
+		Select:      (*Reader).SelectCargoPage,
+	},
+	{
+		Key:         PageRoute,
+		DisplayName: "Route",
+		Depends:     DependsRoute | DependsLocation,
+		Render:      (*Reader).RenderRoutePage,
+		Select:      (*Reader).SelectRoutePage,
 	},
 }
 
-// Mfd is the MFD display structure to be used by this module.
-var (
-	Mfd     mfd.Display
-	MfdLock = sync.RWMutex{}
-	PrevMfd mfd.Display
-	watcher *fsnotify.Watcher
-	stopCh  chan struct{}
-)
+// DefaultDebounce is how long changes of the journal folder wait to be read, if no other time is
+// configured
+const DefaultDebounce = 50 * time.Millisecond
 
-// Start starts the Elite Dangerous journal reader routine using fsnotify
-func Start(cfg conf.Conf) {
-	log.Info().Msg("Starting journal listener")
-	journalfolder := cfg.ExpandJournalFolderPath()
-	log.Debug().Str("journalfolder", logging.CleanPath(journalfolder)).Msg("Looking for journal files")
+// Options set up a reader besides its config
+type Options struct {
+	// Display shows the rendered pages when they change, such as mfd.Write. It may be nil.
+	Display func(mfd.Display)
+	// RouteFile keeps the plotted route between runs, if set
+	RouteFile string
+	// GalaxyDatabase is the folder of the offline galaxy database, if set
+	GalaxyDatabase string
+	// BackfillFile caches what was learned from the older journals between runs, if set
+	BackfillFile string
+	// SnapshotFile keeps the state of the reader between runs, if set, so that the journals are read
+	// on from where the reader stopped
+	SnapshotFile string
+}
 
-	// Set the first enabled page key for splash logic
-	SetFirstEnabledPageKey(cfg.Pages)
+// Reader reads the journal folder of a commander and renders the MFD pages from it. Everything it
+// knows about the game is kept in the reader, so several readers can run side by side.
+type Reader struct {
+	cfg     conf.Conf
+	folder  string
+	pages   []PageDef
+	display func(mfd.Display)
 
-	updateMFD(journalfolder, cfg)
+	// events passes what the reader reads on to the pages, the MFD and the listeners
+	events        *bus.Bus
+	journalEvents *journal.Dispatcher[Journalstate]
 
-	var err error
-	watcher, err = fsnotify.NewWatcher()
-	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to create file watcher")
+	// journalGalaxy learns about the bodies and stations from the journal lines read
+	journalGalaxy *galaxy.Journal
+	// galaxy is where the pages get the system, body and station information from
+	galaxy galaxy.Provider
+	// offlineGalaxy is the offline galaxy database, if it was opened
+	offlineGalaxy *galaxydb.DB
+
+	// The fields below are only used from the reader routine
+
+	// tailer reads the lines appended to the journal files
+	tailer *journal.Tailer
+	// fileCommanders is the Frontier ID of the commander each journal file is written for, once known
+	fileCommanders map[string]string
+	// commanders holds the state of every commander seen, by Frontier ID. The pages show the active
+	// one, whose journal state is kept in state.
+	commanders map[string]*commander
+	active     string
+	state      Journalstate
+	statusSize int64 // for status file change detection
+	// firstPageKey is the first enabled page, which the splash screen waits for
+	firstPageKey string
+	// route is the route being followed, or nil
+	route     *plottedRoute
+	routeFile string
+	// backfillFile caches what was learned from the older journals
+	backfillFile string
+	// snapshotFile keeps the state between runs, savedSnapshot is the state as last saved to it
+	snapshotFile  string
+	savedSnapshot snapshot
+
+	// The data shown on the pages besides the journal state, kept up to date from the bus
+	cargo   Cargo
+	modules ModulesInfo
+	status  Status
+
+	// bodySortOrder is the current sort order of the valuable bodies, cycled with the soft button
+	bodySortOrder BodySortOrder
+	// cargoStolenOnly limits the cargo page to stolen goods, toggled with the soft button
+	cargoStolenOnly bool
+
+	// prefetchSystem is the system the stations were last prefetched for, used by prefetchStations
+	prefetchSystem int64
+
+	viewLock      sync.RWMutex
+	publishedView View
+	// pageCache holds each page as last rendered, for the pages whose state did not change
+	pageCache map[PageKey]mfd.Page
+	metrics   metrics
+
+	// rendered holds the pages as last rendered, shown holds them as last sent to the display
+	displayLock sync.RWMutex
+	rendered    mfd.Display
+	shown       mfd.Display
+
+	// selectCh hands soft button clicks from the device callback over to the reader routine
+	selectCh chan uint32
+	// requestCh runs functions that need access to the reader state on the reader routine
+	requestCh chan func()
+	started   chan struct{}
+	closing   chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// New creates a reader for the journal folder of the config. It reads nothing before Run is called.
+func New(cfg conf.Conf, opts Options) *Reader {
+	r := &Reader{
+		cfg:            cfg,
+		folder:         cfg.ExpandJournalFolderPath(),
+		pages:          append([]PageDef{}, PageRegistry...),
+		display:        opts.Display,
+		backfillFile:   opts.BackfillFile,
+		snapshotFile:   opts.SnapshotFile,
+		events:         bus.New(),
+		journalEvents:  journal.NewDispatcher[Journalstate](),
+		journalGalaxy:  galaxy.NewJournal(),
+		fileCommanders: map[string]string{},
+		commanders:     map[string]*commander{},
+		pageCache:      map[PageKey]mfd.Page{},
+		selectCh:       make(chan uint32, 1),
+		requestCh:      make(chan func()),
+		started:        make(chan struct{}),
+		closing:        make(chan struct{}),
+		stopped:        make(chan struct{}),
 	}
-	stopCh = make(chan struct{})
+	r.tailer = journal.NewTailer(r.folder)
+	r.state.ShowSplashScreen = true
+	r.state.SplashScreenStartTime = time.Now()
+	r.setFirstPageKey()
+	r.galaxy, r.offlineGalaxy = newGalaxy(cfg.Galaxy, r.journalGalaxy, opts.GalaxyDatabase)
+	if opts.RouteFile != "" {
+		r.loadRoute(opts.RouteFile)
+	}
+	r.subscribe()
+	return r
+}
+
+// Run reads the journal folder, and keeps reading as the game writes to it, until the context is
+// done or the reader is closed. It may only be called once.
+func (r *Reader) Run(ctx context.Context) error {
+	log.Info().Msg("Starting journal listener")
+	log.Debug().Str("journalfolder", logging.CleanPath(r.folder)).Msg("Looking for journal files")
 
-	// Watch the folder for new/changed files
-	err = watcher.Add(journalfolder)
+	debounceTime := r.cfg.Watcher.Debounce
+	if debounceTime <= 0 {
+		debounceTime = DefaultDebounce
+	}
+	watcher, err := journal.Watch(r.folder, journal.WatchOptions{
+		Mode:     r.cfg.Watcher.Mode,
+		Interval: r.cfg.Watcher.Interval,
+		Jitter:   r.cfg.Watcher.Jitter,
+	})
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to add watcher")
-	}
-
-	// Prefetch stations for the initial system (if known)
-	if lastJournalState.Location.SystemAddress != 0 {
-		log.Debug().Int64("systemAddress", lastJournalState.Location.SystemAddress).Msg("Prefetching stations for initial system")
-		PrefetchStations(lastJournalState.Location.SystemAddress)
-	}
-
-	go func() {
-		defer watcher.Close()
-		for {
-			select {
-			case event := <-watcher.Events:
-				log.Trace().Str("event", event.String()).Msg("File system event received")
-				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
-					updateMFD(journalfolder, cfg)
-				}
-			case err := <-watcher.Errors:
-				log.Warn().Err(err).Msg("Watcher error")
-			case <-stopCh:
-				log.Info().Msg("Journal watcher stopped")
-				return
+		return fmt.Errorf("watching journal folder: %w", err)
+	}
+	defer watcher.Close()
+	log.Info().Str("mode", watcher.Mode()).Msg("Watching journal folder")
+
+	close(r.started)
+	defer close(r.stopped)
+	r.restoreSnapshot()
+	r.backfill(r.tailer.Newest())
+	r.update(journal.FolderChange{All: true}, true)
+
+	// debounce is set while changes wait to be read, so that the files the game writes at about the
+	// same time, such as the journal and Status.json, are read in one update
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-watcher.Changes():
+			log.Trace().Msg("Journal folder changed")
+			r.metrics.changed()
+			if debounce == nil {
+				debounce = time.After(debounceTime)
 			}
+		case <-debounce:
+			debounce = nil
+			r.update(watcher.Changed(), false)
+		case page := <-r.selectCh:
+			r.handleSelect(page)
+		case fn := <-r.requestCh:
+			fn()
+		case err := <-watcher.Errors():
+			log.Warn().Err(err).Msg("Watcher error")
+		case <-ctx.Done():
+			log.Info().Msg("Journal watcher stopped")
+			return nil
+		case <-r.closing:
+			log.Info().Msg("Journal watcher stopped")
+			return nil
 		}
-	}()
+	}
 }
 
-func updateMFD(journalfolder string, cfg conf.Conf) {
-	journalFile := findJournalFile(journalfolder)
-	log.Debug().Str("journalFile", logging.CleanPath(journalFile)).Msg("Updating MFD")
-	handleJournalFile(journalFile)
-	handleStatusFile(filepath.Join(journalfolder, "Status.json"))
-	handleModulesInfoFile(filepath.Join(journalfolder, FileModulesInfo))
+// Close stops the reader and lets the handlers of the published messages finish
+func (r *Reader) Close() error {
+	r.closeOnce.Do(func() { close(r.closing) })
+	select {
+	case <-r.started:
+		select {
+		case <-r.stopped:
+		case <-time.After(stopTimeout):
+			log.Warn().Msg("Journal watcher did not stop in time")
+		}
+	default:
+	}
+	r.events.Close(stopTimeout)
+	if r.offlineGalaxy != nil {
+		return r.offlineGalaxy.Close()
+	}
+	return nil
+}
 
-	// Update in-memory cargo before rendering pages
-	handleCargoFile(filepath.Join(journalfolder, FileCargo))
+// update reads the files that changed in the journal folder and renders the pages showing what
+// changed. Replay is set for the first update, which reads what was written before the reader ran.
+func (r *Reader) update(changed journal.FolderChange, replay bool) {
+	log.Debug().Bool("all", changed.All).Int("files", len(changed.Files)).Msg("Updating MFD")
+	r.metrics.updated()
+	var dirty Dependency
+	if !changed.Journals() && !changed.Has(FileStatus) && !changed.Has(FileModulesInfo) && !changed.Has(FileCargo) {
+		// None of the files the pages are rendered from
+		return
+	}
+	before := r.pageState()
 
-	// Build enabled pages
-	var enabledPages []mfd.Page
-	for _, pageDef := range PageRegistry {
-		if cfg.Pages[string(pageDef.Key)] {
-			page := mfd.NewPage()
-			pageDef.Render(&page, lastJournalState)
-			enabledPages = append(enabledPages, page)
+	if changed.Journals() {
+		// With several game clients running, each writes its own journal file
+		for _, filename := range r.tailer.Files() {
+			if changed.Has(filepath.Base(filename)) && r.handleJournalFile(filename, replay) {
+				dirty |= DependsJournal
+			}
 		}
 	}
-	MfdLock.Lock()
-	Mfd = mfd.Display{Pages: enabledPages}
-	MfdLock.Unlock()
+	if changed.Has(FileStatus) {
+		r.handleStatusFile(filepath.Join(r.folder, FileStatus))
+	}
+	if changed.Has(FileModulesInfo) {
+		r.handleModulesInfoFile(filepath.Join(r.folder, FileModulesInfo))
+	}
+	if changed.Has(FileCargo) {
+		r.handleCargoFile(filepath.Join(r.folder, FileCargo))
+	}
 
-	swapMfd()
+	dirty |= before.changed(r.pageState())
+	if replay {
+		dirty = DependsAll
+	}
+	if dirty != 0 {
+		r.renderMFD(dirty)
+	}
+	r.saveSnapshot()
 }
 
-// Stop closes the watcher again
-func Stop() {
-	if stopCh != nil {
-		close(stopCh)
+// renderMFD renders the enabled pages that depend on the changed state, takes the others from the
+// cache, and sends them to the device if anything changed
+func (r *Reader) renderMFD(changed Dependency) {
+	// The pages not shown are rendered again when they are previewed
+	for _, pageDef := range r.pages {
+		if pageDef.depends()&changed != 0 {
+			delete(r.pageCache, pageDef.Key)
+		}
 	}
-	if watcher != nil {
-		watcher.Close()
+	var enabledPages []mfd.Page
+	for _, pageDef := range r.enabledPageDefs() {
+		enabledPages = append(enabledPages, r.cachedPage(pageDef))
 	}
+	// The device has room for the largest page set, which the pages of the other commanders may not fill
+	for len(enabledPages) < r.EnabledPageCount() {
+		enabledPages = append(enabledPages, mfd.NewPage())
+	}
+	display := mfd.Display{Pages: enabledPages}
+	r.displayLock.Lock()
+	r.rendered = display
+	r.displayLock.Unlock()
+
+	view := r.currentView(r.state)
+	r.publishView(view)
+	bus.Publish(r.events, rendered{display: display, view: view})
 }
 
-func findJournalFile(folder string) string {
-	// Based on https://github.com/EDCD/EDMarketConnector/blob/693463d3a0dbe58a1f72b83fc09a44a4398af3fd/monitor.py#L264
-	// because I don't have Odyssey myself
-	files, _ := filepath.Glob(filepath.Join(folder, "Journal.*.*.log"))
+// cachedPage returns the page from the cache, rendering it if the state it shows changed since
+func (r *Reader) cachedPage(pageDef PageDef) mfd.Page {
+	page, ok := r.pageCache[pageDef.Key]
+	if ok {
+		r.metrics.cached()
+		return page
+	}
+	page = mfd.NewPage()
+	pageDef.Render(r, &page, r.state)
+	r.pageCache[pageDef.Key] = page
+	r.metrics.rendered()
+	return page
+}
 
-	var lastFileTime time.Time
-	var mostRecentJournal = ""
+// enabledPageDefs returns the pages enabled for the active commander, in display order
+func (r *Reader) enabledPageDefs() []PageDef {
+	return r.pageDefs(r.cfg.ForCommander(r.state.Commander).Pages)
+}
 
-	for _, filename := range files {
-		info, err := os.Stat(filename)
-		if err != nil {
-			continue
-		}
-		if mostRecentJournal == "" || info.ModTime().After(lastFileTime) {
-			lastFileTime = info.ModTime()
-			mostRecentJournal = filename
+func (r *Reader) pageDefs(enabled map[string]bool) []PageDef {
+	var defs []PageDef
+	for _, pageDef := range r.pages {
+		if enabled[string(pageDef.Key)] {
+			defs = append(defs, pageDef)
 		}
 	}
-	log.Debug().Str("mostRecentJournal", logging.CleanPath(mostRecentJournal)).Msg("Found most recent journal file")
-	return mostRecentJournal
+	return defs
+}
+
+// EnabledPageCount returns the number of pages shown on the MFD, which is the number of pages of the
+// largest page set of the commanders
+func (r *Reader) EnabledPageCount() int {
+	count := 0
+	for _, pages := range r.cfg.PageSets() {
+		count = max(count, len(r.pageDefs(pages)))
+	}
+	return count
+}
+
+// SelectPage is the soft button callback for the MFD. The click is routed to the Select
+// handler of the page shown on the given device page.
+func (r *Reader) SelectPage(page uint32) {
+	select {
+	case r.selectCh <- page:
+	default:
+		log.Debug().Uint32("page", page).Msg("Soft button click dropped, previous click still pending")
+	}
 }
 
-func swapMfd() {
-	MfdLock.RLock()
-	defer MfdLock.RUnlock()
-	eq := cmp.Equal(Mfd, PrevMfd)
-	if !eq {
-		mfd.Write(Mfd)
-		PrevMfd = Mfd.Copy()
+func (r *Reader) handleSelect(page uint32) {
+	defs := r.enabledPageDefs()
+	if int(page) >= len(defs) {
+		log.Warn().Uint32("page", page).Msg("Soft button clicked on unknown page")
+		return
+	}
+	pageDef := defs[page]
+	if pageDef.Select == nil {
+		return
 	}
+	log.Debug().Str("page", string(pageDef.Key)).Msg("Soft button select")
+	before := r.pageState()
+	pageDef.Select(r, &r.state)
+	// The page shows what was selected, and the others what else changed
+	delete(r.pageCache, pageDef.Key)
+	r.renderMFD(before.changed(r.pageState()))
+	r.saveSnapshot()
 }
 
-// Prefetches station info for a system and caches it
-func PrefetchStations(systemAddress int64) {
-	go func() {
-		_, _ = edsm.GetSystemStations(systemAddress)
-	}()
+// onReader runs fn on the reader routine and waits for it to finish. If the reader is not running
+// yet, fn is run right away.
+func (r *Reader) onReader(fn func()) {
+	select {
+	case <-r.started:
+	default:
+		fn()
+		return
+	}
+	done := make(chan struct{})
+	select {
+	case r.requestCh <- func() { fn(); close(done) }:
+		<-done
+	case <-r.stopped:
+	}
+}
+
+// swapMfd sends the rendered pages to the display if they changed since they were last sent
+func (r *Reader) swapMfd(p rendered) {
+	r.displayLock.RLock()
+	eq := cmp.Equal(p.display, r.shown)
+	r.displayLock.RUnlock()
+	if eq {
+		return
+	}
+	if r.display != nil {
+		r.display(p.display)
+	}
+	r.displayLock.Lock()
+	r.shown = p.display.Copy()
+	r.displayLock.Unlock()
+	bus.Publish(r.events, DisplayEvent{Display: p.display.Copy(), View: p.view})
+}
+
+// prefetchStations fetches and caches the stations of the system the player arrived in
+func (r *Reader) prefetchStations(e JournalEvent) {
+	address := e.State.Location.SystemAddress
+	if address == 0 || address == r.prefetchSystem {
+		return
+	}
+	r.prefetchSystem = address
+	log.Debug().Int64("systemAddress", address).Msg("Prefetching stations")
+	_, _ = r.galaxy.Stations(address)
 }
@@ -0,0 +1,103 @@
+package edreader
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pellux-network/EDxDC/conf"
+	"github.com/pellux-network/EDxDC/journal"
+	"github.com/rs/zerolog"
+)
+
+// testJournal is the journal of the test folder
+const testJournal = "Journal.2025-01-01T100000.01.log"
+
+// newTestReader returns a reader of a journal folder with a game session going on, as it is after
+// the first update
+func newTestReader(tb testing.TB) *Reader {
+	tb.Helper()
+	return newTestReaderWith(tb, nil)
+}
+
+// newTestReaderWith is newTestReader, calling setup before the first update
+func newTestReaderWith(tb testing.TB, setup func(r *Reader)) *Reader {
+	tb.Helper()
+	return newFolderReader(tb, newTestFolder(tb), setup)
+}
+
+// newTestFolder returns a journal folder with a game session going on
+func newTestFolder(tb testing.TB) string {
+	tb.Helper()
+	// The MFD drops pages it falls behind on, and the journal does not know the value of the system
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+	log.SetOutput(io.Discard)
+	tb.Cleanup(func() {
+		zerolog.SetGlobalLevel(zerolog.TraceLevel)
+		log.SetOutput(os.Stderr)
+	})
+	// The commodity names are read from the names folder
+	tb.Chdir("..")
+
+	dir := tb.TempDir()
+	files := map[string]string{
+		testJournal: `{ "timestamp":"2025-01-01T10:00:00Z", "event":"Commander", "FID":"F1", "Name":"Bench" }
+{ "timestamp":"2025-01-01T10:00:01Z", "event":"Scan", "StarSystem":"Sol", "SystemAddress":10477373803, "BodyName":"Sol", "BodyID":0, "StarType":"G", "DistanceFromArrivalLS":0.0 }
+{ "timestamp":"2025-01-01T10:00:01Z", "event":"Scan", "StarSystem":"Sol", "SystemAddress":10477373803, "BodyName":"Earth", "BodyID":3, "PlanetClass":"Earthlike body", "Landable":false, "DistanceFromArrivalLS":499.0 }
+{ "timestamp":"2025-01-01T10:00:01Z", "event":"Location", "Docked":true, "StationName":"Abraham Lincoln", "StationType":"Orbis", "StarSystem":"Sol", "SystemAddress":10477373803, "Body":"Earth", "BodyID":3, "BodyType":"Planet" }
+`,
+		FileCargo:       `{ "Count":3, "Inventory":[ { "Name":"gold", "Count":2, "Stolen":0 }, { "Name":"silver", "Count":1, "Stolen":1 } ] }`,
+		FileModulesInfo: `{ "Modules":[ { "Slot":"Slot01_Size6", "Item":"int_cargorack_size6_class1" } ] }`,
+		FileStatus:      `{ "Flags":16842765, "Destination":{ "System":10477373803, "Body":3, "Name":"Earth" } }`,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// newFolderReader returns a reader of the journal folder after the first update, calling setup
+// before it
+func newFolderReader(tb testing.TB, dir string, setup func(r *Reader)) *Reader {
+	tb.Helper()
+	cfg := conf.Conf{
+		JournalsFolder: dir,
+		Pages:          map[string]bool{"destination": true, "location": true, "cargo": true},
+		Galaxy:         conf.GalaxyConf{Providers: []string{"journal"}},
+	}
+	r := New(cfg, Options{})
+	tb.Cleanup(func() { r.Close() })
+	if setup != nil {
+		setup(r)
+	}
+	r.update(journal.FolderChange{All: true}, true)
+	return r
+}
+
+// appendJournal appends the lines to the journal of the test reader and reads them
+func appendJournal(tb testing.TB, r *Reader, lines ...string) {
+	tb.Helper()
+	writeJournal(tb, r, lines...)
+	r.update(journal.FolderChange{Files: map[string]bool{testJournal: true}}, false)
+}
+
+// writeJournal appends the lines to the journal of the test reader
+func writeJournal(tb testing.TB, r *Reader, lines ...string) {
+	tb.Helper()
+	f, err := os.OpenFile(filepath.Join(r.folder, testJournal), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for _, line := range lines {
+		if _, err := f.WriteString(line + "\n"); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		tb.Fatal(err)
+	}
+}
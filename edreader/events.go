@@ -0,0 +1,107 @@
+package edreader
+
+import (
+	"time"
+
+	"github.com/pellux-network/EDxDC/bus"
+	"github.com/pellux-network/EDxDC/journal"
+	"github.com/pellux-network/EDxDC/mfd"
+)
+
+const (
+	// ListenerQueue is the number of messages that can wait for a listener added with the Add functions
+	ListenerQueue = 1024
+	// stopTimeout is how long Close waits for the reader and the handlers to finish
+	stopTimeout = 5 * time.Second
+)
+
+// JournalEvent is published for every journal event read
+type JournalEvent struct {
+	// File is the journal file the event was read from
+	File string
+	// Line is the line number of the event in the file, starting at 1
+	Line  int
+	Event journal.Event
+	// State is the journal state after applying the event
+	State Journalstate
+	// Replay is set for the events written before the reader ran, which are read when it starts
+	Replay bool
+}
+
+// StatusEvent is published when Status.json changes
+type StatusEvent struct {
+	Status Status
+}
+
+// CargoEvent is published when Cargo.json is read
+type CargoEvent struct {
+	Cargo Cargo
+}
+
+// ModulesEvent is published when ModulesInfo.json is read
+type ModulesEvent struct {
+	Modules ModulesInfo
+}
+
+// ViewEvent is published when the game state shown on the pages changes
+type ViewEvent struct {
+	View View
+}
+
+// DisplayEvent is published after changed pages were sent to the MFD
+type DisplayEvent struct {
+	Display mfd.Display
+	View    View
+}
+
+// rendered is published when the pages were rendered, changed or not
+type rendered struct {
+	display mfd.Display
+	view    View
+}
+
+// Subscribe calls the handler with the messages of type T published by the reader: JournalEvent,
+// StatusEvent, CargoEvent, ModulesEvent, ViewEvent or DisplayEvent. Handlers without a queue are
+// called from the reader routine and must not block.
+func Subscribe[T any](r *Reader, opts bus.Options, handler func(T)) *bus.Subscription {
+	return bus.Subscribe(r.events, opts, handler)
+}
+
+// JournalEvents passes the decoded journal events, with the state after applying them, to the
+// functions subscribed with journal.On. They are called from the reader routine and must not block.
+func (r *Reader) JournalEvents() *journal.Dispatcher[Journalstate] {
+	return r.journalEvents
+}
+
+// subscribe sets up the handlers of the reader itself
+func (r *Reader) subscribe() {
+	// The data shown on the pages besides the journal state
+	Subscribe(r, bus.Options{Name: "pages"}, func(e StatusEvent) { r.status = e.Status })
+	Subscribe(r, bus.Options{Name: "pages"}, func(e CargoEvent) { r.cargo = e.Cargo })
+	Subscribe(r, bus.Options{Name: "pages"}, func(e ModulesEvent) { r.modules = e.Modules })
+	Subscribe(r, bus.Options{Name: "fleet carriers"}, r.eStatusCarrier)
+	journal.On(r.journalEvents, r.eLoadout)
+	journal.On(r.journalEvents, r.eReceiveText)
+	r.journalEvents.OnName("Commander", r.eCommanderInfo)
+	r.journalEvents.OnName("LoadGame", r.eCommanderInfo)
+	journal.On(r.journalEvents, r.eRank)
+	journal.On(r.journalEvents, r.ePromotion)
+	journal.On(r.journalEvents, r.eMaterials)
+	journal.On(r.journalEvents, r.eMaterialCollected)
+	journal.On(r.journalEvents, r.eMaterialDiscarded)
+	journal.On(r.journalEvents, r.eMissions)
+	journal.On(r.journalEvents, r.eMissionAccepted)
+	journal.On(r.journalEvents, func(state Journalstate, e *journal.MissionCompleted) { r.endMission(state, e.MissionID) })
+	journal.On(r.journalEvents, func(state Journalstate, e *journal.MissionFailed) { r.endMission(state, e.MissionID) })
+	journal.On(r.journalEvents, func(state Journalstate, e *journal.MissionAbandoned) { r.endMission(state, e.MissionID) })
+	journal.On(r.journalEvents, func(_ Journalstate, e *journal.FSDJump) { r.eRouteJump(e.Timestamp, e.Position) })
+	journal.On(r.journalEvents, func(_ Journalstate, e *journal.CarrierJump) { r.eRouteJump(e.Timestamp, e.Position) })
+	Subscribe(r, bus.Options{Name: "journal events"}, func(e JournalEvent) { r.journalEvents.Dispatch(e.State, e.Event) })
+
+	// Only the current system is worth prefetching, so older events may go
+	Subscribe(r, bus.Options{Name: "station prefetch", Queue: 64, Policy: bus.DropOldest}, r.prefetchStations)
+	// Only the latest state is worth saving
+	Subscribe(r, bus.Options{Name: "state snapshot", Queue: 1, Policy: bus.DropOldest}, r.writeSnapshot)
+	// Only the latest pages are worth showing
+	Subscribe(r, bus.Options{Name: "MFD", Queue: 1, Policy: bus.DropOldest}, r.swapMfd)
+}
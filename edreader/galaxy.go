@@ -0,0 +1,64 @@
+package edreader
+
+import (
+	"strings"
+
+	"github.com/pellux-network/EDxDC/conf"
+	"github.com/pellux-network/EDxDC/edsm"
+	"github.com/pellux-network/EDxDC/galaxy"
+	"github.com/pellux-network/EDxDC/galaxydb"
+	"github.com/pellux-network/EDxDC/logging"
+	"github.com/pellux-network/EDxDC/spansh"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultGalaxyProviders is the order of the galaxy providers when the config does not set it
+var DefaultGalaxyProviders = []string{"offline", "edsm", "spansh", "journal"}
+
+// newGalaxy chains the configured galaxy providers. The offline galaxy database is returned as well
+// if it was opened, to be closed with the reader.
+func newGalaxy(cfg conf.GalaxyConf, journal *galaxy.Journal, databaseDir string) (galaxy.Provider, *galaxydb.DB) {
+	names := cfg.Providers
+	if len(names) == 0 {
+		names = DefaultGalaxyProviders
+	}
+	var db *galaxydb.DB
+	providers := []galaxy.Provider{}
+	for _, name := range names {
+		switch strings.ToLower(name) {
+		case "edsm":
+			providers = append(providers, edsm.Provider{})
+		case "spansh":
+			providers = append(providers, spansh.NewProvider(spansh.New()))
+		case "journal":
+			providers = append(providers, journal)
+		case "offline":
+			if db == nil {
+				db = openGalaxyDatabase(databaseDir)
+			}
+			if db != nil {
+				providers = append(providers, db)
+			}
+		default:
+			log.Warn().Str("provider", name).Msg("Ignoring unknown galaxy provider")
+		}
+	}
+	chain := galaxy.NewChain(providers...)
+	log.Info().Str("providers", chain.Name()).Msg("Using galaxy providers")
+	return chain, db
+}
+
+// openGalaxyDatabase opens the offline galaxy database, if dumps were imported into it
+func openGalaxyDatabase(dir string) *galaxydb.DB {
+	if dir == "" || !galaxydb.Exists(dir) {
+		log.Debug().Msg("No offline galaxy database, run galaxy-import to create it")
+		return nil
+	}
+	db, err := galaxydb.Open(dir)
+	if err != nil {
+		log.Warn().Err(err).Str("dir", logging.CleanPath(dir)).Msg("Failed to open offline galaxy database")
+		return nil
+	}
+	log.Info().Int("systems", db.Len()).Msg("Opened offline galaxy database")
+	return db
+}
@@ -1,15 +1,15 @@
 package edreader
 
 import (
-	"bufio"
+	"encoding/json"
 	"io"
 	"os"
 	"regexp"
 	"strings"
-	"sync"
 	"time"
 
-	"github.com/buger/jsonparser"
+	"github.com/pellux-network/EDxDC/bus"
+	"github.com/pellux-network/EDxDC/journal"
 	"github.com/pellux-network/EDxDC/logging"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/text/language"
@@ -41,6 +41,17 @@ type Journalstate struct {
 	LastFSDTargetAddress   int64
 	ShowSplashScreen       bool      // NEW: splash flag
 	SplashScreenStartTime  time.Time // NEW: splash start time
+	Commander              string
+	CommanderFID           string
+	Game
+}
+
+// Game identifies the game client writing the journal
+type Game struct {
+	GameVersion string
+	GameBuild   string
+	Horizons    bool
+	Odyssey     bool
 }
 
 // Location indicates the players current location in the game
@@ -49,6 +60,8 @@ type Location struct {
 
 	SystemAddress int64
 	StarSystem    string
+	// StarPos holds the galactic coordinates of the system, if the journal has told us yet
+	StarPos []float64 `json:",omitempty"`
 
 	Body     string
 	BodyID   int64
@@ -72,79 +85,9 @@ type Destination struct {
 	Name          string
 }
 
-const (
-	systemaddress = "SystemAddress"
-	bodyid        = "BodyID"
-	starsystem    = "StarSystem"
-	docked        = "Docked"
-	body          = "Body"
-	bodytype      = "BodyType"
-	bodyname      = "BodyName"
-	stationname   = "StationName"
-	stationtype   = "StationType"
-	latitude      = "Latitude"
-	longitude     = "Longitude"
-	name          = "Name"
-)
-
-// --- Fleet Carrier helpers ---
-
-var (
-	lastFCReceiveTextNameMu sync.Mutex
-	lastFCReceiveTextName   = map[string]string{} // map[fcID]fcName
-)
-
-type parser struct {
-	line []byte
-}
-
-func (p *parser) getString(field string) (string, bool) {
-	str, err := jsonparser.GetString(p.line, field)
-	if err != nil {
-		return "", false
-	}
-	return str, true
-}
-
-func (p *parser) getInt(field string) (int64, bool) {
-	num, err := jsonparser.GetInt(p.line, field)
-	if err != nil {
-		return 0, false
-	}
-	return num, true
-}
-
-func (p *parser) getBool(field string) (bool, bool) {
-	b, err := jsonparser.GetBoolean(p.line, field)
-	if err != nil {
-		return false, false
-	}
-	return b, true
-}
-
-func (p *parser) getFloat(field string) (float64, bool) {
-	f, err := jsonparser.GetFloat(p.line, field)
-	if err != nil {
-		return 0, false
-	}
-	return f, true
-}
-
 var printer = message.NewPrinter(language.English)
 
-var (
-	lastJournalFile     string
-	lastJournalOffset   int64
-	lastJournalState    Journalstate
-	lastStatusFileSize  int64  // NEW: for status file change detection
-	firstEnabledPageKey string // NEW: track first enabled page
-	lastSystemAddress   int64  // NEW: track last system address for prefetching
-)
-
-func init() {
-	lastJournalState.ShowSplashScreen = true
-	lastJournalState.SplashScreenStartTime = time.Now()
-}
+// --- Fleet Carrier helpers ---
 
 // ExtractFleetCarrierNameID splits a string like "Stormcrow VZY-8XQ" into ("Stormcrow", "VZY-8XQ").
 // Returns ("", "") if not a FC.
@@ -162,8 +105,12 @@ func ExtractFleetCarrierNameID(full string) (string, string) {
 	return name, id
 }
 
-// SaveFleetCarrierReceiveText remembers the last FC name for a given ID.
-func SaveFleetCarrierReceiveText(from string) {
+// SaveFleetCarrierReceiveText remembers the last FC name for a given ID, for the active commander.
+func (r *Reader) SaveFleetCarrierReceiveText(from string) {
+	r.saveFleetCarrierName(r.activeCommander(), from)
+}
+
+func (r *Reader) saveFleetCarrierName(c *commander, from string) {
 	parts := strings.Fields(from)
 	if len(parts) < 2 {
 		return
@@ -174,86 +121,84 @@ func SaveFleetCarrierReceiveText(from string) {
 	}
 	name := strings.TrimSpace(strings.TrimSuffix(from, id))
 	name = strings.TrimSpace(name)
-	lastFCReceiveTextNameMu.Lock()
-	lastFCReceiveTextName[id] = name
-	lastFCReceiveTextNameMu.Unlock()
+	c.carriers[id] = name
+}
+
+// eStatusCarrier remembers the name of the fleet carrier targeted in the status, for the TGT FC page
+func (r *Reader) eStatusCarrier(e StatusEvent) {
+	if e.Status.Destination == nil {
+		return
+	}
+	fcName, fcID := ExtractFleetCarrierNameID(e.Status.Destination.DisplayName())
+	if fcID == "" {
+		return
+	}
+	carriers := r.activeCommander().carriers
+	if _, ok := carriers[fcID]; !ok {
+		carriers[fcID] = fcName
+	}
 }
 
 // GetLastFleetCarrierName returns the last seen FC name for a given ID, or "".
-func GetLastFleetCarrierName(id string) string {
-	lastFCReceiveTextNameMu.Lock()
-	defer lastFCReceiveTextNameMu.Unlock()
-	return lastFCReceiveTextName[id]
+func (r *Reader) GetLastFleetCarrierName(id string) string {
+	return r.activeCommander().carriers[id]
 }
 
-// Call this at startup after loading config, e.g. in main or Start()
-func SetFirstEnabledPageKey(cfg map[string]bool) {
+// setFirstPageKey finds the first enabled page, which the splash screen waits for
+func (r *Reader) setFirstPageKey() {
 	for _, key := range []string{"destination", "location", "cargo"} {
-		if cfg[key] {
-			firstEnabledPageKey = key
+		if r.cfg.Pages[key] {
+			r.firstPageKey = key
 			break
 		}
 	}
 }
 
-// handleJournalFile reads only new lines from the journal file since the last read.
-func handleJournalFile(filename string) {
+// handleJournalFile reads the lines written to the journal file since the last read. The lines update
+// the state of the commander the file is written for, which becomes the active commander when a new
+// game session starts in it. It tells if any lines were read.
+func (r *Reader) handleJournalFile(filename string, replay bool) bool {
 	if filename == "" {
 		log.Warn().Msg("No journal file specified")
-		return
-	}
-	file, err := os.Open(filename)
-	if err != nil {
-		log.Warn().Err(err).Str("filename", logging.CleanPath(filename)).Msg("Error opening journal file")
-		return
-	}
-	defer file.Close()
-
-	var offset int64 = 0
-	if filename == lastJournalFile {
-		offset = lastJournalOffset
-	}
-
-	info, err := file.Stat()
-	if err != nil {
-		log.Warn().Err(err).Str("filename", logging.CleanPath(filename)).Msg("Error stating journal file")
-		return
-	}
-
-	// If file shrank (rotated), start from beginning
-	if offset > info.Size() {
-		offset = 0
+		return false
 	}
 
-	_, err = file.Seek(offset, 0)
-	if err != nil {
-		log.Warn().Err(err).Str("filename", logging.CleanPath(filename)).Msg("Error seeking journal file")
-		return
-	}
-
-	scanner := bufio.NewScanner(file)
-	scanner.Split(bufio.ScanLines)
-	state := lastJournalState // Start from last known state
+	state := r.stateOf(r.fileCommanders[filename]) // Start from last known state
+	session := ""
 	linesRead := 0
-	for scanner.Scan() {
-		ParseJournalLine(scanner.Bytes(), &state)
+	err := r.tailer.ReadFile(filename, func(l journal.Line) {
 		linesRead++
+		e, err := journal.Decode(l.Data)
+		if err != nil {
+			log.Trace().Err(err).Int("line", l.Number).Msg("Skipping journal line")
+			return
+		}
+		if fid := sessionCommander(e); fid != "" {
+			state = r.startSession(state, fid)
+			session = fid
+		}
+		r.journalGalaxy.Observe(e.EventName(), e.Line())
+		reducers.Dispatch(&state, e)
+		bus.Publish(r.events, JournalEvent{File: filename, Line: l.Number, Event: e, State: state, Replay: replay})
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("filename", logging.CleanPath(filename)).Msg("Error reading journal file")
 	}
 	if linesRead > 0 {
-		lastJournalState = state // Only update if new lines were read
+		r.setState(state.CommanderFID, state) // Only update if new lines were read
 		log.Debug().Int("linesRead", linesRead).Str("filename", logging.CleanPath(filename)).Msg("Processed new journal lines")
 	}
+	if session != "" {
+		r.activate(session)
+	}
+	r.fileCommanders[filename] = state.CommanderFID
 
-	// Save offset for next time
-	pos, _ := file.Seek(0, 1)
-	lastJournalFile = filename
-	lastJournalOffset = pos
-
-	checkSplashScreen()
+	r.checkSplashScreen()
+	return linesRead > 0
 }
 
 // handleStatusFile reads Status.json for the current destination
-func handleStatusFile(filename string) {
+func (r *Reader) handleStatusFile(filename string) {
 	if filename == "" {
 		log.Warn().Msg("No status file specified")
 		return
@@ -270,10 +215,10 @@ func handleStatusFile(filename string) {
 		log.Warn().Err(err).Str("filename", logging.CleanPath(filename)).Msg("Error stating status file")
 		return
 	}
-	if info.Size() == lastStatusFileSize {
+	if info.Size() == r.statusSize {
 		return
 	}
-	lastStatusFileSize = info.Size()
+	r.statusSize = info.Size()
 
 	data, err := io.ReadAll(file)
 	if err != nil {
@@ -281,137 +226,108 @@ func handleStatusFile(filename string) {
 		return
 	}
 
-	destObj, _, _, err := jsonparser.Get(data, "Destination")
-	if err == nil && len(destObj) > 0 {
-		sysID, _ := jsonparser.GetInt(destObj, "System")
-		bodyID, _ := jsonparser.GetInt(destObj, "Body")
-		name := ""
-		nameRaw, _ := jsonparser.GetString(destObj, "Name")
-		if nameRaw != "" && !strings.HasPrefix(nameRaw, "$") {
-			name = nameRaw
-		} else {
-			nameLoc, _ := jsonparser.GetString(destObj, "Name_Localised")
-			if nameLoc != "" {
-				name = nameLoc
-			} else {
-				name = nameRaw
-			}
-		}
-		// --- Fleet Carrier: parse name/id if present ---
-		fcName, fcID := ExtractFleetCarrierNameID(name)
-		if fcID != "" {
-			// Store for session (for TGT FC page)
-			lastFCReceiveTextNameMu.Lock()
-			if _, ok := lastFCReceiveTextName[fcID]; !ok {
-				lastFCReceiveTextName[fcID] = fcName
+	var status Status
+	if err := json.Unmarshal(data, &status); err != nil {
+		log.Warn().Err(err).Str("filename", logging.CleanPath(filename)).Msg("Error parsing status file")
+	} else {
+		bus.Publish(r.events, StatusEvent{Status: status})
+		if dest := status.Destination; dest != nil {
+			r.state.Destination = Destination{
+				SystemAddress: dest.System,
+				BodyID:        dest.Body,
+				Name:          dest.DisplayName(),
 			}
-			lastFCReceiveTextNameMu.Unlock()
-		}
-		lastJournalState.Destination = Destination{
-			SystemAddress: sysID,
-			BodyID:        bodyID,
-			Name:          name,
+		} else {
+			r.state.Destination = Destination{}
 		}
-	} else {
-		lastJournalState.Destination = Destination{}
 	}
 
 	// After updating Destination, check for arrival
-	checkArrival()
+	r.checkArrival()
 
-	checkSplashScreen()
+	r.checkSplashScreen()
 }
 
-// ParseJournalLine parses a single line of the journal and returns the new state after parsing.
-func ParseJournalLine(line []byte, state *Journalstate) {
-	re := regexp.MustCompile(`"event":"(\w*)"`)
+// reducers update the journal state with the events they are subscribed to
+var reducers = journal.NewDispatcher[*Journalstate]()
 
-	event := re.FindStringSubmatch(string(line))
-	if len(event) < 2 {
-		// Not a valid event line, skip
-		return
-	}
-	p := parser{line}
-	switch event[1] {
-	case "Location":
-		eLocation(p, state)
-	case "SupercruiseEntry":
-		eSupercruiseEntry(p, state)
-	case "SupercruiseExit":
-		eSupercruiseExit(p, state)
-	case "FSDJump":
-		eFSDJump(p, state)
-	case "Touchdown":
-		eTouchDown(p, state)
-	case "Liftoff":
-		eLiftoff(p, state)
-	case "FSDTarget":
-		eFSDTarget(p, state)
-	case "ApproachBody":
-		eApproachBody(p, state)
-	case "ApproachSettlement":
-		eApproachSettlement(p, state)
-	case "Loadout":
-		eLoadout(p)
-	case "NavRouteClear":
+func init() {
+	journal.On(reducers, func(state *Journalstate, e *journal.Location) { eLocation(state, e.Position) })
+	journal.On(reducers, func(state *Journalstate, _ *journal.SupercruiseEntry) {
+		state.Type = LocationSystem // don't throw away info
+	})
+	journal.On(reducers, func(state *Journalstate, e *journal.SupercruiseExit) { eLocation(state, e.Position) })
+	journal.On(reducers, eFSDJump)
+	journal.On(reducers, eTouchDown)
+	journal.On(reducers, func(state *Journalstate, _ *journal.Liftoff) { state.Type = LocationPlanet })
+	journal.On(reducers, eFSDTarget)
+	journal.On(reducers, eApproachBody)
+	journal.On(reducers, eApproachSettlement)
+	journal.On(reducers, func(state *Journalstate, e *journal.Commander) { eCommander(state, e.Name, e.FID) })
+	journal.On(reducers, func(state *Journalstate, e *journal.LoadGame) {
+		eCommander(state, e.Commander, e.FID)
+		eGame(state, e.GameInfo)
+	})
+	journal.On(reducers, func(state *Journalstate, e *journal.Fileheader) { eGame(state, e.GameInfo) })
+	journal.On(reducers, func(state *Journalstate, e *journal.CarrierJump) { eLocation(state, e.Position) })
+	journal.On(reducers, func(state *Journalstate, _ *journal.NavRouteClear) {
 		state.EDSMTarget = EDSMTarget{}
 		state.LastFSDTargetSystem = ""
 		state.LastFSDTargetAddress = 0
 		state.ArrivedAtFSDTarget = false
 		state.ArrivedAtFSDTargetTime = time.Time{}
-	case "ReceiveText":
-		eReceiveText(p)
-	case "Docked":
-		eDocked(p, state)
+	})
+	journal.On(reducers, eDocked)
+}
+
+// ParseJournalLine parses a single line of the journal and updates the state accordingly.
+func ParseJournalLine(line []byte, state *Journalstate) {
+	e, err := journal.Decode(line)
+	if err != nil {
+		// Not a valid event line, skip
+		log.Trace().Err(err).Msg("Skipping journal line")
+		return
 	}
+	reducers.Dispatch(state, e)
 }
 
-func eLocation(p parser, state *Journalstate) {
+func eLocation(state *Journalstate, pos journal.Position) {
 	// clear current location completely
 	state.Type = LocationSystem
-	state.Location.SystemAddress, _ = p.getInt(systemaddress)
-	state.StarSystem, _ = p.getString(starsystem)
-
-	// Prefetch stations if system changed
-	if state.Location.SystemAddress != lastSystemAddress && state.Location.SystemAddress != 0 {
-		PrefetchStations(state.Location.SystemAddress)
-		lastSystemAddress = state.Location.SystemAddress
-	}
-
-	bodyType, ok := p.getString(bodytype)
-
-	if ok && bodyType == "Planet" {
-		state.Location.BodyID, _ = p.getInt(bodyid)
-		state.Location.Body, _ = p.getString(body)
-		state.BodyType, _ = p.getString(bodytype)
+	previousAddress := state.Location.SystemAddress
+	state.Location.SystemAddress = pos.SystemAddress
+	if len(pos.StarPos) == 3 {
+		state.StarPos = pos.StarPos
+	} else if state.Location.SystemAddress != previousAddress {
+		state.StarPos = nil
+	}
+	state.StarSystem = pos.StarSystem
+
+	if pos.BodyType == "Planet" {
+		state.Location.BodyID = pos.BodyID
+		state.Location.Body = pos.Body
+		state.BodyType = pos.BodyType
 		state.Type = LocationPlanet
 
-		lat, ok := p.getFloat(latitude)
-		if ok {
-			state.Latitude = lat
-			state.Longitude, _ = p.getFloat(longitude)
+		if pos.Latitude != nil {
+			state.Latitude = *pos.Latitude
+			state.Longitude = 0
+			if pos.Longitude != nil {
+				state.Longitude = *pos.Longitude
+			}
 			state.Type = LocationLanded
 		}
 	}
 
-	docked, _ := p.getBool(docked)
-	if docked {
+	if pos.Docked {
 		state.Type = LocationDocked
 	}
 }
 
-func eSupercruiseEntry(p parser, state *Journalstate) {
-	state.Type = LocationSystem // don't throw away info
-}
-
-func eSupercruiseExit(p parser, state *Journalstate) {
-	eLocation(p, state)
-}
-
-func eFSDJump(p parser, state *Journalstate) {
-	eLocation(p, state)
-	jumpSystem, _ := p.getString(starsystem)
-	jumpAddress, _ := p.getInt(systemaddress)
+func eFSDJump(state *Journalstate, e *journal.FSDJump) {
+	eLocation(state, e.Position)
+	jumpSystem := e.StarSystem
+	jumpAddress := e.SystemAddress
 	// Only trigger arrival if there was a valid FSD target (not zero/empty)
 	if (state.LastFSDTargetAddress != 0 && jumpAddress == state.LastFSDTargetAddress) ||
 		(state.LastFSDTargetSystem != "" && jumpSystem != "" && strings.EqualFold(jumpSystem, state.LastFSDTargetSystem)) {
@@ -431,23 +347,17 @@ func eFSDJump(p parser, state *Journalstate) {
 	}
 }
 
-func eTouchDown(p parser, state *Journalstate) {
-	state.Latitude, _ = p.getFloat(latitude)
-	state.Longitude, _ = p.getFloat(longitude)
+func eTouchDown(state *Journalstate, e *journal.Touchdown) {
+	state.Latitude = e.Latitude
+	state.Longitude = e.Longitude
 	state.Type = LocationLanded
 }
 
-func eLiftoff(p parser, state *Journalstate) {
-	state.Type = LocationPlanet
-}
-
-func eFSDTarget(p parser, state *Journalstate) {
-	systemAddress, _ := p.getInt(systemaddress)
-	state.EDSMTarget.SystemAddress = systemAddress
-	state.EDSMTarget.Name, _ = p.getString(name)
-	jumps, ok := p.getInt("RemainingJumpsInRoute")
-	if ok && systemAddress != 0 {
-		state.EDSMTarget.RemainingJumpsInRoute = int(jumps)
+func eFSDTarget(state *Journalstate, e *journal.FSDTarget) {
+	state.EDSMTarget.SystemAddress = e.SystemAddress
+	state.EDSMTarget.Name = e.Name
+	if e.RemainingJumpsInRoute != nil && e.SystemAddress != 0 {
+		state.EDSMTarget.RemainingJumpsInRoute = *e.RemainingJumpsInRoute
 	} else {
 		state.EDSMTarget.RemainingJumpsInRoute = 0
 	}
@@ -459,93 +369,100 @@ func eFSDTarget(p parser, state *Journalstate) {
 	state.ArrivedAtFSDTargetTime = time.Time{}
 }
 
-func eApproachBody(p parser, state *Journalstate) {
-	state.Location.Body, _ = p.getString(body)
-	state.Location.BodyID, _ = p.getInt(bodyid)
+func eApproachBody(state *Journalstate, e *journal.ApproachBody) {
+	state.Location.Body = e.Body
+	state.Location.BodyID = e.BodyID
 
 	state.Type = LocationPlanet
 }
 
-func eApproachSettlement(p parser, state *Journalstate) {
-	state.Location.Body, _ = p.getString(bodyname)
-	state.Location.BodyID, _ = p.getInt(bodyid)
+func eApproachSettlement(state *Journalstate, e *journal.ApproachSettlement) {
+	state.Location.Body = e.BodyName
+	state.Location.BodyID = e.BodyID
 
 	state.Type = LocationPlanet
 }
 
-func eLoadout(p parser) {
-	capacity, ok := p.getInt("CargoCapacity")
-	if ok {
-		currentCargoCapacity = int(capacity)
+func eCommander(state *Journalstate, name, fid string) {
+	state.Commander = name
+	state.CommanderFID = fid
+}
+
+func eGame(state *Journalstate, game journal.GameInfo) {
+	if game.GameVersion != "" {
+		state.GameVersion = game.GameVersion
+	}
+	if game.Build != "" {
+		state.GameBuild = game.Build
+	}
+	if game.Horizons != nil {
+		state.Horizons = *game.Horizons
+	}
+	if game.Odyssey != nil {
+		state.Odyssey = *game.Odyssey
 	}
 }
 
-func eDocked(p parser, state *Journalstate) {
-	stationName, _ := p.getString("StationName")
-	stationType, _ := p.getString("StationType")
-	systemAddress, _ := p.getInt("SystemAddress")
-	systemName, _ := p.getString("StarSystem")
+func (r *Reader) eLoadout(state Journalstate, e *journal.Loadout) {
+	c := r.commander(state.CommanderFID)
+	c.loadout = e.Line()
+	if e.CargoCapacity != nil {
+		c.cargoCapacity = *e.CargoCapacity
+	}
+}
 
+func eDocked(state *Journalstate, e *journal.Docked) {
 	state.Type = LocationDocked
-	state.Location.Body = stationName
+	state.Location.Body = e.StationName
 	state.Location.BodyID = 0
-	state.Location.SystemAddress = systemAddress
-	state.Location.StarSystem = systemName
+	state.Location.SystemAddress = e.SystemAddress
+	state.Location.StarSystem = e.StarSystem
 	state.BodyType = "Station"
-
-	// --- Fleet Carrier: store last FC name for this ID if docking at FC ---
-	if stationType == "FleetCarrier" {
-		// Try to get last seen FC name from ReceiveText, else leave as is
-		// (Display logic will handle fallback)
-	}
 }
 
 // --- Fleet Carrier: parse ReceiveText for FC name ---
-func eReceiveText(p parser) {
-	from, _ := p.getString("From")
-	message, _ := p.getString("Message")
-	channel, _ := p.getString("Channel")
-	if channel == "npc" && strings.HasSuffix(message, "docking_granted;") {
+func (r *Reader) eReceiveText(state Journalstate, e *journal.ReceiveText) {
+	if e.Channel == "npc" && strings.HasSuffix(e.Message, "docking_granted;") {
 		// Only store if looks like FC docking granted
-		SaveFleetCarrierReceiveText(from)
+		r.saveFleetCarrierName(r.commander(state.CommanderFID), e.From)
 	}
 }
 
-func checkArrival() {
+func (r *Reader) checkArrival() {
 	// Only clear arrival state if a new target is set, or N seconds have passed
 	const arrivalTimeout = 10 * time.Second // <-- Change this value as desired
-	if lastJournalState.ArrivedAtFSDTarget {
-		if lastJournalState.EDSMTarget.SystemAddress != 0 || // new FSD target
-			lastJournalState.Destination.SystemAddress != 0 || // local target
-			(!lastJournalState.ArrivedAtFSDTargetTime.IsZero() &&
-				time.Since(lastJournalState.ArrivedAtFSDTargetTime) > arrivalTimeout) {
-			lastJournalState.ArrivedAtFSDTarget = false
-			lastJournalState.ArrivedAtFSDTargetTime = time.Time{}
+	if r.state.ArrivedAtFSDTarget {
+		if r.state.EDSMTarget.SystemAddress != 0 || // new FSD target
+			r.state.Destination.SystemAddress != 0 || // local target
+			(!r.state.ArrivedAtFSDTargetTime.IsZero() &&
+				time.Since(r.state.ArrivedAtFSDTargetTime) > arrivalTimeout) {
+			r.state.ArrivedAtFSDTarget = false
+			r.state.ArrivedAtFSDTargetTime = time.Time{}
 		}
 	}
 }
 
-func checkSplashScreen() {
+func (r *Reader) checkSplashScreen() {
 	const splashTimeout = 10 * time.Second
-	if lastJournalState.ShowSplashScreen {
-		timeoutPassed := time.Since(lastJournalState.SplashScreenStartTime) > splashTimeout
+	if r.state.ShowSplashScreen {
+		timeoutPassed := time.Since(r.state.SplashScreenStartTime) > splashTimeout
 
 		firstPageReady := false
-		switch firstEnabledPageKey {
+		switch r.firstPageKey {
 		case "destination":
-			firstPageReady = lastJournalState.Destination.SystemAddress != 0 ||
-				lastJournalState.EDSMTarget.SystemAddress != 0 ||
-				(lastJournalState.Type == LocationDocked && lastJournalState.Location.Body != "")
+			firstPageReady = r.state.Destination.SystemAddress != 0 ||
+				r.state.EDSMTarget.SystemAddress != 0 ||
+				(r.state.Type == LocationDocked && r.state.Location.Body != "")
 		case "location":
-			firstPageReady = lastJournalState.Location.SystemAddress != 0
+			firstPageReady = r.state.Location.SystemAddress != 0
 		case "cargo":
-			firstPageReady = len(currentCargo.Inventory) > 0
+			firstPageReady = len(r.cargo.Inventory) > 0
 		default:
 			firstPageReady = true // fallback: don't block forever
 		}
 
 		if timeoutPassed && firstPageReady {
-			lastJournalState.ShowSplashScreen = false
+			r.state.ShowSplashScreen = false
 		}
 	}
 }
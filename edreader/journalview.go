@@ -3,42 +3,50 @@ package edreader
 import (
 	"fmt"
 	"log"
+	"slices"
 	"sort"
 	"strings"
+	"time"
 
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 
-	lcdformat "github.com/pbxx/goLCDFormat"
-	"github.com/pellux-network/EDxDC/edsm"
+	"github.com/pellux-network/EDxDC/galaxy"
 	"github.com/pellux-network/EDxDC/mfd"
 )
 
-// Gets system body information from EDSM
-func GetEDSMBodies(systemaddress int64) (*edsm.System, error) {
-	sysinfo := <-edsm.GetSystemBodies(systemaddress)
-	if sysinfo.Error != nil {
-		return nil, fmt.Errorf("unable to fetch system information: %w", sysinfo.Error)
-	}
-	sys := sysinfo.S
-	if sys.ID64 == 0 {
-		return nil, fmt.Errorf("no EDSM data for system address %d", systemaddress)
+// BodySortOrder determines in which order the valuable bodies are listed on the system page
+type BodySortOrder int
+
+const (
+	// SortByValue lists the most valuable bodies first
+	SortByValue BodySortOrder = iota
+	// SortByDistance lists the bodies closest to the arrival point first
+	SortByDistance
+	// SortByName lists the bodies alphabetically
+	SortByName
+)
+
+// GetSystemBodies gets the system body information from the galaxy providers
+func (r *Reader) GetSystemBodies(systemaddress int64) (*galaxy.System, error) {
+	sys, err := r.galaxy.Bodies(systemaddress)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch system information for system address %d: %w", systemaddress, err)
 	}
 	return &sys, nil
 }
 
-// Gets system monetary values from EDSM
-func GetEDSMSystemValue(systemaddress int64) (*edsm.System, error) {
-	valueinfopromise := edsm.GetSystemValue(systemaddress)
-	valinfo := <-valueinfopromise
-	if valinfo.Error != nil {
-		return nil, fmt.Errorf("unable to fetch system value: %w", valinfo.Error)
+// GetSystemValue gets the system monetary values from the galaxy providers
+func (r *Reader) GetSystemValue(systemaddress int64) (*galaxy.System, error) {
+	sys, err := r.galaxy.Value(systemaddress)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch system value for system address %d: %w", systemaddress, err)
 	}
-	return &valinfo.S, nil
+	return &sys, nil
 }
 
 // Helper to render a station page
-func RenderStationPage(page *mfd.Page, header string, st edsm.Station) {
+func RenderStationPage(page *mfd.Page, header string, st galaxy.Station) {
 	lines := []string{}
 	// Map allegiance to abbreviation
 	abbr := map[string]string{
@@ -52,20 +60,21 @@ func RenderStationPage(page *mfd.Page, header string, st edsm.Station) {
 	if alg == "" {
 		alg = st.Allegiance // fallback to raw if not mapped
 	}
-	lines = append(lines, lcdformat.SpaceBetween(16, header, alg))
+	page.ID = "station:" + st.Name
+	lines = append(lines, mfd.SpaceBetween(header, alg))
 	lines = append(lines, st.Name)
 	lines = append(lines, st.Type)
 	for _, line := range lines {
-		page.Add(line)
+		page.Add("%s", line)
 	}
 }
 
 // Helper to render a Fleet Carrier page
-func RenderFleetCarrierPage(page *mfd.Page, header, fcID, fcName string, systemAddress int64) {
+func (r *Reader) RenderFleetCarrierPage(page *mfd.Page, header, fcID, fcName string, systemAddress int64) {
 	lines := []string{}
-	// Try to get EDSM station info for type
+	// Try to get the station info for type
 	stType := "Fleet Carrier"
-	stations, err := edsm.GetSystemStations(systemAddress)
+	stations, err := r.galaxy.Stations(systemAddress)
 	if err == nil {
 		for _, st := range stations {
 			if strings.EqualFold(st.Name, fcID) {
@@ -74,21 +83,22 @@ func RenderFleetCarrierPage(page *mfd.Page, header, fcID, fcName string, systemA
 			}
 		}
 	}
-	lines = append(lines, lcdformat.SpaceBetween(16, header, fcID))
+	page.ID = "station:" + fcID
+	lines = append(lines, mfd.SpaceBetween(header, fcID))
 	lines = append(lines, fcName)
 	lines = append(lines, stType)
 	for _, line := range lines {
-		page.Add(line)
+		page.Add("%s", line)
 	}
 }
 
 // Page rendering functions for MFD
-func RenderLocationPage(page *mfd.Page, state Journalstate) {
+func (r *Reader) RenderLocationPage(page *mfd.Page, state Journalstate) {
 	// Fleet Carrier: CURR FC page
 	if state.Type == LocationDocked && state.Location.Body != "" && state.BodyType == "Station" {
 		// Try to detect if docked at FC
 		// state.Location.Body = StationName (FC ID), state.Location.SystemAddress
-		stations, err := edsm.GetSystemStations(state.Location.SystemAddress)
+		stations, err := r.galaxy.Stations(state.Location.SystemAddress)
 		isFC := false
 		if err == nil {
 			for _, st := range stations {
@@ -101,15 +111,15 @@ func RenderLocationPage(page *mfd.Page, state Journalstate) {
 		if isFC || strings.HasPrefix(state.Location.Body, "FC") || len(state.Location.Body) == 7 {
 			// Try to get last seen FC name from session
 			fcID := state.Location.Body
-			fcName := GetLastFleetCarrierName(fcID)
+			fcName := r.GetLastFleetCarrierName(fcID)
 			if fcName == "" {
 				fcName = "Unknown Fleet Carrier"
 			}
-			RenderFleetCarrierPage(page, "CURR FC", fcID, fcName, state.Location.SystemAddress)
+			r.RenderFleetCarrierPage(page, "CURR FC", fcID, fcName, state.Location.SystemAddress)
 			return
 		}
 		// for normal stations
-		stations, err = edsm.GetSystemStations(state.Location.SystemAddress)
+		stations, err = r.galaxy.Stations(state.Location.SystemAddress)
 		if err == nil {
 			for _, st := range stations {
 				if strings.EqualFold(st.Name, state.Location.Body) {
@@ -121,29 +131,54 @@ func RenderLocationPage(page *mfd.Page, state Journalstate) {
 		// fallback: show as body if not found as station
 	}
 	if state.Type == LocationPlanet || state.Type == LocationLanded {
-		ApplyBodyPage(page, "CURR BODY", state.Location.SystemAddress, state.Location.BodyID, state.Location.Body)
+		r.ApplyBodyPage(page, "CURR BODY", state.Location.SystemAddress, state.Location.BodyID, state.Location.Body)
 	} else {
-		ApplySystemPage(page, "CURR SYS", state.Location.StarSystem, state.Location.SystemAddress, &state)
+		r.ApplySystemPage(page, "CURR SYS", state.Location.StarSystem, state.Location.SystemAddress, &state)
+	}
+}
+
+// SelectLocationPage cycles the sort order of the valuable bodies on the system page
+func (r *Reader) SelectLocationPage(_ *Journalstate) {
+	r.bodySortOrder = (r.bodySortOrder + 1) % (SortByName + 1)
+}
+
+// SelectDestinationPage acknowledges the arrival screen. Without an arrival to acknowledge, the
+// cached galaxy information is cleared so it will be fetched again.
+func (r *Reader) SelectDestinationPage(state *Journalstate) {
+	if state.ArrivedAtFSDTarget {
+		state.ArrivedAtFSDTarget = false
+		state.ArrivedAtFSDTargetTime = time.Time{}
+		return
+	}
+	if cc, ok := r.galaxy.(galaxy.CacheClearer); ok {
+		cc.ClearCache()
 	}
 }
 
-func RenderDestinationPage(page *mfd.Page, state Journalstate) {
+// SelectCargoPage toggles between showing all cargo and only stolen cargo
+func (r *Reader) SelectCargoPage(_ *Journalstate) {
+	r.cargoStolenOnly = !r.cargoStolenOnly
+}
+
+func (r *Reader) RenderDestinationPage(page *mfd.Page, state Journalstate) {
 	lines := []string{}
 	if state.ShowSplashScreen {
+		page.ID = "splash"
 		lines = append(lines, "################")
 		lines = append(lines, "EDxDC v1.2.3-beta")
 		lines = append(lines, "################")
 		for _, line := range lines {
-			page.Add(line)
+			page.Add("%s", line)
 		}
 		return
 	}
 	if state.ArrivedAtFSDTarget {
+		page.ID = "arrived"
 		lines = append(lines, "################")
 		lines = append(lines, " You have arrived ")
 		lines = append(lines, "################")
 		for _, line := range lines {
-			page.Add(line)
+			page.Add("%s", line)
 		}
 		return
 	}
@@ -160,12 +195,12 @@ func RenderDestinationPage(page *mfd.Page, state Journalstate) {
 			if fcName == "" {
 				fcName = "Unknown Fleet Carrier"
 			}
-			RenderFleetCarrierPage(page, "TGT FC", fcID, fcName, state.Destination.SystemAddress)
+			r.RenderFleetCarrierPage(page, "TGT FC", fcID, fcName, state.Destination.SystemAddress)
 			return
 		}
 
 		// Try to match station by name
-		stations, err := edsm.GetSystemStations(state.Location.SystemAddress)
+		stations, err := r.galaxy.Stations(state.Location.SystemAddress)
 		if err == nil {
 			for _, st := range stations {
 				if strings.EqualFold(st.Name, state.Destination.Name) {
@@ -177,99 +212,119 @@ func RenderDestinationPage(page *mfd.Page, state Journalstate) {
 
 		// Fallback to body logic if BodyID is set
 		if state.Destination.BodyID != 0 {
-			sys, err := GetEDSMBodies(state.Location.SystemAddress)
+			sys, err := r.GetSystemBodies(state.Location.SystemAddress)
 			if err == nil {
 				body := sys.BodyByID(state.Destination.BodyID)
+				page.ID = fmt.Sprintf("body:%d:%d", state.Location.SystemAddress, state.Destination.BodyID)
 				switch {
 				case body.IsLandable:
-					ApplyBodyPage(page, "TGT BODY", state.Location.SystemAddress, state.Destination.BodyID, state.Destination.Name)
+					r.ApplyBodyPage(page, "TGT BODY", state.Location.SystemAddress, state.Destination.BodyID, state.Destination.Name)
 					return
 				default:
-					lines = append(lines, lcdformat.SpaceBetween(16, "TGT BODY", ""))
+					lines = append(lines, mfd.SpaceBetween("TGT BODY", ""))
 					lines = append(lines, state.Destination.Name)
 					if body.SubType != "" {
 						lines = append(lines, body.SubType)
 					}
 					for _, line := range lines {
-						page.Add(line)
+						page.Add("%s", line)
 					}
 					return
 				}
 			}
 		}
-		// Fallback if EDSM fails or no BodyID
-		lines = append(lines, lcdformat.SpaceBetween(16, "TGT BODY", ""))
+		// Fallback if the galaxy data fails or no BodyID
+		page.ID = fmt.Sprintf("body:%d:%d", state.Location.SystemAddress, state.Destination.BodyID)
+		lines = append(lines, mfd.SpaceBetween("TGT BODY", ""))
 		lines = append(lines, state.Destination.Name)
 		// No type info available in this fallback
 		for _, line := range lines {
-			page.Add(line)
+			page.Add("%s", line)
 		}
 		return
 	}
 
 	// FSD target (next jump)
 	if state.EDSMTarget.SystemAddress != 0 {
-		ApplySystemPage(page, "NEXT JUMP", state.EDSMTarget.Name, state.EDSMTarget.SystemAddress, &state)
+		r.ApplySystemPage(page, "NEXT JUMP", state.EDSMTarget.Name, state.EDSMTarget.SystemAddress, &state)
 		return
 	}
 
+	page.ID = "none"
 	lines = append(lines, " No Destination ")
 	for _, line := range lines {
-		page.Add(line)
+		page.Add("%s", line)
 	}
 }
 
-func RenderCargoPage(page *mfd.Page, _ Journalstate) {
+func (r *Reader) RenderCargoPage(page *mfd.Page, _ Journalstate) {
 	lines := []string{}
+	page.ID = "cargo"
 	// Cargo header
-	lines = append(lines, fmt.Sprintf("CARGO: %04d/%04d", currentCargo.Count, ModulesInfoCargoCapacity()))
-	// If currentCargo is nil (never loaded), show "No cargo data"
-	if currentCargo.Inventory == nil {
-		lines = append(lines, lcdformat.FillAround(16, "*", " NO CRGO DATA "))
+	if r.cargoStolenOnly {
+		page.ID = "cargo:stolen"
+		lines = append(lines, fmt.Sprintf("STOLN: %04d/%04d", r.cargo.stolenCount(), r.ModulesInfoCargoCapacity()))
+	} else {
+		lines = append(lines, fmt.Sprintf("CARGO: %04d/%04d", r.cargo.Count, r.ModulesInfoCargoCapacity()))
+	}
+	// If r.cargo is nil (never loaded), show "No cargo data"
+	if r.cargo.Inventory == nil {
+		lines = append(lines, mfd.FillAround("*", " NO CRGO DATA "))
 		for _, line := range lines {
-			page.Add(line)
+			page.Add("%s", line)
 		}
 		return
 	}
 
-	if len(currentCargo.Inventory) == 0 {
+	if len(r.cargo.Inventory) == 0 {
 		// If cargo inventory is empty, show "Cargo Hold Empty"
-		lines = append(lines, lcdformat.FillAround(16, "*", " NO CARGO "))
+		lines = append(lines, mfd.FillAround("*", " NO CARGO "))
 		for _, line := range lines {
-			page.Add(line)
+			page.Add("%s", line)
 		}
 		return
 	}
-	sort.Slice(currentCargo.Inventory, func(i, j int) bool {
-		a := currentCargo.Inventory[i]
-		b := currentCargo.Inventory[j]
-		return a.displayname() < b.displayname()
+	// Sorted apart from the cargo, which tells if the cargo changed
+	inventory := slices.Clone(r.cargo.Inventory)
+	sort.Slice(inventory, func(i, j int) bool {
+		return inventory[i].DisplayName() < inventory[j].DisplayName()
 	})
 
-	for _, line := range currentCargo.Inventory {
-		lines = append(lines, lcdformat.SpaceBetween(16, line.displayname(), fmt.Sprintf("%d", line.Count)))
+	for _, line := range inventory {
+		count := line.Count
+		if r.cargoStolenOnly {
+			if line.Stolen == 0 {
+				continue
+			}
+			count = line.Stolen
+		}
+		lines = append(lines, mfd.SpaceBetween(line.DisplayName(), fmt.Sprintf("%d", count)))
+	}
+	if len(lines) == 1 {
+		lines = append(lines, mfd.FillAround("*", " NO STOLEN "))
 	}
 	for _, line := range lines {
-		page.Add(line)
+		page.Add("%s", line)
 	}
 }
 
 // Page assembly functions for MFD
-func ApplySystemPage(page *mfd.Page, header, systemname string, systemaddress int64, state *Journalstate) {
+func (r *Reader) ApplySystemPage(page *mfd.Page, header, systemname string, systemaddress int64, state *Journalstate) {
 	// Initialize a slice to hold lines for the page
 	lines := []string{}
+	page.ID = fmt.Sprintf("system:%d", systemaddress)
 	// Fetch system body information
-	sys, err := GetEDSMBodies(systemaddress)
+	sys, err := r.GetSystemBodies(systemaddress)
 	if err != nil {
-		log.Println("Error fetching EDSM data: ", err)
+		log.Println("Error fetching galaxy data: ", err)
 		return
 	}
 
-	// Fetch system monetary values
-	values, err := GetEDSMSystemValue(systemaddress)
+	// Fetch system monetary values, the journal alone does not know them
+	values, err := r.GetSystemValue(systemaddress)
 	if err != nil {
-		log.Println("Error fetching EDSM system value: ", err)
-		return
+		log.Println("Error fetching system value: ", err)
+		values = nil
 	}
 
 	mainBody := sys.MainStar()
@@ -280,7 +335,7 @@ func ApplySystemPage(page *mfd.Page, header, systemname string, systemaddress in
 		// Add FUEL indicator if star is scoopable
 		if mainBody.IsScoopable {
 
-			newHeader = lcdformat.SpaceBetween(16, header, "FUEL")
+			newHeader = mfd.SpaceBetween(header, "FUEL")
 			lines = append(lines, newHeader)
 		} else {
 			lines = append(lines, header)
@@ -300,29 +355,39 @@ func ApplySystemPage(page *mfd.Page, header, systemname string, systemaddress in
 	if state != nil && header == "NEXT JUMP" {
 		jumps = fmt.Sprintf("J:%d", state.EDSMTarget.RemainingJumpsInRoute)
 	}
-	lines = append(lines, lcdformat.SpaceBetween(16, fmt.Sprintf("CLS:%s", starTypeData.Class), jumps))
+	lines = append(lines, mfd.SpaceBetween(fmt.Sprintf("CLS:%s", starTypeData.Class), jumps))
 	// Add the main star information
 	lines = append(lines, starTypeData.Desc)
 	// Add system body count and estimated values
 
-	lines = append(lines, lcdformat.SpaceBetween(16, "Bodies:", printer.Sprintf("%d", sys.BodyCount)))
-	lines = append(lines, lcdformat.SpaceBetween(16, "Scan:", printer.Sprintf("%dcr", values.EstimatedValue)))
-	lines = append(lines, lcdformat.SpaceBetween(16, "Map:", printer.Sprintf("%dcr", values.EstimatedValueMapped)))
+	lines = append(lines, mfd.SpaceBetween("Bodies:", printer.Sprintf("%d", sys.BodyCount)))
+	if values != nil {
+		lines = append(lines, mfd.SpaceBetween("Scan:", printer.Sprintf("%dcr", values.EstimatedValue)))
+		lines = append(lines, mfd.SpaceBetween("Map:", printer.Sprintf("%dcr", values.EstimatedValueMapped)))
+	}
 
 	// Print valuable bodies if available
-	if len(values.ValuableBodies) > 0 {
-		lines = append(lines, lcdformat.FillAround(16, "*", " VAL BODIES "))
-		for _, valbody := range values.ValuableBodies {
+	if values != nil && len(values.ValuableBodies) > 0 {
+		valuableBodies := sortValuableBodies(values.ValuableBodies, *sys, r.bodySortOrder)
+		switch r.bodySortOrder {
+		case SortByDistance:
+			lines = append(lines, mfd.FillAround("*", " VAL BY DIST "))
+		case SortByName:
+			lines = append(lines, mfd.FillAround("*", " VAL BY NAME "))
+		default:
+			lines = append(lines, mfd.FillAround("*", " VAL BODIES "))
+		}
+		for _, valbody := range valuableBodies {
 			bodyName := valbody.ShortName(*sys)
 			crValue := printer.Sprintf("%dcr", valbody.ValueMax)
 			// append the body name and value to the lines
-			lines = append(lines, lcdformat.SpaceBetween(16, bodyName, crValue))
+			lines = append(lines, mfd.SpaceBetween(bodyName, crValue))
 		}
 	}
 
 	// Evaluate presence of landable bodies and materials
-	landables := []edsm.Body{}
-	matLocations := map[string][]edsm.Body{}
+	landables := []galaxy.Body{}
+	matLocations := map[string][]galaxy.Body{}
 	// Iterate through bodies to find landable bodies and their materials
 	for _, body := range sys.Bodies {
 		if body.IsLandable {
@@ -330,7 +395,7 @@ func ApplySystemPage(page *mfd.Page, header, systemname string, systemaddress in
 			for material := range body.Materials {
 				bodiesWithMat, ok := matLocations[material]
 				if !ok {
-					bodiesWithMat = []edsm.Body{}
+					bodiesWithMat = []galaxy.Body{}
 					matLocations[material] = bodiesWithMat
 				}
 				matLocations[material] = append(bodiesWithMat, body)
@@ -340,7 +405,7 @@ func ApplySystemPage(page *mfd.Page, header, systemname string, systemaddress in
 
 	// Add prospecting information if landable bodies are present
 	// if len(landables) > 0 {
-	// 	lines = append(lines, lcdformat.FillAround(16, "*", " PROSPECT "))
+	// 	lines = append(lines, mfd.FillAround("*", " PROSPECT "))
 	// 	materialList := []string{}
 
 	// 	for mat := range matLocations {
@@ -367,8 +432,8 @@ func ApplySystemPage(page *mfd.Page, header, systemname string, systemaddress in
 	// 		lines = append(lines, fmt.Sprintf("%s %d", material, len(bodiesWithMat)))
 	// 		b := bodiesWithMat[0]
 	// 		// Add the body name (number usually) and material percentage
-	// 		// matLine := lcdformat.SpaceBetween(16, b.ShortName(*sys), fmt.Sprintf("%.2f%%", float64(b.Materials[material])))
-	// 		matLine := lcdformat.SpaceBetween(16, b.ShortName(*sys), fmt.Sprintf("%.2f%%%%", b.Materials[material]))
+	// 		// matLine := mfd.SpaceBetween(b.ShortName(*sys), fmt.Sprintf("%.2f%%", float64(b.Materials[material])))
+	// 		matLine := mfd.SpaceBetween(b.ShortName(*sys), fmt.Sprintf("%.2f%%", b.Materials[material]))
 	// 		lines = append(lines, matLine)
 	// 	}
 	// } else {
@@ -377,42 +442,61 @@ func ApplySystemPage(page *mfd.Page, header, systemname string, systemaddress in
 
 	// Add all pages in slice to the MFD
 	for _, line := range lines {
-		page.Add(line)
+		page.Add("%s", line)
 	}
 }
 
-func ApplyBodyPage(page *mfd.Page, header string, systemAddress int64, bodyID int64, bodyName string) {
+// sortValuableBodies returns a copy of the valuable bodies, sorted in the given order
+func sortValuableBodies(bodies []galaxy.ValuableBody, sys galaxy.System, order BodySortOrder) []galaxy.ValuableBody {
+	sorted := make([]galaxy.ValuableBody, len(bodies))
+	copy(sorted, bodies)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		switch order {
+		case SortByDistance:
+			return a.Distance < b.Distance
+		case SortByName:
+			return a.ShortName(sys) < b.ShortName(sys)
+		default:
+			return a.ValueMax > b.ValueMax
+		}
+	})
+	return sorted
+}
+
+func (r *Reader) ApplyBodyPage(page *mfd.Page, header string, systemAddress int64, bodyID int64, bodyName string) {
 	lines := []string{}
+	page.ID = fmt.Sprintf("body:%d:%d", systemAddress, bodyID)
 
-	sys, err := GetEDSMBodies(systemAddress)
+	sys, err := r.GetSystemBodies(systemAddress)
 	if err != nil {
-		log.Println("Error fetching EDSM data: ", err)
-		lines = append(lines, lcdformat.FillAround(16, "*", " EDSM ERROR "))
+		log.Println("Error fetching galaxy data: ", err)
+		lines = append(lines, mfd.FillAround("*", " NO SYS DATA "))
 		for _, line := range lines {
-			page.Add(line)
+			page.Add("%s", line)
 		}
 		return
 	}
 
 	body := sys.BodyByID(bodyID)
 	if body.BodyID == 0 {
-		lines = append(lines, lcdformat.FillAround(16, "*", " NO BODY DATA "))
+		lines = append(lines, mfd.FillAround("*", " NO BODY DATA "))
 		for _, line := range lines {
-			page.Add(line)
+			page.Add("%s", line)
 		}
 		return
 	}
-	lines = append(lines, lcdformat.SpaceBetween(16, header, fmt.Sprintf("%.2fG", body.Gravity)))
+	lines = append(lines, mfd.SpaceBetween(header, fmt.Sprintf("%.2fG", body.Gravity)))
 	lines = append(lines, bodyName)
 	lines = append(lines, cases.Title(language.English).String(body.SubType))
 
 	// add the planet materials
-	lines = append(lines, lcdformat.FillAround(16, "*", " MATERIAL "))
+	lines = append(lines, mfd.FillAround("*", " MATERIAL "))
 	for _, m := range body.MaterialsSorted() {
-		lines = append(lines, lcdformat.SpaceBetween(16, fmt.Sprintf("%5.2f%%%%", m.Percentage), m.Name))
+		lines = append(lines, mfd.SpaceBetween(fmt.Sprintf("%5.2f%%", m.Percentage), m.Name))
 	}
 	for _, line := range lines {
-		page.Add(line)
+		page.Add("%s", line)
 	}
 }
 
@@ -426,6 +510,9 @@ func ParseStarTypeString(starType string) StarTypeData {
 	// Example input: K (Yellow-Orange) Star
 	splitST := strings.Split(starType, " ")
 	class := splitST[0]
+	if len(splitST) < 2 {
+		return StarTypeData{Class: class}
+	}
 	description := strings.ReplaceAll(splitST[1], "(", "")
 	description = strings.ReplaceAll(description, ")", "")
 	description = fmt.Sprintf("%s %s", description, "Star")
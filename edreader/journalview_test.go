@@ -0,0 +1,186 @@
+package edreader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pellux-network/EDxDC/galaxy"
+	"github.com/pellux-network/EDxDC/mfd"
+)
+
+func TestSelectLocationPage(t *testing.T) {
+	tests := []struct {
+		clicks int
+		want   BodySortOrder
+	}{
+		{0, SortByValue},
+		{1, SortByDistance},
+		{2, SortByName},
+		// Back to the start after the last sort order
+		{3, SortByValue},
+		{4, SortByDistance},
+	}
+	for _, test := range tests {
+		r := &Reader{}
+		for range test.clicks {
+			r.SelectLocationPage(&r.state)
+		}
+		if r.bodySortOrder != test.want {
+			t.Errorf("after %d clicks got sort order %d, wanted %d", test.clicks, r.bodySortOrder, test.want)
+		}
+	}
+}
+
+func TestSortValuableBodies(t *testing.T) {
+	sys := galaxy.System{Name: "Sol"}
+	bodies := []galaxy.ValuableBody{
+		{BodyName: "Sol C 1", Distance: 10, ValueMax: 300},
+		{BodyName: "Sol A", Distance: 900, ValueMax: 100},
+		{BodyName: "Sol B 2", Distance: 5, ValueMax: 300},
+	}
+	tests := []struct {
+		order BodySortOrder
+		want  []string
+	}{
+		// Bodies of the same value stay in the order they came in
+		{SortByValue, []string{"C 1", "B 2", "A"}},
+		{SortByDistance, []string{"B 2", "C 1", "A"}},
+		{SortByName, []string{"A", "B 2", "C 1"}},
+	}
+	for _, test := range tests {
+		var names []string
+		for _, body := range sortValuableBodies(bodies, sys, test.order) {
+			names = append(names, body.ShortName(sys))
+		}
+		if diff := cmp.Diff(test.want, names); diff != "" {
+			t.Errorf("sort order %d differs (-want +got):\n%s", test.order, diff)
+		}
+	}
+	if bodies[0].BodyName != "Sol C 1" || bodies[2].BodyName != "Sol B 2" {
+		t.Errorf("sorting changed the bodies passed in to %v", bodies)
+	}
+}
+
+func TestSelectCargoPage(t *testing.T) {
+	tests := []struct {
+		name   string
+		cargo  Cargo
+		clicks int
+		want   mfd.Page
+	}{
+		{
+			name:  "all cargo",
+			cargo: Cargo{Count: 3, Inventory: []CargoLine{{Name: "gold", Count: 2}, {Name: "silver", Count: 1, Stolen: 1}}},
+			want:  mfd.Page{ID: "cargo", Lines: []string{"CARGO: 0003/0064", "Gold           2", "Silver         1"}},
+		},
+		{
+			name:   "stolen only",
+			cargo:  Cargo{Count: 3, Inventory: []CargoLine{{Name: "gold", Count: 2}, {Name: "silver", Count: 1, Stolen: 1}}},
+			clicks: 1,
+			want:   mfd.Page{ID: "cargo:stolen", Lines: []string{"STOLN: 0001/0064", "Silver         1"}},
+		},
+		{
+			name:   "nothing stolen",
+			cargo:  Cargo{Count: 2, Inventory: []CargoLine{{Name: "gold", Count: 2}}},
+			clicks: 1,
+			want:   mfd.Page{ID: "cargo:stolen", Lines: []string{"STOLN: 0000/0064", "** NO STOLEN ***"}},
+		},
+		{
+			name:   "back to all cargo",
+			cargo:  Cargo{Count: 2, Inventory: []CargoLine{{Name: "gold", Count: 2}}},
+			clicks: 2,
+			want:   mfd.Page{ID: "cargo", Lines: []string{"CARGO: 0002/0064", "Gold           2"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newTestReader(t)
+			r.cargo = test.cargo
+			for range test.clicks {
+				r.SelectCargoPage(&r.state)
+			}
+			page := mfd.NewPage()
+			r.RenderCargoPage(&page, r.state)
+			if diff := cmp.Diff(test.want, page); diff != "" {
+				t.Errorf("page differs (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// cacheCounter counts how often the cache of the galaxy provider is cleared
+type cacheCounter struct {
+	galaxy.Provider
+	cleared int
+}
+
+func (c *cacheCounter) ClearCache() {
+	c.cleared++
+}
+
+func TestSelectDestinationPage(t *testing.T) {
+	tests := []struct {
+		name    string
+		arrived bool
+		// cleared is whether the galaxy information is fetched again
+		cleared bool
+	}{
+		{name: "arrived", arrived: true},
+		{name: "travelling", cleared: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newTestReader(t)
+			counter := &cacheCounter{Provider: r.galaxy}
+			r.galaxy = counter
+			r.state.ArrivedAtFSDTarget = test.arrived
+			if test.arrived {
+				r.state.ArrivedAtFSDTargetTime = time.Now()
+			}
+
+			r.SelectDestinationPage(&r.state)
+			if r.state.ArrivedAtFSDTarget || !r.state.ArrivedAtFSDTargetTime.IsZero() {
+				t.Error("the arrival is still shown")
+			}
+			if cleared := counter.cleared > 0; cleared != test.cleared {
+				t.Errorf("cache cleared: %v, wanted %v", cleared, test.cleared)
+			}
+		})
+	}
+}
+
+func TestHandleSelect(t *testing.T) {
+	tests := []struct {
+		name string
+		page uint32
+		// changed tells if the click went to the handler of the page
+		changed func(r *Reader) bool
+	}{
+		{"destination", 0, func(r *Reader) bool { return !r.state.ArrivedAtFSDTarget }},
+		{"location", 1, func(r *Reader) bool { return r.bodySortOrder == SortByDistance }},
+		{"cargo", 2, func(r *Reader) bool { return r.cargoStolenOnly }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newTestReader(t)
+			r.state.ArrivedAtFSDTarget = true
+			r.handleSelect(test.page)
+			for _, other := range tests {
+				if changed := other.changed(r); changed != (other.page == test.page) {
+					t.Errorf("%s page changed: %v", other.name, changed)
+				}
+			}
+		})
+	}
+
+	// Clicks on pages that are not shown are dropped
+	r := newTestReader(t)
+	r.state.ArrivedAtFSDTarget = true
+	r.handleSelect(3)
+	for _, test := range tests {
+		if test.changed(r) {
+			t.Errorf("%s page changed by a click on a page not shown", test.name)
+		}
+	}
+}
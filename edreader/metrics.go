@@ -0,0 +1,117 @@
+package edreader
+
+import (
+	"sync"
+	"time"
+)
+
+// Metrics tells how much work the reader does to keep the pages up to date
+type Metrics struct {
+	// Changes counts the changes of the journal folder told by the watcher, and Updates the updates
+	// they were coalesced into
+	Changes uint64 `json:"changes"`
+	Updates uint64 `json:"updates"`
+	// PagesRendered counts the pages rendered, and PagesCached the pages shown as rendered before, as
+	// nothing they show changed
+	PagesRendered uint64 `json:"pagesRendered"`
+	PagesCached   uint64 `json:"pagesCached"`
+	// ChangesPerSecond and UpdatesPerSecond are the rates over the last minute
+	ChangesPerSecond float64 `json:"changesPerSecond"`
+	UpdatesPerSecond float64 `json:"updatesPerSecond"`
+}
+
+// metrics counts the work of the reader routine, for Metrics
+type metrics struct {
+	mu            sync.Mutex
+	changes       uint64
+	updates       uint64
+	pagesRendered uint64
+	pagesCached   uint64
+	changeRate    rate
+	updateRate    rate
+}
+
+func (m *metrics) changed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.changes++
+	m.changeRate.add(time.Now())
+}
+
+func (m *metrics) updated() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.updates++
+	m.updateRate.add(time.Now())
+}
+
+func (m *metrics) rendered() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pagesRendered++
+}
+
+func (m *metrics) cached() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pagesCached++
+}
+
+// Metrics returns how much work the reader did so far
+func (r *Reader) Metrics() Metrics {
+	m := &r.metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	return Metrics{
+		Changes:          m.changes,
+		Updates:          m.updates,
+		PagesRendered:    m.pagesRendered,
+		PagesCached:      m.pagesCached,
+		ChangesPerSecond: m.changeRate.perSecond(now),
+		UpdatesPerSecond: m.updateRate.perSecond(now),
+	}
+}
+
+// rateSeconds is the number of seconds the rates are averaged over
+const rateSeconds = 60
+
+// rate counts events by second, for the rate over the last seconds
+type rate struct {
+	counts [rateSeconds]uint64
+	// first and last are the Unix seconds of the first event and the newest second counted
+	first, last int64
+}
+
+func (r *rate) add(now time.Time) {
+	sec := now.Unix()
+	if r.first == 0 {
+		r.first, r.last = sec, sec
+	}
+	r.advance(sec)
+	r.counts[sec%rateSeconds]++
+}
+
+// advance clears the counts of the seconds passed since the newest second counted
+func (r *rate) advance(sec int64) {
+	for s := r.last + 1; s <= sec && s <= r.last+rateSeconds; s++ {
+		r.counts[s%rateSeconds] = 0
+	}
+	r.last = max(r.last, sec)
+}
+
+// perSecond returns the average rate over the last seconds, or since the first event if that was
+// less long ago
+func (r *rate) perSecond(now time.Time) float64 {
+	if r.first == 0 {
+		return 0
+	}
+	sec := now.Unix()
+	r.advance(sec)
+	var sum uint64
+	for _, count := range r.counts {
+		sum += count
+	}
+	seconds := min(sec-r.first+1, rateSeconds)
+	return float64(sum) / float64(seconds)
+}
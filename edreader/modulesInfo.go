@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/pellux-network/EDxDC/bus"
 	"github.com/pellux-network/EDxDC/logging"
 	"github.com/rs/zerolog/log"
 )
@@ -22,32 +23,31 @@ type ModulesLine struct {
 	Item string
 }
 
-var currentModules ModulesInfo
-var currentCargoCapacity int
-
-func handleModulesInfoFile(file string) {
+func (r *Reader) handleModulesInfoFile(file string) {
 	data, err := os.ReadFile(file)
 	if err != nil {
 		log.Warn().Err(err).Str("file", logging.CleanPath(file)).Msg("Failed to read ModulesInfo file")
-		currentModules = ModulesInfo{}
+		bus.Publish(r.events, ModulesEvent{})
 		return
 	}
 	var modules ModulesInfo
 	if err := json.Unmarshal(data, &modules); err != nil {
 		log.Error().Err(err).Str("file", logging.CleanPath(file)).Msg("Failed to unmarshal ModulesInfo file")
-		currentModules = ModulesInfo{}
+		bus.Publish(r.events, ModulesEvent{})
 		return
 	}
-	currentModules = modules
+	bus.Publish(r.events, ModulesEvent{Modules: modules})
 }
 
-func ModulesInfoCargoCapacity() int {
-	if currentCargoCapacity > 0 {
-		return currentCargoCapacity
+// ModulesInfoCargoCapacity returns the cargo capacity of the ship, from the Loadout event or else
+// from the cargo racks in ModulesInfo.json
+func (r *Reader) ModulesInfoCargoCapacity() int {
+	if c := r.activeCommander(); c.cargoCapacity > 0 {
+		return c.cargoCapacity
 	}
 	cargoCapacity := 0
 
-	for i, line := range currentModules.Modules {
+	for i, line := range r.modules.Modules {
 		log.Debug().
 			Int("index", i).
 			Str("slot", line.Slot).
@@ -0,0 +1,32 @@
+package edreader
+
+import (
+	"github.com/pellux-network/EDxDC/mfd"
+)
+
+// PagePreview is a registered page rendered from the current state
+type PagePreview struct {
+	Key         PageKey  `json:"key"`
+	DisplayName string   `json:"displayName"`
+	Enabled     bool     `json:"enabled"`
+	Page        mfd.Page `json:"page"`
+}
+
+// PreviewPages returns all registered pages as rendered from the current state, including the ones that
+// are not enabled for the active commander. The pages are taken from the cache, so only those whose
+// state changed since they were last rendered are rendered again.
+func (r *Reader) PreviewPages() []PagePreview {
+	var previews []PagePreview
+	r.onReader(func() {
+		enabled := r.cfg.ForCommander(r.state.Commander).Pages
+		for _, pageDef := range r.pages {
+			previews = append(previews, PagePreview{
+				Key:         pageDef.Key,
+				DisplayName: pageDef.DisplayName,
+				Enabled:     enabled[string(pageDef.Key)],
+				Page:        r.cachedPage(pageDef).Copy(),
+			})
+		}
+	})
+	return previews
+}
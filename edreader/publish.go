@@ -0,0 +1,65 @@
+package edreader
+
+import (
+	"bytes"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pellux-network/EDxDC/bus"
+	"github.com/pellux-network/EDxDC/mfd"
+)
+
+// CurrentView returns the game state as of the last update
+func (r *Reader) CurrentView() View {
+	r.viewLock.RLock()
+	defer r.viewLock.RUnlock()
+	return r.publishedView
+}
+
+// CurrentDisplay returns the rendered MFD pages as of the last update
+func (r *Reader) CurrentDisplay() mfd.Display {
+	r.displayLock.RLock()
+	defer r.displayLock.RUnlock()
+	return r.rendered.Copy()
+}
+
+// AddDisplayListener registers a function that is called with the new pages and game state whenever
+// the content of the MFD changes. Listeners are called from their own routine; if they fall behind,
+// only the latest pages are passed on.
+func (r *Reader) AddDisplayListener(listener func(mfd.Display, View)) {
+	Subscribe(r, bus.Options{Name: "display listener", Queue: 16, Policy: bus.DropOldest}, func(e DisplayEvent) {
+		listener(e.Display, e.View)
+	})
+}
+
+// AddStateListener registers a function that is called with the new game state whenever it changes.
+// Listeners are called from their own routine; if they fall behind, only the latest state is passed on.
+func (r *Reader) AddStateListener(listener func(View)) {
+	Subscribe(r, bus.Options{Name: "state listener", Queue: 16, Policy: bus.DropOldest}, func(e ViewEvent) {
+		listener(e.View)
+	})
+}
+
+// AddJournalListener registers a function that is called for every journal line written while the
+// reader runs, with the name of the event and the state after parsing it. Listeners are called from
+// their own routine and get every line; the reader, and with it the MFD, waits when ListenerQueue
+// lines are waiting for a listener. Listeners should hand slow work, such as uploads, on to a routine
+// of their own, like the uploaders queueing to an outbox.Sender.
+func (r *Reader) AddJournalListener(listener func(event string, line []byte, state Journalstate)) {
+	Subscribe(r, bus.Options{Name: "journal listener", Queue: ListenerQueue, Policy: bus.Block}, func(e JournalEvent) {
+		if e.Replay {
+			return
+		}
+		listener(e.Event.EventName(), bytes.Clone(e.Event.Line()), e.State)
+	})
+}
+
+func (r *Reader) publishView(view View) {
+	r.viewLock.Lock()
+	changed := !cmp.Equal(view, r.publishedView, cmpopts.IgnoreUnexported(View{}))
+	r.publishedView = view
+	r.viewLock.Unlock()
+	if changed {
+		bus.Publish(r.events, ViewEvent{View: view})
+	}
+}
@@ -0,0 +1,75 @@
+package edreader
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pellux-network/EDxDC/journal"
+)
+
+func TestJournalListenerSkipsReplay(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	r := newTestReaderWith(t, func(r *Reader) {
+		r.AddJournalListener(func(event string, line []byte, state Journalstate) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, event+" "+state.Commander)
+		})
+	})
+	appendJournal(t, r,
+		`{ "timestamp":"2025-01-01T10:00:02Z", "event":"FSDTarget", "Name":"Alpha Centauri", "SystemAddress":3932277478106 }`,
+		`{ "timestamp":"2025-01-01T10:00:03Z", "event":"Music", "MusicTrack":"Exploration" }`,
+	)
+	// Closing lets the listener finish the lines passed on
+	r.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"FSDTarget Bench", "Music Bench"}
+	if diff := cmp.Diff(want, events); diff != "" {
+		t.Errorf("listener got lines written before the reader ran (-want +got):\n%s", diff)
+	}
+}
+
+func TestJournalListenerBackpressure(t *testing.T) {
+	unblock := make(chan struct{})
+	r := newTestReaderWith(t, func(r *Reader) {
+		r.AddJournalListener(func(event string, line []byte, state Journalstate) { <-unblock })
+	})
+	update := func(lines int) <-chan struct{} {
+		music := make([]string, lines)
+		for i := range music {
+			music[i] = `{ "timestamp":"2025-01-01T10:00:03Z", "event":"Music", "MusicTrack":"Exploration" }`
+		}
+		writeJournal(t, r, music...)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			r.update(journal.FolderChange{Files: map[string]bool{testJournal: true}}, false)
+		}()
+		return done
+	}
+
+	// One line is with the listener and the others wait in its queue
+	select {
+	case <-update(ListenerQueue + 1):
+	case <-time.After(5 * time.Second):
+		t.Fatal("the reader waited for the listener before its queue was full")
+	}
+	// With the queue full, the reader waits for the listener
+	done := update(1)
+	select {
+	case <-done:
+		t.Fatal("the reader did not wait for the listener with its queue full")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(unblock)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the reader did not go on once the listener caught up")
+	}
+}
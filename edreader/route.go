@@ -0,0 +1,171 @@
+package edreader
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pellux-network/EDxDC/clipboard"
+	"github.com/pellux-network/EDxDC/journal"
+	"github.com/pellux-network/EDxDC/logging"
+	"github.com/pellux-network/EDxDC/mfd"
+	"github.com/pellux-network/EDxDC/spansh"
+	"github.com/rs/zerolog/log"
+)
+
+// PageRoute shows the progress along a route plotted with Spansh
+const PageRoute PageKey = "route"
+
+// plottedRoute is the route being followed, and the time of the last jump counted towards it
+type plottedRoute struct {
+	Progress *spansh.Progress `json:"progress"`
+	LastJump time.Time        `json:"lastJump"`
+}
+
+// loadRoute loads the route followed in an earlier run from the file, and saves routes to it from
+// now on
+func (r *Reader) loadRoute(path string) {
+	r.routeFile = path
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var saved plottedRoute
+	if err := json.Unmarshal(data, &saved); err != nil || saved.Progress == nil {
+		log.Warn().Err(err).Str("path", logging.CleanPath(path)).Msg("Ignoring unreadable route file")
+		return
+	}
+	r.route = &saved
+	log.Info().Str("to", saved.Progress.Route.To).Int("next", saved.Progress.Next).Msg("Loaded plotted route")
+}
+
+// SetRoute starts following the route from the current system
+func (r *Reader) SetRoute(route spansh.Route) {
+	r.onReader(func() {
+		r.route = &plottedRoute{
+			Progress: spansh.NewProgress(route, r.state.StarSystem, r.state.Location.SystemAddress),
+			LastJump: time.Now(),
+		}
+		r.saveRoute()
+		log.Info().Str("kind", string(route.Kind)).Str("to", route.To).Int("waypoints", len(route.Waypoints)).Msg("Following plotted route")
+		r.renderMFD(DependsRoute)
+	})
+}
+
+// Loadout returns the last Loadout event of the active commander, which describes the current ship, or nil if
+// there was none yet
+func (r *Reader) Loadout() []byte {
+	var loadout []byte
+	r.onReader(func() {
+		loadout = bytes.Clone(r.activeCommander().loadout)
+	})
+	return loadout
+}
+
+// ClearRoute stops following the plotted route
+func (r *Reader) ClearRoute() {
+	r.onReader(func() {
+		r.route = nil
+		r.saveRoute()
+		r.renderMFD(DependsRoute)
+	})
+}
+
+func (r *Reader) saveRoute() {
+	if r.routeFile == "" {
+		return
+	}
+	if r.route == nil {
+		if err := os.Remove(r.routeFile); err != nil && !os.IsNotExist(err) {
+			log.Warn().Err(err).Msg("Failed to remove route file")
+		}
+		return
+	}
+	data, err := json.Marshal(r.route)
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(r.routeFile, data, 0644); err != nil {
+		log.Warn().Err(err).Str("path", logging.CleanPath(r.routeFile)).Msg("Failed to save route")
+	}
+}
+
+// eRouteJump moves along the plotted route. Jumps from before the route was plotted, or that were
+// already counted before a restart, are ignored.
+func (r *Reader) eRouteJump(jumpTime time.Time, pos journal.Position) {
+	if r.route == nil || !jumpTime.After(r.route.LastJump) {
+		return
+	}
+	r.route.Progress.Jumped(pos.StarSystem, pos.SystemAddress)
+	r.route.LastJump = jumpTime
+	r.saveRoute()
+}
+
+// RenderRoutePage shows the next waypoint, and the jumps and distance left
+func (r *Reader) RenderRoutePage(page *mfd.Page, _ Journalstate) {
+	lines := []string{}
+	if r.route == nil {
+		page.ID = "route"
+		lines = append(lines, mfd.Center("ROUTE"))
+		lines = append(lines, mfd.FillAround("*", " NO ROUTE "))
+		for _, line := range lines {
+			page.Add("%s", line)
+		}
+		return
+	}
+
+	progress := r.route.Progress
+	header := strings.ToUpper(string(progress.Route.Kind))
+	next, ok := progress.NextWaypoint()
+	if !ok {
+		page.ID = "route:done"
+		lines = append(lines, mfd.Center(header))
+		lines = append(lines, mfd.FillAround("*", " ARRIVED "))
+		lines = append(lines, progress.Route.To)
+		for _, line := range lines {
+			page.Add("%s", line)
+		}
+		return
+	}
+
+	page.ID = fmt.Sprintf("route:%d", progress.Next)
+	lines = append(lines, mfd.SpaceBetween(header, fmt.Sprintf("%d/%d", progress.Next, len(progress.Route.Waypoints)-1)))
+	lines = append(lines, next.System)
+	lines = append(lines, mfd.SpaceBetween(fmt.Sprintf("J:%d", progress.JumpsLeft()), fmt.Sprintf("%.0fLY", progress.DistanceLeft())))
+	if next.Neutron {
+		lines = append(lines, "NEUTRON STAR")
+	}
+	for _, body := range next.Bodies {
+		lines = append(lines, shortName(next.System, body))
+	}
+	for _, line := range lines {
+		page.Add("%s", line)
+	}
+}
+
+// SelectRoutePage copies the name of the next waypoint to the clipboard, to paste it in the galaxy map
+func (r *Reader) SelectRoutePage(_ *Journalstate) {
+	if r.route == nil {
+		return
+	}
+	next, ok := r.route.Progress.NextWaypoint()
+	if !ok {
+		return
+	}
+	if err := clipboard.Write(next.System); err != nil {
+		log.Warn().Err(err).Msg("Failed to copy waypoint to clipboard")
+		return
+	}
+	log.Info().Str("system", next.System).Msg("Copied next waypoint to clipboard")
+}
+
+// shortName returns the name of the body without the system name prefix
+func shortName(system, body string) string {
+	if short := strings.TrimPrefix(body, system+" "); short != "" {
+		return short
+	}
+	return body
+}
@@ -0,0 +1,214 @@
+package edreader
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pellux-network/EDxDC/bus"
+	"github.com/pellux-network/EDxDC/journal"
+	"github.com/pellux-network/EDxDC/logging"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+ The state of the reader is saved to a snapshot file whenever it changes, so that after a restart the
+ journals are read on from where the reader stopped instead of from the start. Each journal file in the
+ snapshot keeps a checksum of the bytes before the position; if the file no longer matches, the
+ snapshot is dropped and the journal read again.
+*/
+
+const (
+	// snapshotVersion changes when the snapshot file can no longer be read by this version
+	snapshotVersion = 1
+	// snapshotCheckSize is the number of bytes before the journal position that are checked
+	snapshotCheckSize = 1024
+	// snapshotFollowFinished is how long journals that were read to the end are followed after a
+	// restart, in case the game client writing them is still running
+	snapshotFollowFinished = 24 * time.Hour
+)
+
+// snapshot is the state of the reader as saved to the snapshot file
+type snapshot struct {
+	Version    int                          `json:"version"`
+	Journals   map[string]snapshotJournal   `json:"journals"`
+	Active     string                       `json:"active"`
+	Commanders map[string]snapshotCommander `json:"commanders"`
+	Cargo      Cargo                        `json:"cargo"`
+	Modules    ModulesInfo                  `json:"modules"`
+}
+
+// snapshotJournal is how far a journal file was read, by file name
+type snapshotJournal struct {
+	Offset    int64  `json:"offset"`
+	Line      int    `json:"line"`
+	Commander string `json:"commander"`
+	// Check is the checksum of the bytes before the offset
+	Check uint64 `json:"check"`
+}
+
+// snapshotCommander is the state of a commander, by Frontier ID
+type snapshotCommander struct {
+	Commander
+	State         Journalstate      `json:"state"`
+	Loadout       json.RawMessage   `json:"loadout,omitempty"`
+	CargoCapacity int               `json:"cargoCapacity,omitempty"`
+	Carriers      map[string]string `json:"carriers,omitempty"`
+}
+
+// takeSnapshot returns the state of the reader. The journal checksums are left out, as they are only
+// needed when the state changed.
+func (r *Reader) takeSnapshot() snapshot {
+	s := snapshot{
+		Version:    snapshotVersion,
+		Journals:   map[string]snapshotJournal{},
+		Active:     r.active,
+		Commanders: map[string]snapshotCommander{},
+		Cargo:      r.cargo,
+		Modules:    r.modules,
+	}
+	for filename, pos := range r.tailer.Positions() {
+		s.Journals[filepath.Base(filename)] = snapshotJournal{Offset: pos.Offset, Line: pos.Line, Commander: r.fileCommanders[filename]}
+	}
+	for fid, c := range r.commanders {
+		if fid == "" {
+			continue
+		}
+		sc := snapshotCommander{
+			Commander:     c.Commander,
+			State:         r.stateOf(fid),
+			Loadout:       c.loadout,
+			CargoCapacity: c.cargoCapacity,
+			Carriers:      maps.Clone(c.carriers),
+		}
+		sc.Missions = slices.Clone(sc.Missions)
+		sc.Materials = maps.Clone(sc.Materials)
+		// The splash screen is shown again on the next start
+		sc.State.ShowSplashScreen = false
+		sc.State.SplashScreenStartTime = time.Time{}
+		s.Commanders[fid] = sc
+	}
+	return s
+}
+
+// saveSnapshot passes the state on to be saved, if it changed since it was last saved
+func (r *Reader) saveSnapshot() {
+	if r.snapshotFile == "" {
+		return
+	}
+	s := r.takeSnapshot()
+	if cmp.Equal(s, r.savedSnapshot) {
+		return
+	}
+	r.savedSnapshot = s
+	bus.Publish(r.events, s)
+}
+
+// writeSnapshot writes the snapshot to the file, replacing it only once it is written completely
+func (r *Reader) writeSnapshot(s snapshot) {
+	journals := make(map[string]snapshotJournal, len(s.Journals))
+	for name, saved := range s.Journals {
+		saved.Check = journalCheck(filepath.Join(r.folder, name), saved.Offset)
+		journals[name] = saved
+	}
+	s.Journals = journals
+	data, err := json.Marshal(s)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to encode state snapshot")
+		return
+	}
+	tmp := r.snapshotFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Warn().Err(err).Msg("Failed to save state snapshot")
+		return
+	}
+	if err := os.Rename(tmp, r.snapshotFile); err != nil {
+		log.Warn().Err(err).Msg("Failed to save state snapshot")
+	}
+}
+
+// restoreSnapshot restores the state saved by the last run, if the journal files still match it
+func (r *Reader) restoreSnapshot() {
+	if r.snapshotFile == "" {
+		return
+	}
+	data, err := os.ReadFile(r.snapshotFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn().Err(err).Msg("Failed to read state snapshot")
+		}
+		return
+	}
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil || s.Version != snapshotVersion {
+		log.Info().Err(err).Msg("Ignoring outdated state snapshot")
+		return
+	}
+	for name, saved := range s.Journals {
+		filename := filepath.Join(r.folder, name)
+		info, err := os.Stat(filename)
+		if err == nil && info.Size() == saved.Offset && time.Since(info.ModTime()) > snapshotFollowFinished {
+			// Not worth following any more
+			delete(s.Journals, name)
+			continue
+		}
+		if os.IsNotExist(err) {
+			delete(s.Journals, name)
+			continue
+		}
+		if journalCheck(filename, saved.Offset) != saved.Check {
+			log.Info().Str("filename", logging.CleanPath(filename)).Msg("Journal changed since the state snapshot, reading it again")
+			return
+		}
+	}
+
+	for name, saved := range s.Journals {
+		filename := filepath.Join(r.folder, name)
+		r.tailer.Follow(filename, journal.FilePosition{Offset: saved.Offset, Line: saved.Line})
+		r.fileCommanders[filename] = saved.Commander
+	}
+	for fid, sc := range s.Commanders {
+		c := r.commander(fid)
+		c.Commander = sc.Commander
+		c.state = sc.State
+		c.loadout = sc.Loadout
+		c.cargoCapacity = sc.CargoCapacity
+		if sc.Carriers != nil {
+			c.carriers = sc.Carriers
+		}
+	}
+	r.cargo = s.Cargo
+	r.modules = s.Modules
+	if s.Active != "" {
+		r.activate(s.Active)
+	}
+	r.savedSnapshot = r.takeSnapshot()
+	log.Info().Int("journals", len(s.Journals)).Str("commander", r.state.Commander).Msg("Restored state snapshot")
+}
+
+// journalCheck returns the checksum of the bytes of the journal file before the offset, or 0 if the
+// file is shorter
+func journalCheck(filename string, offset int64) uint64 {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err != nil || info.Size() < offset {
+		return 0
+	}
+	start := max(offset-snapshotCheckSize, 0)
+	data := make([]byte, offset-start)
+	if _, err := io.ReadFull(io.NewSectionReader(file, start, offset-start), data); err != nil {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write(data)
+	return h.Sum64()
+}
@@ -0,0 +1,106 @@
+package edreader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pellux-network/EDxDC/bus"
+)
+
+// snapshotReader returns a reader of the folder restoring the snapshot, and the numbers of the
+// journal lines its first update read
+func snapshotReader(t *testing.T, dir, snapshotFile string) (*Reader, *[]int) {
+	t.Helper()
+	var lines []int
+	r := newFolderReader(t, dir, func(r *Reader) {
+		Subscribe(r, bus.Options{Name: "lines read"}, func(e JournalEvent) { lines = append(lines, e.Line) })
+		r.snapshotFile = snapshotFile
+		r.restoreSnapshot()
+	})
+	return r, &lines
+}
+
+// saveTestSnapshot reads the folder and saves its snapshot to the file
+func saveTestSnapshot(t *testing.T, dir, snapshotFile string) {
+	t.Helper()
+	r, lines := snapshotReader(t, dir, snapshotFile)
+	r.Close()
+	if len(*lines) != 4 {
+		t.Fatalf("read lines %v without a snapshot, wanted the whole journal", *lines)
+	}
+	if _, err := os.Stat(snapshotFile); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	dir := newTestFolder(t)
+	snapshotFile := filepath.Join(t.TempDir(), "snapshot.json")
+	saveTestSnapshot(t, dir, snapshotFile)
+
+	// The game goes on writing while the reader is not running
+	f, err := os.OpenFile(filepath.Join(dir, testJournal), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{ "timestamp":"2025-01-01T10:00:02Z", "event":"FSDTarget", "Name":"Alpha Centauri", "SystemAddress":3932277478106 }` + "\n")
+	f.Close()
+
+	r, lines := snapshotReader(t, dir, snapshotFile)
+	if diff := cmp.Diff([]int{5}, *lines); diff != "" {
+		t.Errorf("read lines other than the new one (-want +got):\n%s", diff)
+	}
+	if r.state.Commander != "Bench" || r.state.Location.StarSystem != "Sol" {
+		t.Errorf("got commander %q in %q, wanted the state of the snapshot", r.state.Commander, r.state.Location.StarSystem)
+	}
+	if len(r.cargo.Inventory) != 2 {
+		t.Errorf("got cargo %v, wanted the cargo of the snapshot", r.cargo.Inventory)
+	}
+}
+
+func TestSnapshotRewrittenJournal(t *testing.T) {
+	dir := newTestFolder(t)
+	snapshotFile := filepath.Join(t.TempDir(), "snapshot.json")
+	saveTestSnapshot(t, dir, snapshotFile)
+
+	// The journal is replaced by one of the same length
+	path := filepath.Join(dir, testJournal)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(strings.ReplaceAll(string(data), "Bench", "Other")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, lines := snapshotReader(t, dir, snapshotFile)
+	if diff := cmp.Diff([]int{1, 2, 3, 4}, *lines); diff != "" {
+		t.Errorf("did not read the changed journal again (-want +got):\n%s", diff)
+	}
+	if r.state.Commander != "Other" {
+		t.Errorf("got commander %q, wanted the one of the changed journal", r.state.Commander)
+	}
+}
+
+func TestSnapshotFinishedJournal(t *testing.T) {
+	dir := newTestFolder(t)
+	snapshotFile := filepath.Join(t.TempDir(), "snapshot.json")
+	saveTestSnapshot(t, dir, snapshotFile)
+
+	// The journal was read to the end and not written since the day before
+	path := filepath.Join(dir, testJournal)
+	old := time.Now().Add(-snapshotFollowFinished - time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// It is no longer followed from the snapshot, so it is read as the newest journal, as without one
+	_, lines := snapshotReader(t, dir, snapshotFile)
+	if diff := cmp.Diff([]int{1, 2, 3, 4}, *lines); diff != "" {
+		t.Errorf("followed the finished journal from the snapshot (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,52 @@
+package edreader
+
+import "strings"
+
+// FileStatus is the name of the status file written by the game
+const FileStatus = "Status.json"
+
+// Status holds the ship and commander status from Status.json
+type Status struct {
+	Flags      uint32
+	Flags2     uint32
+	Pips       []int
+	FireGroup  int
+	GuiFocus   int
+	Fuel       StatusFuel
+	Cargo      float64
+	LegalState string
+	Balance    int64
+
+	Latitude  float64
+	Longitude float64
+	Altitude  float64
+	Heading   int
+	BodyName  string
+
+	// Destination is the target selected in the game, if any
+	Destination *StatusDestination `json:",omitempty"`
+}
+
+// StatusDestination is the target selected in the game
+type StatusDestination struct {
+	System        int64
+	Body          int64
+	Name          string
+	NameLocalised string `json:"Name_Localised,omitempty"`
+}
+
+// DisplayName returns the name of the target, localised if the game only wrote a symbol
+func (d StatusDestination) DisplayName() string {
+	if d.Name == "" || strings.HasPrefix(d.Name, "$") {
+		if d.NameLocalised != "" {
+			return d.NameLocalised
+		}
+	}
+	return d.Name
+}
+
+// StatusFuel holds the fuel levels of the ship
+type StatusFuel struct {
+	FuelMain      float64
+	FuelReservoir float64
+}
@@ -0,0 +1,175 @@
+package edreader
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/pellux-network/EDxDC/galaxy"
+	"github.com/pellux-network/EDxDC/logging"
+	"github.com/pellux-network/EDxDC/mfd"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+// View is the game state available to page templates and external tools
+type View struct {
+	Journal       Journalstate `json:"journal"`
+	Cargo         Cargo        `json:"cargo"`
+	CargoCapacity int          `json:"cargoCapacity"`
+	Modules       ModulesInfo  `json:"modules"`
+	Status        Status       `json:"status"`
+	Commander     Commander    `json:"commander"`
+
+	// reader looks up the galaxy information for templates
+	reader *Reader
+}
+
+// currentView returns the view of the current game state. Views are passed to other routines, so
+// the cargo inventory is copied rather than shared with the reader.
+func (r *Reader) currentView(state Journalstate) View {
+	cargo := r.cargo
+	cargo.Inventory = slices.Clone(cargo.Inventory)
+	return View{
+		Journal:       state,
+		Cargo:         cargo,
+		CargoCapacity: r.ModulesInfoCargoCapacity(),
+		Modules:       r.modules,
+		Status:        r.status,
+		Commander:     r.commanderView(),
+		reader:        r,
+	}
+}
+
+// System returns the body information of the current system, or nil if it is not available
+func (v View) System() *galaxy.System {
+	if v.reader == nil {
+		return nil
+	}
+	sys, err := v.reader.GetSystemBodies(v.Journal.Location.SystemAddress)
+	if err != nil {
+		return nil
+	}
+	return sys
+}
+
+// SystemValue returns the estimated value of the current system, or nil if it is not available
+func (v View) SystemValue() *galaxy.System {
+	if v.reader == nil {
+		return nil
+	}
+	sys, err := v.reader.GetSystemValue(v.Journal.Location.SystemAddress)
+	if err != nil {
+		return nil
+	}
+	return sys
+}
+
+// Stations returns the known stations in the current system
+func (v View) Stations() []galaxy.Station {
+	if v.reader == nil {
+		return nil
+	}
+	stations, _ := v.reader.galaxy.Stations(v.Journal.Location.SystemAddress)
+	return stations
+}
+
+// templateFuncs are the helper functions available in page templates
+var templateFuncs = template.FuncMap{
+	"spaceBetween": func(s ...string) string { return mfd.SpaceBetween(s...) },
+	"fillAround":   func(fill, s string) string { return mfd.FillAround(fill, s) },
+	"center":       func(s string) string { return mfd.Center(s) },
+	"credits":      func(v int64) string { return printer.Sprintf("%dcr", v) },
+	"number":       func(v any) string { return printer.Sprintf("%v", v) },
+	"upper":        strings.ToUpper,
+	"lower":        strings.ToLower,
+	"title":        cases.Title(language.English).String,
+}
+
+// templatePage is a page rendered from a user defined template
+type templatePage struct {
+	key  string
+	id   *template.Template
+	text *template.Template
+}
+
+// RegisterTemplatePages parses the page templates from the config and adds them to the pages of the
+// reader. Template files are looked up relative to baseDir. It must be called before Run.
+func (r *Reader) RegisterTemplatePages(baseDir string) error {
+	for _, tc := range r.cfg.Templates {
+		if tc.Key == "" {
+			return fmt.Errorf("page template without key")
+		}
+		for _, pageDef := range r.pages {
+			if string(pageDef.Key) == tc.Key {
+				return fmt.Errorf("page template %q: key is already in use", tc.Key)
+			}
+		}
+
+		text := tc.Text
+		if tc.File != "" {
+			file := tc.File
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(baseDir, file)
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("page template %q: %w", tc.Key, err)
+			}
+			text = string(data)
+		}
+
+		tp := templatePage{key: tc.Key}
+		var err error
+		tp.text, err = template.New(tc.Key).Funcs(templateFuncs).Parse(text)
+		if err != nil {
+			return fmt.Errorf("page template %q: %w", tc.Key, err)
+		}
+		if tc.ID != "" {
+			tp.id, err = template.New(tc.Key + ".id").Funcs(templateFuncs).Parse(tc.ID)
+			if err != nil {
+				return fmt.Errorf("page template %q: id: %w", tc.Key, err)
+			}
+		}
+
+		displayName := tc.Name
+		if displayName == "" {
+			displayName = tc.Key
+		}
+		r.pages = append(r.pages, PageDef{
+			Key:         PageKey(tc.Key),
+			DisplayName: displayName,
+			Render:      tp.render,
+		})
+		log.Info().Str("key", tc.Key).Str("file", logging.CleanPath(tc.File)).Msg("Registered page template")
+	}
+	return nil
+}
+
+func (tp templatePage) render(r *Reader, page *mfd.Page, state Journalstate) {
+	view := r.currentView(state)
+
+	page.ID = tp.key
+	if tp.id != nil {
+		var id bytes.Buffer
+		if err := tp.id.Execute(&id, view); err != nil {
+			log.Warn().Err(err).Str("key", tp.key).Msg("Failed to render page template id")
+		}
+		page.ID = tp.key + ":" + id.String()
+	}
+
+	var out bytes.Buffer
+	if err := tp.text.Execute(&out, view); err != nil {
+		log.Warn().Err(err).Str("key", tp.key).Msg("Failed to render page template")
+		page.Add("%s", mfd.FillAround("*", " TMPL ERROR "))
+		return
+	}
+	for _, line := range strings.Split(strings.TrimRight(out.String(), "\n"), "\n") {
+		page.Add("%s", strings.TrimRight(line, "\r"))
+	}
+}
@@ -0,0 +1,159 @@
+package edreader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pellux-network/EDxDC/conf"
+	"github.com/pellux-network/EDxDC/mfd"
+	"github.com/rs/zerolog"
+)
+
+// templateReader returns a reader with the page templates of the config
+func templateReader(t *testing.T, templates []conf.TemplateConf) *Reader {
+	t.Helper()
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+	t.Cleanup(func() { zerolog.SetGlobalLevel(zerolog.TraceLevel) })
+	r := New(conf.Conf{Templates: templates, Galaxy: conf.GalaxyConf{Providers: []string{"journal"}}}, Options{})
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestRegisterTemplatePages(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(baseDir, "status.tmpl"), []byte(`{{.Journal.Commander}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		templates []conf.TemplateConf
+		// err is part of the error, if registering fails
+		err  string
+		keys []PageKey
+	}{
+		{
+			name: "text and file",
+			templates: []conf.TemplateConf{
+				{Key: "system", Text: `{{.Journal.StarSystem}}`},
+				{Key: "status", Name: "Status", File: "status.tmpl"},
+			},
+			keys: []PageKey{"system", "status"},
+		},
+		{
+			name:      "absolute file",
+			templates: []conf.TemplateConf{{Key: "status", File: filepath.Join(baseDir, "status.tmpl")}},
+			keys:      []PageKey{"status"},
+		},
+		{
+			name:      "no key",
+			templates: []conf.TemplateConf{{Text: `{{.Journal.StarSystem}}`}},
+			err:       "without key",
+		},
+		{
+			name:      "key of a built-in page",
+			templates: []conf.TemplateConf{{Key: "cargo", Text: `{{.Cargo.Count}}`}},
+			err:       `"cargo": key is already in use`,
+		},
+		{
+			name: "same key twice",
+			templates: []conf.TemplateConf{
+				{Key: "system", Text: `{{.Journal.StarSystem}}`},
+				{Key: "system", Text: `{{.Journal.Body}}`},
+			},
+			err: `"system": key is already in use`,
+		},
+		{
+			name:      "missing file",
+			templates: []conf.TemplateConf{{Key: "status", File: "missing.tmpl"}},
+			err:       `"status"`,
+		},
+		{
+			name:      "syntax error",
+			templates: []conf.TemplateConf{{Key: "system", Text: `{{.Journal.StarSystem`}},
+			err:       `"system"`,
+		},
+		{
+			name:      "syntax error in id",
+			templates: []conf.TemplateConf{{Key: "system", ID: `{{end}}`, Text: `{{.Journal.StarSystem}}`}},
+			err:       `"system": id`,
+		},
+		{
+			name:      "unknown function",
+			templates: []conf.TemplateConf{{Key: "system", Text: `{{shout .Journal.StarSystem}}`}},
+			err:       `"system"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := templateReader(t, tt.templates)
+			err := r.RegisterTemplatePages(baseDir)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("got error %v, want one with %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var keys []PageKey
+			for _, pageDef := range r.pages[len(PageRegistry):] {
+				keys = append(keys, pageDef.Key)
+			}
+			if diff := cmp.Diff(tt.keys, keys); diff != "" {
+				t.Errorf("pages differ (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRenderTemplatePage(t *testing.T) {
+	state := Journalstate{Commander: "jameson"}
+	state.StarSystem = "Shinrarta Dezhra"
+	state.Body = "Founders World"
+	tests := []struct {
+		name string
+		tc   conf.TemplateConf
+		want mfd.Page
+	}{
+		{
+			name: "lines",
+			tc:   conf.TemplateConf{Key: "system", Text: "{{.Journal.StarSystem}}\r\n{{.Journal.Body}}\n\n"},
+			want: mfd.Page{ID: "system", Lines: []string{"Shinrarta Dezhra", "Founders World"}},
+		},
+		{
+			name: "id",
+			tc:   conf.TemplateConf{Key: "body", ID: "{{.Journal.Body}}", Text: "{{.Journal.Body}}"},
+			want: mfd.Page{ID: "body:Founders World", Lines: []string{"Founders World"}},
+		},
+		{
+			name: "functions",
+			tc: conf.TemplateConf{Key: "cmdr", Text: `{{title .Journal.Commander}}
+{{spaceBetween "Cr" (credits 1234567)}}
+{{center (upper "sol")}}
+{{number 9876}}`},
+			want: mfd.Page{ID: "cmdr", Lines: []string{"Jameson", "Cr   1,234,567cr", "      SOL       ", "9,876"}},
+		},
+		{
+			name: "execution error",
+			tc:   conf.TemplateConf{Key: "broken", Text: "{{.Journal.NoSuchField}}"},
+			want: mfd.Page{ID: "broken", Lines: []string{"** TMPL ERROR **"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := templateReader(t, []conf.TemplateConf{tt.tc})
+			if err := r.RegisterTemplatePages(t.TempDir()); err != nil {
+				t.Fatal(err)
+			}
+			page := mfd.NewPage()
+			r.pages[len(r.pages)-1].Render(r, &page, state)
+			if diff := cmp.Diff(tt.want, page); diff != "" {
+				t.Errorf("page differs (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
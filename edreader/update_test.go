@@ -0,0 +1,95 @@
+package edreader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pellux-network/EDxDC/journal"
+)
+
+// BenchmarkStatusUpdate updates the pages after the game wrote Status.json, which it does several
+// times a second
+func BenchmarkStatusUpdate(b *testing.B) {
+	// The status changes in size, as the reader only reads it then
+	statuses := []string{
+		`{ "Flags":16842765, "Destination":{ "System":10477373803, "Body":3, "Name":"Earth" } }`,
+		`{ "Flags":16842761, "Fuel":{ "FuelMain":16.0 }, "Destination":{ "System":10477373803, "Body":3, "Name":"Earth" } }`,
+	}
+	run := func(b *testing.B, update func(r *Reader)) {
+		r := newTestReader(b)
+		path := filepath.Join(r.folder, FileStatus)
+		b.ResetTimer()
+		for i := range b.N {
+			if err := os.WriteFile(path, []byte(statuses[i%len(statuses)]), 0644); err != nil {
+				b.Fatal(err)
+			}
+			update(r)
+		}
+		b.StopTimer()
+		m := r.Metrics()
+		b.ReportMetric(float64(m.PagesRendered)/float64(m.Updates), "pages/update")
+	}
+
+	b.Run("all files and pages", func(b *testing.B) {
+		// As before updates were file specific: every file read and every page rendered
+		run(b, func(r *Reader) {
+			clear(r.pageCache)
+			r.update(journal.FolderChange{All: true}, false)
+		})
+	})
+	b.Run("changed file", func(b *testing.B) {
+		run(b, func(r *Reader) {
+			r.update(journal.FolderChange{Files: map[string]bool{FileStatus: true}}, false)
+		})
+	})
+}
+
+// BenchmarkJournalUpdate updates the pages after the game wrote a journal event, alternating between
+// one that changes the destination and one that no page shows
+func BenchmarkJournalUpdate(b *testing.B) {
+	lines := []string{
+		`{ "timestamp":"2025-01-01T10:00:02Z", "event":"FSDTarget", "Name":"Alpha Centauri", "SystemAddress":3932277478106 }`,
+		`{ "timestamp":"2025-01-01T10:00:03Z", "event":"Music", "MusicTrack":"Exploration" }`,
+		`{ "timestamp":"2025-01-01T10:00:04Z", "event":"FSDTarget", "Name":"Barnard's Star", "SystemAddress":10477373803 }`,
+		`{ "timestamp":"2025-01-01T10:00:05Z", "event":"Music", "MusicTrack":"Exploration" }`,
+	}
+	run := func(b *testing.B, update func(r *Reader)) {
+		r := newTestReader(b)
+		path := filepath.Join(r.folder, testJournal)
+		b.ResetTimer()
+		for i := range b.N {
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				b.Fatal(err)
+			}
+			f.WriteString(lines[i%len(lines)] + "\n")
+			f.Close()
+			update(r)
+		}
+		b.StopTimer()
+		m := r.Metrics()
+		b.ReportMetric(float64(m.PagesRendered)/float64(m.Updates), "pages/update")
+	}
+	changed := journal.FolderChange{Files: map[string]bool{testJournal: true}}
+
+	b.Run("all pages", func(b *testing.B) {
+		run(b, func(r *Reader) {
+			clear(r.pageCache)
+			r.update(changed, false)
+		})
+	})
+	b.Run("dependent pages", func(b *testing.B) {
+		run(b, func(r *Reader) { r.update(changed, false) })
+	})
+}
+
+// BenchmarkUnrelatedUpdate updates the pages after the game wrote a file none of them shows
+func BenchmarkUnrelatedUpdate(b *testing.B) {
+	r := newTestReader(b)
+	changed := journal.FolderChange{Files: map[string]bool{"NavRoute.json": true}}
+	b.ResetTimer()
+	for range b.N {
+		r.update(changed, false)
+	}
+}
@@ -5,10 +5,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"sort"
-	"strings"
 	"sync"
+	"time"
 
+	"github.com/pellux-network/EDxDC/galaxy"
 	"github.com/rs/zerolog/log"
 )
 
@@ -19,132 +19,34 @@ import (
 const (
 	urlBodies      = "https://www.edsm.net/api-system-v1/bodies?systemId64=%d"
 	urlSystemValue = "https://www.edsm.net/api-system-v1/estimated-value?systemId64=%d"
-)
-
-// System parses the root object response from the api-system-v1 apis
-type System struct {
-	ID64      uint64
-	Name      string
-	BodyCount int
-
-	EstimatedValue       int64
-	EstimatedValueMapped int64
-
-	Bodies         []Body
-	ValuableBodies []ValuableBody
-}
-
-// Body parses information about a single body
-type Body struct {
-	ID64   uint64
-	BodyID int64
-
-	Name        string
-	IsMainStar  bool
-	IsScoopable bool
-	Type        string
-	SubType     string
-
-	Gravity float64
 
-	Volcanism  string
-	IsLandable bool
-
-	Materials map[string]float64
-}
-
-// ValuableBody holds information about the value of bodies
-type ValuableBody struct {
-	BodyName string
-	ValueMax int64
-}
+	requestTimeout = 10 * time.Second
+)
 
-// Material presents a single material and it's presence as a percentage
-type Material struct {
-	Name       string
-	Percentage float64
-}
+// client gives up on EDSM after a while, so the pages can fall back to other providers
+var client = &http.Client{Timeout: requestTimeout}
 
 // SystemResult bundles the result of fetching system information with the optional error
 type SystemResult struct {
-	S     System
+	S     galaxy.System
 	Error error
 }
 
-// Station represents a station in the system
-type Station struct {
-	ID                int64   `json:"id"`
-	Name              string  `json:"name"`
-	Type              string  `json:"type"`
-	DistanceToArrival float64 `json:"distanceToArrival"`
-	Allegiance        string  `json:"allegiance"`
-}
-
 // StationsResponse represents the response for stations in a system
 type StationsResponse struct {
-	ID       int64     `json:"id"`
-	Name     string    `json:"name"`
-	Stations []Station `json:"stations"`
-}
-
-// MainStar returns the main star in the system
-func (s System) MainStar() Body {
-	for _, body := range s.Bodies {
-		if body.IsMainStar {
-			return body
-		}
-	}
-	return Body{}
-}
-
-// BodyByID retrieves a body from the system by it's BodyID
-func (s System) BodyByID(bodyID int64) Body {
-	for _, body := range s.Bodies {
-		if body.BodyID == bodyID {
-			return body
-		}
-	}
-	return Body{}
-}
-
-// ShortName returns the shortened name of the body, without the system name prefix
-func (b Body) ShortName(s System) string {
-	return shortName(s.Name, b.Name)
-}
-
-// ShortName returns the shortened name of the body, without the system name prefix
-func (b ValuableBody) ShortName(s System) string {
-	return shortName(s.Name, b.BodyName)
-}
-
-func shortName(systemName, bodyName string) string {
-	if strings.HasPrefix(bodyName, systemName) && len(bodyName) > len(systemName) {
-		return bodyName[len(systemName)+1:]
-	}
-	return bodyName
-}
-
-// MaterialsSorted returns the materials of this body in descending sorted order
-func (b Body) MaterialsSorted() []Material {
-	ms := []Material{}
-	for m, p := range b.Materials {
-		ms = append(ms, Material{m, p})
-	}
-
-	sort.Slice(ms, func(i, j int) bool {
-		if ms[i].Percentage == ms[j].Percentage {
-			return ms[i].Name < ms[j].Name
-		}
-		return ms[i].Percentage > ms[j].Percentage
-	})
-	return ms
+	ID       int64            `json:"id"`
+	Name     string           `json:"name"`
+	Stations []galaxy.Station `json:"stations"`
 }
 
 // ClearCache will clear the module cache
 func ClearCache() {
 	cachelock.Lock()
-	defer cachelock.Unlock()
-	sysinfocache = make(map[string]System)
+	sysinfocache = make(map[string]galaxy.System)
+	cachelock.Unlock()
+	stationCache.Lock()
+	stationCache.data = make(map[int64][]galaxy.Station)
+	stationCache.Unlock()
 	log.Debug().Msg("Cached EDSM information cleared")
 }
 
@@ -158,13 +60,13 @@ func GetSystemValue(id64 int64) <-chan SystemResult {
 	return getBodyInfo(urlSystemValue, id64)
 }
 
-var sysinfocache = make(map[string]System)
+var sysinfocache = make(map[string]galaxy.System)
 var cachelock = sync.RWMutex{}
 
 var stationCache = struct {
 	sync.RWMutex
-	data map[int64][]Station
-}{data: make(map[int64][]Station)}
+	data map[int64][]galaxy.Station
+}{data: make(map[int64][]galaxy.Station)}
 
 func getBodyInfo(url string, id64 int64) <-chan SystemResult {
 	log.Trace().Str("url", url).Int64("id64", id64).Msg("getBodyInfo called")
@@ -182,14 +84,20 @@ func getBodyInfo(url string, id64 int64) <-chan SystemResult {
 			return
 		}
 		log.Debug().Str("sysurl", sysurl).Msg("Requesting information from EDSM")
-		resp, err := http.Get(fmt.Sprintf(url, id64))
-		s := System{Bodies: []Body{}}
+		resp, err := client.Get(sysurl)
+		s := galaxy.System{Bodies: []galaxy.Body{}}
 		if err != nil {
 			log.Warn().Err(err).Str("sysurl", sysurl).Msg("Failed to fetch EDSM info")
 			retchan <- SystemResult{s, err}
 			return
 		}
 		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err := fmt.Errorf("EDSM request failed: %s", resp.Status)
+			log.Warn().Err(err).Str("sysurl", sysurl).Msg("Failed to fetch EDSM info")
+			retchan <- SystemResult{s, err}
+			return
+		}
 		data, err := io.ReadAll(resp.Body)
 		if err != nil {
 			log.Warn().Err(err).Str("sysurl", sysurl).Msg("Failed to read EDSM response")
@@ -208,7 +116,7 @@ func getBodyInfo(url string, id64 int64) <-chan SystemResult {
 }
 
 // GetSystemStations retrieves station information from EDSM.net
-func GetSystemStations(systemaddress int64) ([]Station, error) {
+func GetSystemStations(systemaddress int64) ([]galaxy.Station, error) {
 	stationCache.RLock()
 	if stations, ok := stationCache.data[systemaddress]; ok {
 		stationCache.RUnlock()
@@ -217,11 +125,14 @@ func GetSystemStations(systemaddress int64) ([]Station, error) {
 	stationCache.RUnlock()
 
 	url := fmt.Sprintf("https://www.edsm.net/api-system-v1/stations?systemId64=%d", systemaddress)
-	resp, err := http.Get(url)
+	resp, err := client.Get(url)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("EDSM request failed: %s", resp.Status)
+	}
 	var sr StationsResponse
 	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
 		return nil, err
@@ -231,3 +142,46 @@ func GetSystemStations(systemaddress int64) ([]Station, error) {
 	stationCache.Unlock()
 	return sr.Stations, nil
 }
+
+// Provider makes EDSM a galaxy provider
+type Provider struct{}
+
+// Name is "edsm"
+func (Provider) Name() string {
+	return "edsm"
+}
+
+// Bodies returns the bodies of the system known to EDSM
+func (Provider) Bodies(id64 int64) (galaxy.System, error) {
+	return systemResult(<-GetSystemBodies(id64))
+}
+
+// Value returns the estimated value of the system
+func (Provider) Value(id64 int64) (galaxy.System, error) {
+	return systemResult(<-GetSystemValue(id64))
+}
+
+// Stations returns the stations in the system known to EDSM
+func (Provider) Stations(id64 int64) ([]galaxy.Station, error) {
+	stations, err := GetSystemStations(id64)
+	if err == nil && len(stations) == 0 {
+		return nil, galaxy.ErrNotFound
+	}
+	return stations, err
+}
+
+// ClearCache clears the module cache
+func (Provider) ClearCache() {
+	ClearCache()
+}
+
+// systemResult turns the empty answer EDSM gives for unknown systems into galaxy.ErrNotFound
+func systemResult(r SystemResult) (galaxy.System, error) {
+	if r.Error != nil {
+		return galaxy.System{}, r.Error
+	}
+	if r.S.ID64 == 0 {
+		return galaxy.System{}, galaxy.ErrNotFound
+	}
+	return r.S, nil
+}
@@ -0,0 +1,25 @@
+package edsm
+
+import (
+	"testing"
+
+	"github.com/pellux-network/EDxDC/galaxy"
+)
+
+func TestClearCache(t *testing.T) {
+	cachelock.Lock()
+	sysinfocache["bodies/1"] = galaxy.System{ID64: 1}
+	cachelock.Unlock()
+	stationCache.Lock()
+	stationCache.data[1] = []galaxy.Station{{Name: "Port"}}
+	stationCache.Unlock()
+
+	Provider{}.ClearCache()
+
+	if len(sysinfocache) != 0 {
+		t.Errorf("got %d systems cached after clearing", len(sysinfocache))
+	}
+	if len(stationCache.data) != 0 {
+		t.Errorf("got stations of %d systems cached after clearing", len(stationCache.data))
+	}
+}
@@ -0,0 +1,548 @@
+package edsm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pellux-network/EDxDC/conf"
+	"github.com/pellux-network/EDxDC/logging"
+	"github.com/pellux-network/EDxDC/outbox"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+ Uploading journal events to the commander's EDSM logbook with the api-journal-v1 API, as described on
+ https://www.edsm.net/en/api-journal-v1
+*/
+
+const (
+	urlJournal        = "https://www.edsm.net/api-journal-v1"
+	urlJournalDiscard = "https://www.edsm.net/api-journal-v1/discard"
+
+	// softwareName identifies EDxDC to EDSM
+	softwareName = "EDxDC"
+
+	journalBatchSize = 50
+	journalLinger    = 10 * time.Second
+	journalTimeout   = 30 * time.Second
+	// journalOutboxLimit bounds the outbox while EDSM is unreachable for a long time
+	journalOutboxLimit = 10000
+	// trackedFiles is the number of journal files whose upload state is remembered
+	trackedFiles = 20
+	// stateSaveDelay is the time the upload state is saved after lines are queued, saving the lines
+	// queued meanwhile with it
+	stateSaveDelay = 5 * time.Second
+	// discardRefresh is how often the events EDSM does not want are fetched again
+	discardRefresh = 24 * time.Hour
+
+	// msgnum values in EDSM responses
+	msgOK          = 100
+	msgFirstError  = 200
+	msgFirstServer = 500
+)
+
+// JournalLine is a line read from the journal, with where it was read and who was playing
+type JournalLine struct {
+	// File is the path of the journal file, and Number the line number in it, starting at 1
+	File   string
+	Number int
+	Event  string
+	Line   []byte
+
+	Commander   string
+	GameVersion string
+	GameBuild   string
+}
+
+// queuedEvent is a journal event waiting in the outbox
+type queuedEvent struct {
+	GameVersion string          `json:"gameversion"`
+	GameBuild   string          `json:"gamebuild"`
+	Event       string          `json:"event"`
+	Line        json.RawMessage `json:"line"`
+}
+
+// journalResponse is the response to a journal upload
+type journalResponse struct {
+	MsgNum int    `json:"msgnum"`
+	Msg    string `json:"msg"`
+	Events []struct {
+		MsgNum int    `json:"msgnum"`
+		Msg    string `json:"msg"`
+	} `json:"events"`
+}
+
+// JournalUploader uploads journal events to EDSM
+type JournalUploader struct {
+	commander       string
+	apiKey          string
+	softwareVersion string
+	url             string
+	discardURL      string
+	client          *http.Client
+	sender          *outbox.Sender
+
+	// statePath is the file remembering how many lines of each journal file were queued
+	statePath string
+
+	lock        sync.Mutex
+	files       map[string]int
+	trackedFile string
+	tracker     transientState
+	// saveTimer saves the upload state once lines were queued, nil while there is nothing to save
+	saveTimer *time.Timer
+
+	discardLock sync.RWMutex
+	discard     map[string]bool
+	// discardFetched is when the discard list was last fetched
+	discardFetched time.Time
+}
+
+// transientState is the game state EDSM wants added to every event, as it is not part of all of them
+type transientState struct {
+	SystemAddress json.Number
+	SystemName    string
+	Coordinates   []json.Number
+	MarketID      json.Number
+	StationName   string
+	ShipID        json.Number
+}
+
+// NewJournalUploader creates an uploader for the commander configured. Events are queued in the outbox
+// file, and the upload state of the journal files kept in the state file.
+func NewJournalUploader(cfg conf.EDSMConf, outboxPath, statePath, softwareVersion string) (*JournalUploader, error) {
+	if cfg.Commander == "" || cfg.APIKey == "" {
+		return nil, errors.New("EDSM upload needs the commander name and API key")
+	}
+	queue, err := outbox.Open(outboxPath, journalOutboxLimit)
+	if err != nil {
+		return nil, err
+	}
+	u := &JournalUploader{
+		commander:       cfg.Commander,
+		apiKey:          cfg.APIKey,
+		softwareVersion: softwareVersion,
+		url:             urlJournal,
+		discardURL:      urlJournalDiscard,
+		client:          &http.Client{Timeout: journalTimeout},
+		statePath:       statePath,
+		files:           map[string]int{},
+	}
+	if data, err := os.ReadFile(statePath); err == nil {
+		if err := json.Unmarshal(data, &u.files); err != nil {
+			log.Warn().Err(err).Str("path", logging.CleanPath(statePath)).Msg("Ignoring unreadable EDSM upload state")
+			u.files = map[string]int{}
+		}
+	}
+	u.sender = &outbox.Sender{
+		Name:      "EDSM",
+		Queue:     queue,
+		BatchSize: journalBatchSize,
+		Linger:    journalLinger,
+		Send:      u.upload,
+	}
+	return u, nil
+}
+
+// Start starts uploading queued events in the background
+func (u *JournalUploader) Start() {
+	log.Info().Str("commander", u.commander).Int("queued", u.sender.Queue.Len()).Msg("Starting EDSM journal upload")
+	u.sender.Start()
+}
+
+// Close stops uploading and saves the upload state. Events not yet uploaded are kept for the next start.
+func (u *JournalUploader) Close() {
+	u.sender.Close()
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if u.saveTimer != nil {
+		u.saveTimer.Stop()
+		u.saveTimer = nil
+	}
+	u.saveState()
+}
+
+// HandleLine queues a journal line for upload. Lines of the file that were written while EDxDC was not
+// watching are read from the file and queued first.
+func (u *JournalUploader) HandleLine(l JournalLine) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	name := filepath.Base(l.File)
+	var events []any
+	if u.trackedFile != name {
+		// Catch up with the lines before this one, to know where the player is and upload what was missed
+		u.trackedFile = name
+		u.tracker = transientState{}
+		events = u.catchUp(l, name)
+	}
+	if l.Number <= u.files[name] {
+		u.tracker.update(l.Event, l.Line)
+		return
+	}
+	u.files[name] = l.Number
+	if event, ok := u.prepare(l, l.Event, l.Line); ok {
+		events = append(events, event)
+	}
+	if len(events) == 0 {
+		return
+	}
+	if err := u.sender.Push(events...); err != nil {
+		log.Error().Err(err).Msg("Failed to queue EDSM events")
+	}
+	u.scheduleSave()
+}
+
+// scheduleSave saves the upload state after a while, together with the lines queued until then. Lines
+// queued again after a crash before that are dropped by EDSM as duplicates. The caller must hold the lock.
+func (u *JournalUploader) scheduleSave() {
+	if u.saveTimer != nil {
+		return
+	}
+	u.saveTimer = time.AfterFunc(stateSaveDelay, func() {
+		u.lock.Lock()
+		defer u.lock.Unlock()
+		if u.saveTimer == nil {
+			// Saved by Close
+			return
+		}
+		u.saveTimer = nil
+		u.saveState()
+	})
+}
+
+// catchUp reads the lines of the file before the line l, returning the events that were not queued yet
+func (u *JournalUploader) catchUp(l JournalLine, name string) []any {
+	file, err := os.Open(l.File)
+	if err != nil {
+		log.Warn().Err(err).Str("filename", logging.CleanPath(l.File)).Msg("Failed to read journal for EDSM upload")
+		return nil
+	}
+	defer file.Close()
+
+	var events []any
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+	for number := 1; number < l.Number && scanner.Scan(); number++ {
+		line := scanner.Bytes()
+		event, err := eventName(line)
+		if err != nil {
+			continue
+		}
+		if number <= u.files[name] {
+			u.tracker.update(event, line)
+			continue
+		}
+		if queued, ok := u.prepare(l, event, bytes.Clone(line)); ok {
+			events = append(events, queued)
+		}
+	}
+	if len(events) > 0 {
+		log.Info().Int("events", len(events)).Str("filename", logging.CleanPath(l.File)).Msg("Queued missed journal events for EDSM")
+	}
+	return events
+}
+
+// prepare updates the transient state with the line, and returns the event to upload, if it should be
+func (u *JournalUploader) prepare(l JournalLine, event string, line []byte) (queuedEvent, bool) {
+	u.tracker.update(event, line)
+	if !strings.EqualFold(l.Commander, u.commander) {
+		return queuedEvent{}, false
+	}
+	if strings.Contains(strings.ToLower(l.GameVersion), "beta") {
+		// EDSM does not take data from beta versions of the game
+		return queuedEvent{}, false
+	}
+	if u.discarded(event) {
+		return queuedEvent{}, false
+	}
+	augmented, err := u.tracker.augment(line)
+	if err != nil {
+		log.Debug().Err(err).Str("event", event).Msg("Not uploading invalid journal line to EDSM")
+		return queuedEvent{}, false
+	}
+	return queuedEvent{GameVersion: l.GameVersion, GameBuild: l.GameBuild, Event: event, Line: augmented}, true
+}
+
+// saveState writes the upload state of the most recent journal files, replacing the file only once it
+// is written completely. The caller must hold the lock.
+func (u *JournalUploader) saveState() {
+	names := make([]string, 0, len(u.files))
+	for name := range u.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for len(names) > trackedFiles {
+		delete(u.files, names[0])
+		names = names[1:]
+	}
+	data, err := json.Marshal(u.files)
+	if err != nil {
+		return
+	}
+	if err := writeFileAtomic(u.statePath, data); err != nil {
+		log.Warn().Err(err).Str("path", logging.CleanPath(u.statePath)).Msg("Failed to save EDSM upload state")
+	}
+}
+
+// writeFileAtomic replaces the file with data, leaving the old file in place if writing fails
+func writeFileAtomic(filename string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// discarded tells if EDSM asked not to be sent the event
+func (u *JournalUploader) discarded(event string) bool {
+	u.discardLock.RLock()
+	defer u.discardLock.RUnlock()
+	return u.discard[event]
+}
+
+// fetchDiscardList fetches the events EDSM does not want, if that was not done yet or the list is due
+// to be refreshed. If refreshing fails, the list fetched before is kept.
+func (u *JournalUploader) fetchDiscardList() error {
+	u.discardLock.RLock()
+	loaded := u.discard != nil
+	due := time.Since(u.discardFetched) >= discardRefresh
+	u.discardLock.RUnlock()
+	if loaded && !due {
+		return nil
+	}
+
+	discard, err := u.getDiscardList()
+	if err != nil {
+		if loaded {
+			log.Warn().Err(err).Msg("Failed to refresh EDSM discard list, keeping the previous one")
+			u.discardLock.Lock()
+			// Try again with the next refresh
+			u.discardFetched = time.Now()
+			u.discardLock.Unlock()
+			return nil
+		}
+		return err
+	}
+
+	u.discardLock.Lock()
+	u.discard = discard
+	u.discardFetched = time.Now()
+	u.discardLock.Unlock()
+	log.Debug().Int("events", len(discard)).Msg("Fetched EDSM discard list")
+	return nil
+}
+
+// getDiscardList requests the events EDSM does not want
+func (u *JournalUploader) getDiscardList() (map[string]bool, error) {
+	resp, err := u.client.Get(u.discardURL)
+	if err != nil {
+		return nil, fmt.Errorf("fetching EDSM discard list: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching EDSM discard list: %s", resp.Status)
+	}
+	var events []string
+	if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
+		return nil, fmt.Errorf("parsing EDSM discard list: %w", err)
+	}
+	discard := make(map[string]bool, len(events))
+	for _, event := range events {
+		discard[event] = true
+	}
+	return discard, nil
+}
+
+// upload sends a batch of queued events to EDSM, one request per game version in the batch
+func (u *JournalUploader) upload(messages []json.RawMessage) error {
+	if err := u.fetchDiscardList(); err != nil {
+		return err
+	}
+
+	var batch []json.RawMessage
+	var current queuedEvent
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := u.post(current, batch)
+		batch = nil
+		return err
+	}
+	for _, message := range messages {
+		var event queuedEvent
+		if err := json.Unmarshal(message, &event); err != nil {
+			log.Warn().Err(err).Msg("Dropping unreadable EDSM outbox entry")
+			continue
+		}
+		if event.GameVersion != current.GameVersion || event.GameBuild != current.GameBuild {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		current = event
+		if !u.discarded(event.Event) {
+			batch = append(batch, event.Line)
+		}
+	}
+	return flush()
+}
+
+// post uploads events from the same game version
+func (u *JournalUploader) post(game queuedEvent, events []json.RawMessage) error {
+	message, err := json.Marshal(events)
+	if err != nil {
+		return outbox.Permanent(err)
+	}
+	form := url.Values{
+		"commanderName":       {u.commander},
+		"apiKey":              {u.apiKey},
+		"fromSoftware":        {softwareName},
+		"fromSoftwareVersion": {u.softwareVersion},
+		"fromGameVersion":     {game.GameVersion},
+		"fromGameBuild":       {game.GameBuild},
+		"message":             {string(message)},
+	}
+	resp, err := u.client.PostForm(u.url, form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("EDSM upload failed: %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var result journalResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("parsing EDSM response: %w", err)
+	}
+
+	switch {
+	case result.MsgNum >= msgOK && result.MsgNum < msgFirstError:
+		for i, event := range result.Events {
+			if event.MsgNum >= msgFirstError {
+				log.Debug().Int("msgnum", event.MsgNum).Str("msg", event.Msg).Int("index", i).Msg("EDSM did not store event")
+			}
+		}
+		log.Debug().Int("events", len(events)).Msg("Uploaded journal events to EDSM")
+		return nil
+	case result.MsgNum >= msgFirstError && result.MsgNum < msgFirstServer:
+		// Wrong commander name or API key; keep the events until the config is fixed
+		log.Error().Int("msgnum", result.MsgNum).Str("msg", result.Msg).Msg("EDSM refused the upload, check the commander name and API key")
+		return fmt.Errorf("EDSM refused upload: %d %s", result.MsgNum, result.Msg)
+	default:
+		return fmt.Errorf("EDSM upload failed: %d %s", result.MsgNum, result.Msg)
+	}
+}
+
+// eventName returns the name of the event of a journal line
+func eventName(line []byte) (string, error) {
+	var event struct {
+		Event string `json:"event"`
+	}
+	if err := json.Unmarshal(line, &event); err != nil {
+		return "", err
+	}
+	if event.Event == "" {
+		return "", errors.New("line has no event")
+	}
+	return event.Event, nil
+}
+
+// update tracks the system, station and ship from the event
+func (t *transientState) update(event string, line []byte) {
+	var e struct {
+		StarSystem    string
+		SystemAddress json.Number
+		StarPos       []json.Number
+		Docked        bool
+		MarketID      json.Number
+		StationName   string
+		ShipID        json.Number
+		NewShipID     json.Number
+	}
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	if err := decoder.Decode(&e); err != nil {
+		return
+	}
+	switch event {
+	case "Location", "FSDJump", "CarrierJump":
+		t.SystemName = e.StarSystem
+		t.SystemAddress = e.SystemAddress
+		t.Coordinates = e.StarPos
+		if e.Docked {
+			t.MarketID, t.StationName = e.MarketID, e.StationName
+		} else {
+			t.MarketID, t.StationName = "", ""
+		}
+	case "Docked":
+		t.SystemName = e.StarSystem
+		t.SystemAddress = e.SystemAddress
+		t.MarketID, t.StationName = e.MarketID, e.StationName
+	case "Undocked":
+		t.MarketID, t.StationName = "", ""
+	case "LoadGame", "Loadout", "ShipyardSwap":
+		t.ShipID = e.ShipID
+	case "ShipyardNew":
+		t.ShipID = e.NewShipID
+	}
+}
+
+// augment adds the transient state to the event, in the fields EDSM reads it from
+func (t transientState) augment(line []byte) (json.RawMessage, error) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	var event map[string]any
+	if err := decoder.Decode(&event); err != nil {
+		return nil, err
+	}
+	if t.SystemAddress != "" {
+		event["_systemAddress"] = t.SystemAddress
+	}
+	if t.SystemName != "" {
+		event["_systemName"] = t.SystemName
+	}
+	if len(t.Coordinates) == 3 {
+		event["_systemCoordinates"] = t.Coordinates
+	}
+	if t.MarketID != "" {
+		event["_marketId"] = t.MarketID
+	}
+	if t.StationName != "" {
+		event["_stationName"] = t.StationName
+	}
+	if t.ShipID != "" {
+		event["_shipId"] = t.ShipID
+	}
+	return json.Marshal(event)
+}
@@ -0,0 +1,199 @@
+package edsm
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pellux-network/EDxDC/conf"
+)
+
+// fakeEDSM records the events uploaded to the journal API
+type fakeEDSM struct {
+	*httptest.Server
+	uploads chan []map[string]any
+	// discards counts the requests of the discard list
+	discards atomic.Int32
+}
+
+func newFakeEDSM(t *testing.T) *fakeEDSM {
+	f := &fakeEDSM{uploads: make(chan []map[string]any, 10)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api-journal-v1/discard", func(w http.ResponseWriter, r *http.Request) {
+		f.discards.Add(1)
+		w.Write([]byte(`["Music","ReceiveText"]`))
+	})
+	mux.HandleFunc("/api-journal-v1", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("commanderName") != "Jameson" || r.FormValue("apiKey") != "secret" {
+			w.Write([]byte(`{"msgnum":203,"msg":"Commander name/API Key not found"}`))
+			return
+		}
+		var events []map[string]any
+		if err := json.Unmarshal([]byte(r.FormValue("message")), &events); err != nil {
+			t.Errorf("invalid message: %v", err)
+		}
+		f.uploads <- events
+		w.Write([]byte(`{"msgnum":100,"msg":"OK","events":[]}`))
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeEDSM) receive(t *testing.T) []map[string]any {
+	t.Helper()
+	select {
+	case events := <-f.uploads:
+		return events
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing uploaded")
+		return nil
+	}
+}
+
+func newTestUploader(t *testing.T, server *fakeEDSM, dir string) *JournalUploader {
+	t.Helper()
+	cfg := conf.EDSMConf{Upload: true, Commander: "Jameson", APIKey: "secret"}
+	u, err := NewJournalUploader(cfg, filepath.Join(dir, "outbox.json"), filepath.Join(dir, "state.json"), "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.url = server.URL + "/api-journal-v1"
+	u.discardURL = server.URL + "/api-journal-v1/discard"
+	u.sender.Linger = 0
+	u.Start()
+	return u
+}
+
+func testLine(journal string, number int, line string) JournalLine {
+	event, _ := eventName([]byte(line))
+	return JournalLine{File: journal, Number: number, Event: event, Line: []byte(line), Commander: "Jameson", GameVersion: "4.0.0.1904", GameBuild: "r1"}
+}
+
+func TestJournalUpload(t *testing.T) {
+	server := newFakeEDSM(t)
+	dir := t.TempDir()
+	journal := filepath.Join(dir, "Journal.2025-01-01T000000.01.log")
+	lines := []string{
+		`{ "timestamp":"2025-01-01T00:00:00Z", "event":"LoadGame", "Commander":"Jameson", "ShipID":7 }`,
+		`{ "timestamp":"2025-01-01T00:01:00Z", "event":"FSDJump", "StarSystem":"Sol", "SystemAddress":10477373803, "StarPos":[0.0,0.0,0.0] }`,
+		`{ "timestamp":"2025-01-01T00:02:00Z", "event":"Music", "MusicTrack":"Exploration" }`,
+		`{ "timestamp":"2025-01-01T00:03:00Z", "event":"Scan", "BodyName":"Earth", "BodyID":3 }`,
+		`{ "timestamp":"2025-01-01T00:04:00Z", "event":"Scan", "BodyName":"Moon", "BodyID":4 }`,
+	}
+	if err := os.WriteFile(journal, []byte(strings.Join(lines[:4], "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first line seen live is the fourth, the ones before it are read from the file
+	u := newTestUploader(t, server, dir)
+	u.HandleLine(testLine(journal, 4, lines[3]))
+	events := server.receive(t)
+	if len(events) != 3 {
+		t.Fatalf("got %d events, wanted LoadGame, FSDJump and Scan", len(events))
+	}
+	scan := events[2]
+	if scan["event"] != "Scan" || scan["_systemName"] != "Sol" || scan["_shipId"] != float64(7) {
+		t.Errorf("got %v, wanted an augmented Scan", scan)
+	}
+	if scan["_systemAddress"] != float64(10477373803) {
+		t.Errorf("got _systemAddress %v", scan["_systemAddress"])
+	}
+	u.Close()
+
+	// After a restart, only new lines are uploaded, still with the state from earlier lines
+	u = newTestUploader(t, server, dir)
+	defer u.Close()
+	u.HandleLine(testLine(journal, 5, lines[4]))
+	events = server.receive(t)
+	if len(events) != 1 || events[0]["BodyName"] != "Moon" || events[0]["_systemName"] != "Sol" {
+		t.Errorf("got %v, wanted only the augmented Moon scan", events)
+	}
+
+	// Other commanders are not uploaded to this account
+	deadline := time.Now().Add(5 * time.Second)
+	for u.sender.Queue.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	other := testLine(journal, 6, `{ "timestamp":"2025-01-01T00:05:00Z", "event":"Scan", "BodyName":"Mars", "BodyID":5 }`)
+	other.Commander = "Someone"
+	u.HandleLine(other)
+	if n := u.sender.Queue.Len(); n != 0 {
+		t.Errorf("got %d queued events, wanted none", n)
+	}
+}
+
+func TestJournalStateSavedBatched(t *testing.T) {
+	server := newFakeEDSM(t)
+	dir := t.TempDir()
+	journal := filepath.Join(dir, "Journal.2025-01-01T000000.01.log")
+	if err := os.WriteFile(journal, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	statePath := filepath.Join(dir, "state.json")
+
+	u := newTestUploader(t, server, dir)
+	for i := 1; i <= 3; i++ {
+		u.HandleLine(testLine(journal, i, `{ "timestamp":"2025-01-01T00:00:00Z", "event":"Scan", "BodyName":"Earth", "BodyID":3 }`))
+	}
+	// Saved once a while after the lines were queued, not for every line
+	if _, err := os.Stat(statePath); !os.IsNotExist(err) {
+		t.Errorf("state saved right after queuing a line: %v", err)
+	}
+	u.Close()
+
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files map[string]int
+	if err := json.Unmarshal(data, &files); err != nil || files[filepath.Base(journal)] != 3 {
+		t.Errorf("got state %s (%v), wanted 3 lines of the journal", data, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".tmp") {
+			t.Errorf("temporary file %s left behind", entry.Name())
+		}
+	}
+}
+
+func TestDiscardListRefresh(t *testing.T) {
+	server := newFakeEDSM(t)
+	u := newTestUploader(t, server, t.TempDir())
+	defer u.Close()
+
+	for range 2 {
+		if err := u.fetchDiscardList(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := server.discards.Load(); n != 1 {
+		t.Errorf("discard list fetched %d times, wanted once", n)
+	}
+
+	// Fetched again once it is due
+	u.discardFetched = time.Now().Add(-discardRefresh)
+	if err := u.fetchDiscardList(); err != nil {
+		t.Fatal(err)
+	}
+	if n := server.discards.Load(); n != 2 {
+		t.Errorf("discard list fetched %d times, wanted it refreshed", n)
+	}
+
+	// The list fetched before is kept while EDSM can not be reached
+	u.discardURL = server.URL + "/missing"
+	u.discardFetched = time.Now().Add(-discardRefresh)
+	if err := u.fetchDiscardList(); err != nil {
+		t.Errorf("failed refresh: %v", err)
+	}
+	if !u.discarded("Music") {
+		t.Error("the discard list was dropped after a failed refresh")
+	}
+}
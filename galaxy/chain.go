@@ -0,0 +1,263 @@
+package galaxy
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultRetryDelay is how long a provider that failed is skipped before it is asked again
+const DefaultRetryDelay = time.Minute
+
+// Chain asks its providers in order of priority, and asks the next ones only for what the earlier ones
+// left out. The answers are merged field by field, and where the providers disagree, the one earlier
+// in the chain wins. A provider that fails, because its site is down for example, is skipped for a
+// while so the pages don't wait for it on every update.
+type Chain struct {
+	providers  []Provider
+	RetryDelay time.Duration
+
+	lock sync.Mutex
+	down map[string]time.Time
+}
+
+// NewChain returns a chain of the providers, in order of priority
+func NewChain(providers ...Provider) *Chain {
+	return &Chain{
+		providers:  providers,
+		RetryDelay: DefaultRetryDelay,
+		down:       map[string]time.Time{},
+	}
+}
+
+// Name lists the names of the providers in the chain
+func (c *Chain) Name() string {
+	names := make([]string, len(c.providers))
+	for i, p := range c.providers {
+		names[i] = p.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// Bodies returns the bodies known to the providers, until one of them knows all of them
+func (c *Chain) Bodies(id64 int64) (System, error) {
+	return query(c, id64, Provider.Bodies, Merge, bodiesComplete)
+}
+
+// Value returns the system value from the first provider that knows it
+func (c *Chain) Value(id64 int64) (System, error) {
+	return query(c, id64, Provider.Value, Merge, valueComplete)
+}
+
+// Stations returns the stations known to the providers, until one of them knows all about them
+func (c *Chain) Stations(id64 int64) ([]Station, error) {
+	stations, err := query(c, id64, Provider.Stations, MergeStations, stationsComplete)
+	if err != nil {
+		return nil, err
+	}
+	if stations == nil {
+		stations = []Station{}
+	}
+	return stations, nil
+}
+
+// ClearCache clears the caches of the providers, and asks the failed providers again
+func (c *Chain) ClearCache() {
+	c.lock.Lock()
+	c.down = map[string]time.Time{}
+	c.lock.Unlock()
+	for _, p := range c.providers {
+		if cc, ok := p.(CacheClearer); ok {
+			cc.ClearCache()
+		}
+	}
+}
+
+// query asks the available providers in order of priority and merges their answers, until the answer
+// is complete. Without any answer, the error of the first provider that failed is returned, or
+// ErrNotFound.
+func query[T any](c *Chain, id64 int64, get func(Provider, int64) (T, error), merge func(T, T) T, complete func(T) bool) (T, error) {
+	var merged, none T
+	answered := false
+	var firstErr error
+	for _, p := range c.providers {
+		if !c.available(p) {
+			continue
+		}
+		result, err := get(p, id64)
+		if err != nil {
+			if !errors.Is(err, ErrNotFound) {
+				c.failed(p, err)
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+			continue
+		}
+		merged = merge(merged, result)
+		answered = true
+		if complete(merged) {
+			break
+		}
+	}
+	if answered {
+		return merged, nil
+	}
+	if firstErr != nil {
+		return none, firstErr
+	}
+	return none, ErrNotFound
+}
+
+// bodiesComplete tells if the system has all its bodies, with their types
+func bodiesComplete(sys System) bool {
+	if sys.BodyCount == 0 || len(sys.Bodies) < sys.BodyCount {
+		return false
+	}
+	for _, body := range sys.Bodies {
+		if body.Type == "" || body.SubType == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// valueComplete tells if the system has both its values
+func valueComplete(sys System) bool {
+	return sys.EstimatedValue != 0 && sys.EstimatedValueMapped != 0
+}
+
+// stationsComplete tells if the type and distance of all stations are known
+func stationsComplete(stations []Station) bool {
+	if len(stations) == 0 {
+		return false
+	}
+	for _, st := range stations {
+		if st.Type == "" || st.DistanceToArrival == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Chain) available(p Provider) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return time.Now().After(c.down[p.Name()])
+}
+
+func (c *Chain) failed(p Provider, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.down[p.Name()] = time.Now().Add(c.RetryDelay)
+	log.Warn().Err(err).Str("provider", p.Name()).Dur("retry", c.RetryDelay).Msg("Galaxy provider failed, using the others")
+}
+
+// Merge fills the fields of a that are not set from b, and adds the bodies only b knows
+func Merge(a, b System) System {
+	if a.ID64 == 0 {
+		a.ID64 = b.ID64
+	}
+	if a.Name == "" {
+		a.Name = b.Name
+	}
+	if a.BodyCount == 0 {
+		a.BodyCount = b.BodyCount
+	}
+	if a.EstimatedValue == 0 {
+		a.EstimatedValue = b.EstimatedValue
+	}
+	if a.EstimatedValueMapped == 0 {
+		a.EstimatedValueMapped = b.EstimatedValueMapped
+	}
+	if len(a.ValuableBodies) == 0 {
+		a.ValuableBodies = b.ValuableBodies
+	}
+
+	bodies := make([]Body, len(a.Bodies), len(a.Bodies)+len(b.Bodies))
+	copy(bodies, a.Bodies)
+	for _, body := range b.Bodies {
+		if i := findBody(bodies, body); i >= 0 {
+			bodies[i] = mergeBody(bodies[i], body)
+		} else {
+			bodies = append(bodies, body)
+		}
+	}
+	a.Bodies = bodies
+	return a
+}
+
+// findBody returns the index of the body with the same ID, or the same name, or -1
+func findBody(bodies []Body, body Body) int {
+	for i, b := range bodies {
+		if (b.ID64 != 0 && b.ID64 == body.ID64) || strings.EqualFold(b.Name, body.Name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// mergeBody fills the fields of a that are not set from b
+func mergeBody(a, b Body) Body {
+	if a.ID64 == 0 {
+		a.ID64 = b.ID64
+	}
+	if a.BodyID == 0 {
+		a.BodyID = b.BodyID
+	}
+	if a.Name == "" {
+		a.Name = b.Name
+	}
+	a.IsMainStar = a.IsMainStar || b.IsMainStar
+	a.IsScoopable = a.IsScoopable || b.IsScoopable
+	a.IsLandable = a.IsLandable || b.IsLandable
+	if a.Type == "" {
+		a.Type = b.Type
+	}
+	if a.SubType == "" {
+		a.SubType = b.SubType
+	}
+	if a.Gravity == 0 {
+		a.Gravity = b.Gravity
+	}
+	if a.Volcanism == "" {
+		a.Volcanism = b.Volcanism
+	}
+	if len(a.Materials) == 0 {
+		a.Materials = b.Materials
+	}
+	return a
+}
+
+// MergeStations adds the stations that are not in a yet, and fills in the fields missing in a
+func MergeStations(a, b []Station) []Station {
+	for _, st := range b {
+		found := false
+		for i := range a {
+			if !strings.EqualFold(a[i].Name, st.Name) {
+				continue
+			}
+			found = true
+			if a[i].ID == 0 {
+				a[i].ID = st.ID
+			}
+			if a[i].Type == "" {
+				a[i].Type = st.Type
+			}
+			if a[i].DistanceToArrival == 0 {
+				a[i].DistanceToArrival = st.DistanceToArrival
+			}
+			if a[i].Allegiance == "" {
+				a[i].Allegiance = st.Allegiance
+			}
+			break
+		}
+		if !found {
+			a = append(a, st)
+		}
+	}
+	return a
+}
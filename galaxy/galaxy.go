@@ -0,0 +1,152 @@
+package galaxy
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+/*
+ Module with the system, body and station information shown on the pages, independent of the site or
+ file it comes from. Each source implements Provider, and a Chain combines them in order of priority.
+*/
+
+// ErrNotFound is returned by providers that have no information about a system
+var ErrNotFound = errors.New("system not found")
+
+// Provider is a source of galaxy information. The methods may block while the information is fetched.
+type Provider interface {
+	// Name identifies the provider in the config and the logs
+	Name() string
+	// Bodies returns the system with its bodies
+	Bodies(id64 int64) (System, error)
+	// Value returns the system with its estimated values and valuable bodies
+	Value(id64 int64) (System, error)
+	// Stations returns the stations in the system
+	Stations(id64 int64) ([]Station, error)
+}
+
+// CacheClearer is implemented by providers that cache information, to fetch it again
+type CacheClearer interface {
+	ClearCache()
+}
+
+// System holds information about a star system. The fields match the EDSM system API.
+type System struct {
+	ID64      uint64
+	Name      string
+	BodyCount int
+
+	EstimatedValue       int64
+	EstimatedValueMapped int64
+
+	Bodies         []Body
+	ValuableBodies []ValuableBody
+}
+
+// Body holds information about a single body
+type Body struct {
+	ID64   uint64
+	BodyID int64
+
+	Name        string
+	IsMainStar  bool
+	IsScoopable bool
+	Type        string
+	SubType     string
+
+	Gravity float64
+
+	Volcanism  string
+	IsLandable bool
+
+	Materials map[string]float64
+}
+
+// ValuableBody holds information about the value of bodies
+type ValuableBody struct {
+	BodyName string
+	Distance float64
+	ValueMax int64
+}
+
+// Material presents a single material and it's presence as a percentage
+type Material struct {
+	Name       string
+	Percentage float64
+}
+
+// Station represents a station in the system
+type Station struct {
+	ID                int64   `json:"id"`
+	Name              string  `json:"name"`
+	Type              string  `json:"type"`
+	DistanceToArrival float64 `json:"distanceToArrival"`
+	Allegiance        string  `json:"allegiance"`
+}
+
+// MainStar returns the main star in the system
+func (s System) MainStar() Body {
+	for _, body := range s.Bodies {
+		if body.IsMainStar {
+			return body
+		}
+	}
+	return Body{}
+}
+
+// BodyByID retrieves a body from the system by it's BodyID
+func (s System) BodyByID(bodyID int64) Body {
+	for _, body := range s.Bodies {
+		if body.BodyID == bodyID {
+			return body
+		}
+	}
+	return Body{}
+}
+
+// ShortName returns the shortened name of the body, without the system name prefix
+func (b Body) ShortName(s System) string {
+	return shortName(s.Name, b.Name)
+}
+
+// ShortName returns the shortened name of the body, without the system name prefix
+func (b ValuableBody) ShortName(s System) string {
+	return shortName(s.Name, b.BodyName)
+}
+
+func shortName(systemName, bodyName string) string {
+	if strings.HasPrefix(bodyName, systemName) && len(bodyName) > len(systemName) {
+		return bodyName[len(systemName)+1:]
+	}
+	return bodyName
+}
+
+// MaterialsSorted returns the materials of this body in descending sorted order
+func (b Body) MaterialsSorted() []Material {
+	ms := []Material{}
+	for m, p := range b.Materials {
+		ms = append(ms, Material{m, p})
+	}
+
+	sort.Slice(ms, func(i, j int) bool {
+		if ms[i].Percentage == ms[j].Percentage {
+			return ms[i].Name < ms[j].Name
+		}
+		return ms[i].Percentage > ms[j].Percentage
+	})
+	return ms
+}
+
+// BodyID64 returns the ID64 of a body, which is the system address with the body ID in the top bits
+func BodyID64(systemAddress, bodyID int64) uint64 {
+	return uint64(systemAddress) | uint64(bodyID)<<55
+}
+
+// IsScoopable tells if fuel can be scooped from a star of the subtype, the classes KGBFOAM
+func IsScoopable(subType string) bool {
+	if subType == "" {
+		return false
+	}
+	return strings.ContainsRune("KGBFOAM", rune(subType[0])) && (len(subType) == 1 || subType[1] == ' ')
+}
@@ -0,0 +1,216 @@
+package galaxy
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeProvider answers from fixed data, and counts the questions
+type fakeProvider struct {
+	name     string
+	systems  map[int64]System
+	stations map[int64][]Station
+	err      error
+	calls    int
+}
+
+func (f *fakeProvider) Name() string { return f.name }
+
+func (f *fakeProvider) Bodies(id64 int64) (System, error) {
+	f.calls++
+	if f.err != nil {
+		return System{}, f.err
+	}
+	sys, ok := f.systems[id64]
+	if !ok {
+		return System{}, ErrNotFound
+	}
+	return sys, nil
+}
+
+func (f *fakeProvider) Value(id64 int64) (System, error) {
+	return f.Bodies(id64)
+}
+
+func (f *fakeProvider) Stations(id64 int64) ([]Station, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	stations, ok := f.stations[id64]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return stations, nil
+}
+
+func TestChainMergesFields(t *testing.T) {
+	site := &fakeProvider{name: "site", systems: map[int64]System{
+		1: {ID64: 1, Name: "Alpha", BodyCount: 3, Bodies: []Body{
+			{ID64: 11, BodyID: 0, Name: "Alpha A", Type: "Star", SubType: "K (Yellow-Orange) Star", IsMainStar: true},
+			{ID64: 12, BodyID: 1, Name: "Alpha 1", Type: "Planet"},
+		}},
+	}, stations: map[int64][]Station{
+		1: {{Name: "Port", Type: "Orbis Starport"}},
+	}}
+	local := &fakeProvider{name: "local", systems: map[int64]System{
+		1: {ID64: 1, Name: "Alpha", BodyCount: 4, Bodies: []Body{
+			{ID64: 12, BodyID: 1, Name: "Alpha 1", Type: "Planet", SubType: "Icy body", Gravity: 0.2, IsLandable: true},
+			{ID64: 13, BodyID: 2, Name: "Alpha 2", Type: "Planet", SubType: "Rocky body"},
+		}},
+	}, stations: map[int64][]Station{
+		1: {{Name: "port", Allegiance: "Empire"}, {Name: "Outpost", Type: "Outpost"}},
+	}}
+
+	sys, err := NewChain(site, local).Bodies(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sys.BodyCount != 3 || len(sys.Bodies) != 3 {
+		t.Fatalf("got %d bodies of %d", len(sys.Bodies), sys.BodyCount)
+	}
+	if b := sys.BodyByID(1); b.SubType != "Icy body" || b.Gravity != 0.2 || !b.IsLandable {
+		t.Errorf("missing fields not filled in: %+v", b)
+	}
+	if sys.MainStar().Name != "Alpha A" || sys.BodyByID(2).Name != "Alpha 2" {
+		t.Errorf("got bodies %+v", sys.Bodies)
+	}
+
+	stations, err := NewChain(site, local).Stations(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stations) != 2 || stations[0].Type != "Orbis Starport" || stations[0].Allegiance != "Empire" {
+		t.Errorf("got stations %+v", stations)
+	}
+}
+
+func TestChainStopsWhenComplete(t *testing.T) {
+	complete := System{ID64: 1, Name: "Alpha", BodyCount: 1, EstimatedValue: 1000, EstimatedValueMapped: 5000, Bodies: []Body{
+		{ID64: 11, Name: "Alpha A", Type: "Star", SubType: "K (Yellow-Orange) Star", IsMainStar: true},
+	}}
+	site := &fakeProvider{name: "site", systems: map[int64]System{1: complete}, stations: map[int64][]Station{
+		1: {{Name: "Port", Type: "Orbis Starport", DistanceToArrival: 120}},
+	}}
+	local := &fakeProvider{name: "local", systems: map[int64]System{1: complete}, stations: map[int64][]Station{
+		1: {{Name: "Outpost", Type: "Outpost"}},
+	}}
+	chain := NewChain(site, local)
+
+	if _, err := chain.Bodies(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chain.Value(1); err != nil {
+		t.Fatal(err)
+	}
+	stations, err := chain.Stations(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stations) != 1 {
+		t.Errorf("got stations %+v, wanted only those of the first provider", stations)
+	}
+	if site.calls != 3 || local.calls != 0 {
+		t.Errorf("providers asked %d and %d times, wanted only the first one asked", site.calls, local.calls)
+	}
+
+	// Falls through for what is missing
+	incomplete := complete
+	incomplete.EstimatedValueMapped = 0
+	site.systems[1] = incomplete
+	sys, err := chain.Value(1)
+	if err != nil || sys.EstimatedValueMapped != 5000 || local.calls != 1 {
+		t.Errorf("got %+v, %v after %d calls, wanted the mapped value of the second provider", sys, err, local.calls)
+	}
+}
+
+func TestChainFallsBack(t *testing.T) {
+	site := &fakeProvider{name: "site", err: errors.New("site is down")}
+	local := &fakeProvider{name: "local", systems: map[int64]System{2: {ID64: 2, Name: "Beta"}}}
+	chain := NewChain(site, local)
+
+	sys, err := chain.Bodies(2)
+	if err != nil || sys.Name != "Beta" {
+		t.Fatalf("got %+v, %v", sys, err)
+	}
+	if _, err := chain.Bodies(3); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v for an unknown system, wanted ErrNotFound", err)
+	}
+	if site.calls != 1 {
+		t.Errorf("failed provider asked %d times, wanted once until the retry delay", site.calls)
+	}
+
+	chain.RetryDelay = 0
+	chain.ClearCache()
+	time.Sleep(time.Millisecond)
+	chain.Bodies(2)
+	if site.calls != 2 {
+		t.Errorf("failed provider asked %d times after the retry delay, wanted 2", site.calls)
+	}
+
+	if _, err := NewChain(site).Bodies(2); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, wanted the error of the provider", err)
+	}
+}
+
+func TestJournal(t *testing.T) {
+	j := NewJournal()
+	lines := map[string]string{
+		"FSSDiscoveryScan": `{"event":"FSSDiscoveryScan","BodyCount":5,"SystemName":"Gamma","SystemAddress":7}`,
+		"Scan":             `{"event":"Scan","BodyName":"Gamma","BodyID":0,"StarSystem":"Gamma","SystemAddress":7,"DistanceFromArrivalLS":0,"StarType":"K"}`,
+		"Docked":           `{"event":"Docked","StationName":"Q2K-BHB","StationType":"FleetCarrier","StarSystem":"Gamma","SystemAddress":7,"MarketID":3700000000,"DistFromStarLS":12.5}`,
+		"Music":            `{"event":"Music","MusicTrack":"Exploration"}`,
+	}
+	for event, line := range lines {
+		j.Observe(event, []byte(line))
+	}
+	j.Observe("Scan", []byte(`{"event":"Scan","BodyName":"Gamma 1","BodyID":1,"StarSystem":"Gamma","SystemAddress":7,
+		"DistanceFromArrivalLS":300,"PlanetClass":"High metal content body","Landable":true,"SurfaceGravity":4.903325,
+		"Materials":[{"Name":"iron","Percent":20.5},{"Name":"nickel","Percent":15}]}`))
+	if v := j.Version(); v != 4 {
+		t.Errorf("got version %d after learning from 4 lines", v)
+	}
+
+	sys, err := j.Bodies(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sys.Name != "Gamma" || sys.BodyCount != 5 || len(sys.Bodies) != 2 {
+		t.Fatalf("got %+v", sys)
+	}
+	if star := sys.MainStar(); star.SubType != "K (Yellow-Orange) Star" || !star.IsScoopable {
+		t.Errorf("got main star %+v", star)
+	}
+	planet := sys.BodyByID(1)
+	if planet.SubType != "High metal content world" || planet.Gravity != 0.5 || !planet.IsLandable || planet.Materials["Iron"] != 20.5 {
+		t.Errorf("got planet %+v", planet)
+	}
+	if planet.ID64 != BodyID64(7, 1) {
+		t.Errorf("got body ID64 %d", planet.ID64)
+	}
+
+	stations, err := j.Stations(7)
+	if err != nil || len(stations) != 1 || stations[0].Type != "Fleet Carrier" || stations[0].ID != 3700000000 {
+		t.Errorf("got stations %+v, %v", stations, err)
+	}
+	if _, err := j.Bodies(8); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v for an unknown system", err)
+	}
+}
+
+func TestIsScoopable(t *testing.T) {
+	for subType, want := range map[string]bool{
+		"K (Yellow-Orange) Star":          true,
+		"A (Blue-White super giant) Star": true,
+		"M (Red dwarf) Star":              true,
+		"Black Hole":                      false,
+		"Neutron Star":                    false,
+		"T Tauri Star":                    false,
+		"":                                false,
+	} {
+		if got := IsScoopable(subType); got != want {
+			t.Errorf("IsScoopable(%q) = %v", subType, got)
+		}
+	}
+}
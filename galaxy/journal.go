@@ -0,0 +1,281 @@
+package galaxy
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// standardGravity converts the surface gravity of the journal, in m/s², to g
+const standardGravity = 9.80665
+
+// starTypes maps the star types of the journal to the subtypes used by EDSM and Spansh
+var starTypes = map[string]string{
+	"O":                     "O (Blue-White) Star",
+	"B":                     "B (Blue-White) Star",
+	"A":                     "A (Blue-White) Star",
+	"F":                     "F (White) Star",
+	"G":                     "G (White-Yellow) Star",
+	"K":                     "K (Yellow-Orange) Star",
+	"M":                     "M (Red dwarf) Star",
+	"L":                     "L (Brown dwarf) Star",
+	"T":                     "T (Brown dwarf) Star",
+	"Y":                     "Y (Brown dwarf) Star",
+	"TTS":                   "T Tauri Star",
+	"AeBe":                  "Herbig Ae/Be Star",
+	"N":                     "Neutron Star",
+	"H":                     "Black Hole",
+	"SupermassiveBlackHole": "Supermassive Black Hole",
+	"B_BlueWhiteSuperGiant": "B (Blue-White super giant) Star",
+	"A_BlueWhiteSuperGiant": "A (Blue-White super giant) Star",
+	"F_WhiteSuperGiant":     "F (White super giant) Star",
+	"G_WhiteSuperGiant":     "G (White-Yellow super giant) Star",
+	"K_OrangeGiant":         "K (Yellow-Orange giant) Star",
+	"M_RedGiant":            "M (Red giant) Star",
+	"M_RedSuperGiant":       "M (Red super giant) Star",
+	"C":                     "C Star",
+	"CN":                    "CN Star",
+	"CJ":                    "CJ Star",
+	"MS":                    "MS-type Star",
+	"S":                     "S-type Star",
+	"W":                     "Wolf-Rayet Star",
+	"WN":                    "Wolf-Rayet N Star",
+	"WNC":                   "Wolf-Rayet NC Star",
+	"WC":                    "Wolf-Rayet C Star",
+	"WO":                    "Wolf-Rayet O Star",
+}
+
+// planetClasses maps the planet classes of the journal that are named differently by EDSM and Spansh
+var planetClasses = map[string]string{
+	"Metal rich body":                   "Metal-rich body",
+	"High metal content body":           "High metal content world",
+	"Rocky ice body":                    "Rocky Ice world",
+	"Earthlike body":                    "Earth-like world",
+	"Gas giant with water based life":   "Gas giant with water-based life",
+	"Gas giant with ammonia based life": "Gas giant with ammonia-based life",
+	"Sudarsky class I gas giant":        "Class I gas giant",
+	"Sudarsky class II gas giant":       "Class II gas giant",
+	"Sudarsky class III gas giant":      "Class III gas giant",
+	"Sudarsky class IV gas giant":       "Class IV gas giant",
+	"Sudarsky class V gas giant":        "Class V gas giant",
+	"Helium rich gas giant":             "Helium-rich gas giant",
+}
+
+// stationTypes maps the station types of the journal to the names used by EDSM and Spansh
+var stationTypes = map[string]string{
+	"Coriolis":         "Coriolis Starport",
+	"Orbis":            "Orbis Starport",
+	"Ocellus":          "Ocellus Starport",
+	"Bernal":           "Ocellus Starport",
+	"FleetCarrier":     "Fleet Carrier",
+	"CraterOutpost":    "Planetary Outpost",
+	"CraterPort":       "Planetary Port",
+	"SurfaceStation":   "Planetary Port",
+	"AsteroidBase":     "Asteroid base",
+	"MegaShip":         "Mega ship",
+	"OnFootSettlement": "Odyssey Settlement",
+}
+
+// Journal is the provider of the bodies scanned and stations docked at in the game, as written to the
+// journal. It only knows what the commander has seen, but does not depend on any site.
+type Journal struct {
+	lock     sync.RWMutex
+	systems  map[int64]*System
+	stations map[int64][]Station
+	// version counts the lines learned from
+	version uint64
+}
+
+// NewJournal returns a provider without any information yet, which is added with Observe
+func NewJournal() *Journal {
+	return &Journal{
+		systems:  map[int64]*System{},
+		stations: map[int64][]Station{},
+	}
+}
+
+// journalEvent holds the fields of the journal events the provider learns from
+type journalEvent struct {
+	SystemAddress int64
+	StarSystem    string
+	SystemName    string
+
+	BodyID                int64
+	BodyName              string
+	StarType              string
+	PlanetClass           string
+	DistanceFromArrivalLS float64
+	Landable              bool
+	SurfaceGravity        float64
+	Volcanism             string
+	Materials             []struct {
+		Name    string
+		Percent float64
+	}
+
+	BodyCount int
+
+	StationName       string
+	StationType       string
+	StationAllegiance string
+	MarketID          int64
+	DistFromStarLS    float64
+	Docked            bool
+}
+
+// Version changes whenever the journal learned something, so that what was shown from it can be
+// looked up again
+func (j *Journal) Version() uint64 {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+	return j.version
+}
+
+// Name is "journal"
+func (j *Journal) Name() string {
+	return "journal"
+}
+
+// Observe learns from a journal line. Lines of events without galaxy information are ignored.
+func (j *Journal) Observe(event string, line []byte) {
+	switch event {
+	case "Scan", "FSSDiscoveryScan", "FSDJump", "CarrierJump", "Location", "Docked":
+	default:
+		return
+	}
+	var e journalEvent
+	if err := json.Unmarshal(line, &e); err != nil || e.SystemAddress == 0 {
+		return
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.version++
+	sys := j.system(e.SystemAddress)
+	if e.StarSystem != "" {
+		sys.Name = e.StarSystem
+	} else if e.SystemName != "" {
+		sys.Name = e.SystemName
+	}
+	switch event {
+	case "Scan":
+		j.addBody(sys, e)
+	case "FSSDiscoveryScan":
+		sys.BodyCount = e.BodyCount
+	case "Location", "CarrierJump":
+		if e.Docked {
+			j.addStation(e)
+		}
+	case "Docked":
+		j.addStation(e)
+	}
+}
+
+func (j *Journal) system(id64 int64) *System {
+	sys, ok := j.systems[id64]
+	if !ok {
+		sys = &System{ID64: uint64(id64)}
+		j.systems[id64] = sys
+	}
+	return sys
+}
+
+func (j *Journal) addBody(sys *System, e journalEvent) {
+	body := Body{
+		ID64:      BodyID64(e.SystemAddress, e.BodyID),
+		BodyID:    e.BodyID,
+		Name:      e.BodyName,
+		Gravity:   e.SurfaceGravity / standardGravity,
+		Volcanism: e.Volcanism,
+	}
+	switch {
+	case e.StarType != "":
+		body.Type = "Star"
+		body.SubType = starTypes[e.StarType]
+		if body.SubType == "" {
+			body.SubType = e.StarType + " Star"
+		}
+		body.IsMainStar = e.DistanceFromArrivalLS == 0
+		body.IsScoopable = IsScoopable(body.SubType)
+	case e.PlanetClass != "":
+		body.Type = "Planet"
+		body.SubType = e.PlanetClass
+		if name, ok := planetClasses[e.PlanetClass]; ok {
+			body.SubType = name
+		}
+		body.IsLandable = e.Landable
+	default:
+		// Belt clusters and rings
+		return
+	}
+	if len(e.Materials) > 0 {
+		body.Materials = map[string]float64{}
+		for _, m := range e.Materials {
+			if m.Name == "" {
+				continue
+			}
+			body.Materials[strings.ToUpper(m.Name[:1])+m.Name[1:]] = m.Percent
+		}
+	}
+
+	for i, b := range sys.Bodies {
+		if b.BodyID == body.BodyID {
+			sys.Bodies[i] = body
+			return
+		}
+	}
+	sys.Bodies = append(sys.Bodies, body)
+}
+
+func (j *Journal) addStation(e journalEvent) {
+	if e.StationName == "" {
+		return
+	}
+	st := Station{
+		ID:                e.MarketID,
+		Name:              e.StationName,
+		Type:              e.StationType,
+		DistanceToArrival: e.DistFromStarLS,
+		Allegiance:        e.StationAllegiance,
+	}
+	if name, ok := stationTypes[e.StationType]; ok {
+		st.Type = name
+	}
+	stations := j.stations[e.SystemAddress]
+	for i, s := range stations {
+		if s.Name == st.Name {
+			stations[i] = st
+			return
+		}
+	}
+	j.stations[e.SystemAddress] = append(stations, st)
+}
+
+// Bodies returns the bodies scanned in the system
+func (j *Journal) Bodies(id64 int64) (System, error) {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+	sys, ok := j.systems[id64]
+	if !ok || (len(sys.Bodies) == 0 && sys.BodyCount == 0) {
+		return System{}, ErrNotFound
+	}
+	s := *sys
+	s.Bodies = append([]Body{}, sys.Bodies...)
+	return s, nil
+}
+
+// Value is not known from the journal
+func (j *Journal) Value(int64) (System, error) {
+	return System{}, ErrNotFound
+}
+
+// Stations returns the stations docked at in the system
+func (j *Journal) Stations(id64 int64) ([]Station, error) {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+	stations, ok := j.stations[id64]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]Station{}, stations...), nil
+}
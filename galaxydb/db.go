@@ -0,0 +1,644 @@
+package galaxydb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pellux-network/EDxDC/galaxy"
+	"github.com/pellux-network/EDxDC/logging"
+	"github.com/rs/zerolog/log"
+	"go.etcd.io/bbolt"
+	bolterrors "go.etcd.io/bbolt/errors"
+)
+
+/*
+ Module keeping the galaxy information imported from the dumps of Spansh and EDSM on disk, for players
+ that fly offline or don't want to wait for the sites.
+
+ The systems are appended to a data file as they are imported, each record starting with the ID64 of
+ the system and the length of the JSON that follows. Updating a system appends a new record. Where the
+ last record of each system is kept in the index, a bbolt database next to the data file, together with
+ the size of the data file it covers. Each Put writes its records to the index in one transaction.
+ Records written after that are found again when the database is opened, so an import that was
+ interrupted loses nothing. The records replaced by updates stay in the data file until Compact
+ rewrites it.
+*/
+
+const (
+	dataFile  = "systems.dat"
+	indexFile = "systems.idx"
+
+	// recordHeaderSize is the ID64 and the length of the record
+	recordHeaderSize = 12
+	// maxRecordSize protects against reading garbage as a record length
+	maxRecordSize = 64 << 20
+	// indexBatchSize is the number of systems written to the index in one transaction when it is rebuilt
+	indexBatchSize = 100000
+
+	indexVersion = 2
+)
+
+var (
+	// bucketSystems holds the entry of each system, by the big endian ID64
+	bucketSystems = []byte("systems")
+	// bucketMeta holds the counters below
+	bucketMeta = []byte("meta")
+
+	keyVersion = []byte("version")
+	// keyCovered is the size of the data file the index covers
+	keyCovered = []byte("covered")
+	// keyCount is the number of systems in the index
+	keyCount = []byte("count")
+	// keyLive is the size of the records the index points to, the rest of the data file is garbage
+	keyLive = []byte("live")
+)
+
+// Record is what the database knows about a system
+type Record struct {
+	System   galaxy.System    `json:"system"`
+	Stations []galaxy.Station `json:"stations,omitempty"`
+}
+
+// entry is the location of a record in the data file
+type entry struct {
+	offset int64
+	length uint32
+}
+
+// size is the size of the record in the data file, with its header
+func (e entry) size() int64 {
+	return recordHeaderSize + int64(e.length)
+}
+
+func (e entry) encode() []byte {
+	var v [12]byte
+	binary.BigEndian.PutUint64(v[:8], uint64(e.offset))
+	binary.BigEndian.PutUint32(v[8:], e.length)
+	return v[:]
+}
+
+func decodeIndexEntry(v []byte) entry {
+	return entry{offset: int64(binary.BigEndian.Uint64(v[:8])), length: binary.BigEndian.Uint32(v[8:])}
+}
+
+func indexKey(id64 int64) []byte {
+	var k [8]byte
+	binary.BigEndian.PutUint64(k[:], uint64(id64))
+	return k[:]
+}
+
+// DB is the offline galaxy database. It is safe to use from several routines.
+type DB struct {
+	dir string
+
+	lock  sync.RWMutex
+	data  *os.File
+	size  int64
+	index *bbolt.DB
+	// count and live mirror the counters in the index
+	count int64
+	live  int64
+}
+
+// Exists tells if there is a database in the folder
+func Exists(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, dataFile))
+	return err == nil
+}
+
+// Open opens the database in the folder, creating it if needed
+func Open(dir string) (*DB, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	// Left by a compaction that was interrupted
+	os.Remove(filepath.Join(dir, dataFile+".tmp"))
+	os.Remove(filepath.Join(dir, indexFile+".tmp"))
+
+	db := &DB{dir: dir}
+	if err := db.open(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// open opens the data file and the index, rebuilding the index if it does not match the data file
+func (db *DB) open() error {
+	data, err := os.OpenFile(filepath.Join(db.dir, dataFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := data.Stat()
+	if err != nil {
+		data.Close()
+		return err
+	}
+	db.data, db.size = data, info.Size()
+
+	indexPath := filepath.Join(db.dir, indexFile)
+	index, err := openIndex(indexPath)
+	if errors.Is(err, bolterrors.ErrTimeout) {
+		data.Close()
+		return errors.New("the galaxy database is in use, by an import running at the same time for example")
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("dir", logging.CleanPath(db.dir)).Msg("Rebuilding galaxy database index")
+		os.Remove(indexPath)
+		if index, err = openIndex(indexPath); err != nil {
+			data.Close()
+			return err
+		}
+	}
+	db.index = index
+
+	covered, err := db.loadMeta()
+	if err != nil || covered > db.size {
+		if err == nil {
+			err = errors.New("index covers more than the data file")
+		}
+		log.Warn().Err(err).Str("dir", logging.CleanPath(db.dir)).Msg("Rebuilding galaxy database index")
+		if err := db.resetIndex(); err != nil {
+			return errors.Join(err, db.closeFiles())
+		}
+		covered = 0
+	}
+	if err := db.scan(covered); err != nil {
+		return errors.Join(err, db.closeFiles())
+	}
+	return nil
+}
+
+func openIndex(path string) (*bbolt.DB, error) {
+	index, err := bbolt.Open(path, 0644, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = index.Update(func(tx *bbolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(bucketSystems); err != nil {
+			return err
+		}
+		meta, err := tx.CreateBucketIfNotExists(bucketMeta)
+		if err != nil {
+			return err
+		}
+		if meta.Get(keyVersion) == nil {
+			return putCounter(meta, keyVersion, indexVersion)
+		}
+		return nil
+	})
+	if err != nil {
+		index.Close()
+		return nil, err
+	}
+	return index, nil
+}
+
+// Close closes the database
+func (db *DB) Close() error {
+	err := db.Flush()
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	return errors.Join(err, db.closeFiles())
+}
+
+func (db *DB) closeFiles() error {
+	return errors.Join(db.index.Close(), db.data.Close())
+}
+
+// Len returns the number of systems in the database
+func (db *DB) Len() int {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	return int(db.count)
+}
+
+// Garbage returns the size of the records in the data file that were replaced by updates
+func (db *DB) Garbage() int64 {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	return db.size - db.live
+}
+
+// ShouldCompact tells if the replaced records take more of the data file than the current ones
+func (db *DB) ShouldCompact() bool {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	return db.size-db.live > db.live
+}
+
+// Get returns the record of the system, if it is in the database
+func (db *DB) Get(id64 int64) (Record, bool, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	entries, err := db.lookup([]int64{id64})
+	if err != nil {
+		return Record{}, false, err
+	}
+	e, ok := entries[id64]
+	if !ok {
+		return Record{}, false, nil
+	}
+	r, err := db.read(id64, e)
+	return r, err == nil, err
+}
+
+// lookup returns the index entries of the systems that are in the database
+func (db *DB) lookup(ids []int64) (map[int64]entry, error) {
+	entries := map[int64]entry{}
+	err := db.index.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucketSystems)
+		for _, id64 := range ids {
+			if v := b.Get(indexKey(id64)); v != nil {
+				entries[id64] = decodeIndexEntry(v)
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// read reads the record at the entry
+func (db *DB) read(id64 int64, e entry) (Record, error) {
+	buf := make([]byte, e.length)
+	if _, err := db.data.ReadAt(buf, e.offset+recordHeaderSize); err != nil {
+		return Record{}, fmt.Errorf("reading system %d: %w", id64, err)
+	}
+	var r Record
+	if err := json.Unmarshal(buf, &r); err != nil {
+		return Record{}, fmt.Errorf("parsing system %d: %w", id64, err)
+	}
+	return r, nil
+}
+
+// Put stores the records. A system that is in the database already is updated: the fields set in
+// the new record replace the stored ones, the others are kept. Later records of the same system
+// replace earlier ones the same way.
+func (db *DB) Put(records ...Record) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	merged := map[int64]*Record{}
+	order := []int64{}
+	for _, r := range records {
+		id64 := int64(r.System.ID64)
+		if id64 == 0 {
+			continue
+		}
+		if m, ok := merged[id64]; ok {
+			*m = merge(r, *m)
+			continue
+		}
+		merged[id64] = &r
+		order = append(order, id64)
+	}
+	if len(order) == 0 {
+		return nil
+	}
+	stored, err := db.lookup(order)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(io.NewOffsetWriter(db.data, db.size))
+	offset := db.size
+	written := map[int64]entry{}
+	for _, id64 := range order {
+		r := *merged[id64]
+		if e, ok := stored[id64]; ok {
+			if old, err := db.read(id64, e); err != nil {
+				log.Warn().Err(err).Msg("Replacing unreadable galaxy database record")
+			} else {
+				r = merge(r, old)
+			}
+		}
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		var header [recordHeaderSize]byte
+		binary.LittleEndian.PutUint64(header[:8], uint64(id64))
+		binary.LittleEndian.PutUint32(header[8:], uint32(len(data)))
+		w.Write(header[:])
+		w.Write(data)
+		written[id64] = entry{offset, uint32(len(data))}
+		offset += int64(recordHeaderSize + len(data))
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("writing galaxy database: %w", err)
+	}
+	// The records are on disk before the index points to them
+	if err := db.data.Sync(); err != nil {
+		return fmt.Errorf("writing galaxy database: %w", err)
+	}
+	db.size = offset
+	return db.commit(written)
+}
+
+// commit writes the entries to the index, with the size of the data file it now covers
+func (db *DB) commit(written map[int64]entry) error {
+	count, live := db.count, db.live
+	err := db.index.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucketSystems)
+		for id64, e := range written {
+			key := indexKey(id64)
+			if old := b.Get(key); old != nil {
+				live -= decodeIndexEntry(old).size()
+			} else {
+				count++
+			}
+			live += e.size()
+			if err := b.Put(key, e.encode()); err != nil {
+				return err
+			}
+		}
+		meta := tx.Bucket(bucketMeta)
+		return errors.Join(
+			putCounter(meta, keyCovered, db.size),
+			putCounter(meta, keyCount, count),
+			putCounter(meta, keyLive, live),
+		)
+	})
+	if err != nil {
+		return fmt.Errorf("saving galaxy database index: %w", err)
+	}
+	db.count, db.live = count, live
+	return nil
+}
+
+// merge fills the fields of a that are not set from b
+func merge(a, b Record) Record {
+	return Record{
+		System:   galaxy.Merge(a.System, b.System),
+		Stations: galaxy.MergeStations(append([]galaxy.Station{}, a.Stations...), b.Stations),
+	}
+}
+
+// Flush makes sure the records are on disk. The index is saved by every Put.
+func (db *DB) Flush() error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	return db.data.Sync()
+}
+
+// Compact rewrites the data file with only the current record of every system, dropping the records
+// replaced by updates. The index is rebuilt with it. If the program stops during the compaction, the
+// database is opened as it was before, or with the index rebuilt from the compacted data file.
+func (db *DB) Compact() error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	dataPath := filepath.Join(db.dir, dataFile)
+	indexPath := filepath.Join(db.dir, indexFile)
+	size, err := db.compactTo(dataPath+".tmp", indexPath+".tmp")
+	if err != nil {
+		os.Remove(dataPath + ".tmp")
+		os.Remove(indexPath + ".tmp")
+		return fmt.Errorf("compacting galaxy database: %w", err)
+	}
+	before := db.size
+	if err := db.closeFiles(); err != nil {
+		return err
+	}
+	// Without an index, the index is rebuilt from whichever data file is there if the program stops
+	// before both files are replaced
+	err = errors.Join(
+		os.Remove(indexPath),
+		os.Rename(dataPath+".tmp", dataPath),
+		os.Rename(indexPath+".tmp", indexPath),
+	)
+	if err := errors.Join(err, db.open()); err != nil {
+		return fmt.Errorf("compacting galaxy database: %w", err)
+	}
+	log.Info().Int64("before", before).Int64("after", size).Msg("Compacted galaxy database")
+	return nil
+}
+
+// compactTo copies the current records to a new data file and their entries to a new index, in
+// the order of the ID64s, and returns the size of the new data file
+func (db *DB) compactTo(dataPath, indexPath string) (int64, error) {
+	os.Remove(indexPath)
+	file, err := os.Create(dataPath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	index, err := openIndex(indexPath)
+	if err != nil {
+		return 0, err
+	}
+	defer index.Close()
+
+	w := bufio.NewWriterSize(file, 1<<20)
+	var offset int64
+	var count int64
+	batch := map[int64]entry{}
+	save := func() error {
+		err := index.Update(func(tx *bbolt.Tx) error {
+			b := tx.Bucket(bucketSystems)
+			for id64, e := range batch {
+				if err := b.Put(indexKey(id64), e.encode()); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		clear(batch)
+		return err
+	}
+	err = db.index.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucketSystems).ForEach(func(k, v []byte) error {
+			e := decodeIndexEntry(v)
+			record := make([]byte, e.size())
+			if _, err := db.data.ReadAt(record, e.offset); err != nil {
+				return err
+			}
+			if _, err := w.Write(record); err != nil {
+				return err
+			}
+			batch[int64(binary.BigEndian.Uint64(k))] = entry{offset, e.length}
+			offset += e.size()
+			count++
+			if len(batch) >= indexBatchSize {
+				return save()
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := errors.Join(save(), w.Flush(), file.Sync()); err != nil {
+		return 0, err
+	}
+	err = index.Update(func(tx *bbolt.Tx) error {
+		meta := tx.Bucket(bucketMeta)
+		return errors.Join(
+			putCounter(meta, keyCovered, offset),
+			putCounter(meta, keyCount, count),
+			putCounter(meta, keyLive, offset),
+		)
+	})
+	return offset, err
+}
+
+// scan adds the records after the offset to the index. A record that was only partly written when
+// the program stopped is removed.
+func (db *DB) scan(offset int64) error {
+	r := bufio.NewReader(io.NewSectionReader(db.data, offset, db.size-offset))
+	var header [recordHeaderSize]byte
+	scanned := 0
+	batch := map[int64]entry{}
+	for offset < db.size {
+		_, err := io.ReadFull(r, header[:])
+		length := binary.LittleEndian.Uint32(header[8:])
+		if err == nil && (length > maxRecordSize || offset+recordHeaderSize+int64(length) > db.size) {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			log.Warn().Int64("offset", offset).Msg("Removing incomplete record from galaxy database")
+			if err := db.data.Truncate(offset); err != nil {
+				return err
+			}
+			db.size = offset
+			break
+		}
+		if _, err := r.Discard(int(length)); err != nil {
+			return err
+		}
+		batch[int64(binary.LittleEndian.Uint64(header[:8]))] = entry{offset, length}
+		offset += recordHeaderSize + int64(length)
+		scanned++
+		if len(batch) >= indexBatchSize {
+			if err := db.commitScanned(batch, offset); err != nil {
+				return err
+			}
+		}
+	}
+	if err := db.commitScanned(batch, db.size); err != nil {
+		return err
+	}
+	if scanned > 0 {
+		log.Debug().Int("records", scanned).Msg("Indexed galaxy database records")
+	}
+	return nil
+}
+
+// commitScanned writes the entries found by scan to the index, covering the data file up to the offset
+func (db *DB) commitScanned(batch map[int64]entry, offset int64) error {
+	size := db.size
+	db.size = offset
+	err := db.commit(batch)
+	db.size = size
+	clear(batch)
+	return err
+}
+
+// loadMeta reads the counters of the index, and returns the size of the data file it covers
+func (db *DB) loadMeta() (int64, error) {
+	var covered int64
+	err := db.index.View(func(tx *bbolt.Tx) error {
+		meta := tx.Bucket(bucketMeta)
+		if version := getCounter(meta, keyVersion); version != indexVersion {
+			return fmt.Errorf("unknown index version %d", version)
+		}
+		covered = getCounter(meta, keyCovered)
+		db.count = getCounter(meta, keyCount)
+		db.live = getCounter(meta, keyLive)
+		return nil
+	})
+	return covered, err
+}
+
+// resetIndex removes all entries from the index
+func (db *DB) resetIndex() error {
+	db.count, db.live = 0, 0
+	return db.index.Update(func(tx *bbolt.Tx) error {
+		if err := tx.DeleteBucket(bucketSystems); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucket(bucketSystems); err != nil {
+			return err
+		}
+		meta := tx.Bucket(bucketMeta)
+		return errors.Join(
+			putCounter(meta, keyVersion, indexVersion),
+			putCounter(meta, keyCovered, 0),
+			putCounter(meta, keyCount, 0),
+			putCounter(meta, keyLive, 0),
+		)
+	})
+}
+
+func putCounter(b *bbolt.Bucket, key []byte, value int64) error {
+	var v [8]byte
+	binary.BigEndian.PutUint64(v[:], uint64(value))
+	return b.Put(key, v[:])
+}
+
+func getCounter(b *bbolt.Bucket, key []byte) int64 {
+	v := b.Get(key)
+	if len(v) != 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(v))
+}
+
+// Name is "offline"
+func (db *DB) Name() string {
+	return "offline"
+}
+
+// Bodies returns the imported bodies of the system
+func (db *DB) Bodies(id64 int64) (galaxy.System, error) {
+	r, err := db.record(id64)
+	if err != nil {
+		return galaxy.System{}, err
+	}
+	if len(r.System.Bodies) == 0 && r.System.BodyCount == 0 {
+		return galaxy.System{}, galaxy.ErrNotFound
+	}
+	return r.System, nil
+}
+
+// Value returns the imported value of the system, which only the Spansh dumps have
+func (db *DB) Value(id64 int64) (galaxy.System, error) {
+	r, err := db.record(id64)
+	if err != nil {
+		return galaxy.System{}, err
+	}
+	if r.System.EstimatedValue == 0 && len(r.System.ValuableBodies) == 0 {
+		return galaxy.System{}, galaxy.ErrNotFound
+	}
+	return r.System, nil
+}
+
+// Stations returns the imported stations of the system
+func (db *DB) Stations(id64 int64) ([]galaxy.Station, error) {
+	r, err := db.record(id64)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.Stations) == 0 {
+		return nil, galaxy.ErrNotFound
+	}
+	return r.Stations, nil
+}
+
+func (db *DB) record(id64 int64) (Record, error) {
+	r, ok, err := db.Get(id64)
+	if err != nil {
+		return Record{}, err
+	}
+	if !ok {
+		return Record{}, galaxy.ErrNotFound
+	}
+	return r, nil
+}
@@ -0,0 +1,254 @@
+package galaxydb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pellux-network/EDxDC/galaxy"
+)
+
+const (
+	sol      = 10477373803
+	shinrara = 3932277478106
+	colonia  = 3238296097059
+)
+
+// gzipped writes a gzipped copy of the test dump, as the dumps are downloaded
+func gzipped(t *testing.T, name string) string {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	path := filepath.Join(t.TempDir(), name+".gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{
+		gzipped(t, "spansh-galaxy.json"),
+		filepath.Join("testdata", "edsm-bodies.json"),
+		filepath.Join("testdata", "edsm-stations.json"),
+	} {
+		if _, err := db.Import(context.Background(), path, nil); err != nil {
+			t.Fatalf("importing %s: %v", path, err)
+		}
+	}
+	if db.Len() != 3 {
+		t.Errorf("got %d systems, wanted 3", db.Len())
+	}
+
+	sys, err := db.Bodies(sol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sys.Bodies) != 3 || sys.MainStar().Name != "Sol" || !sys.MainStar().IsScoopable {
+		t.Errorf("got Sol %+v", sys)
+	}
+	if earth := sys.BodyByID(1); earth.Volcanism != "No volcanism" || earth.SubType != "Earth-like world" {
+		t.Errorf("EDSM and Spansh bodies not merged: %+v", earth)
+	}
+	if moon := sys.BodyByID(2); !moon.IsLandable || moon.Materials["Iron"] != 21.5 {
+		t.Errorf("got moon %+v", moon)
+	}
+	value, err := db.Value(sol)
+	if err != nil || value.EstimatedValue != 304000 || len(value.ValuableBodies) != 1 {
+		t.Errorf("got value %+v, %v", value, err)
+	}
+	stations, err := db.Stations(sol)
+	if err != nil || len(stations) != 2 {
+		t.Errorf("got stations %+v, %v", stations, err)
+	}
+
+	// EDSM dumps have no values
+	if _, err := db.Value(colonia); !errors.Is(err, galaxy.ErrNotFound) {
+		t.Errorf("got error %v for the value of Colonia", err)
+	}
+	if stations, _ := db.Stations(colonia); len(stations) != 1 || stations[0].Name != "Jaques Station" {
+		t.Errorf("got stations %+v", stations)
+	}
+	if _, err := db.Bodies(1); !errors.Is(err, galaxy.ErrNotFound) {
+		t.Errorf("got error %v for an unknown system", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if sys, err := db.Bodies(shinrara); err != nil || sys.Name != "Shinrarta Dezhra" {
+		t.Errorf("after reopening: got %+v, %v", sys, err)
+	}
+}
+
+func TestImportResumes(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	path := filepath.Join("testdata", "spansh-galaxy.json")
+	info, _ := os.Stat(path)
+	abs, _ := filepath.Abs(path)
+	// An earlier import stopped after the first entry
+	db.saveCheckpoint(checkpoint{File: abs, Size: info.Size(), ModTime: info.ModTime().UTC(), Entries: 1})
+
+	entries, err := db.Import(context.Background(), path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries != 2 || db.Len() != 1 {
+		t.Errorf("read %d entries and stored %d systems, wanted only the second one stored", entries, db.Len())
+	}
+	if _, err := os.Stat(filepath.Join(dir, checkpointFile)); !os.IsNotExist(err) {
+		t.Error("checkpoint kept after the import finished")
+	}
+}
+
+func TestOpenRecovers(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Put(Record{System: galaxy.System{ID64: sol, Name: "Sol"}})
+	db.Flush()
+	db.Put(Record{System: galaxy.System{ID64: colonia, Name: "Colonia"}})
+	// The program stops in the middle of writing a record, before the index is saved again
+	db.data.WriteAt([]byte{1, 2, 3, 4, 5, 6, 7, 8, 200}, db.size)
+	db.closeFiles()
+
+	db, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Len() != 2 {
+		t.Errorf("got %d systems after recovery, wanted 2", db.Len())
+	}
+	if r, ok, _ := db.Get(colonia); !ok || r.System.Name != "Colonia" {
+		t.Errorf("record written after the index was saved is lost: %+v", r)
+	}
+	db.Put(Record{System: galaxy.System{ID64: colonia, BodyCount: 9}})
+	if r, _, _ := db.Get(colonia); r.System.Name != "Colonia" || r.System.BodyCount != 9 {
+		t.Errorf("update did not keep the known fields: %+v", r.System)
+	}
+	db.Close()
+}
+
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Put(Record{System: galaxy.System{ID64: sol, Name: "Sol"}}, Record{System: galaxy.System{ID64: colonia, Name: "Colonia"}})
+	db.Put(Record{System: galaxy.System{ID64: sol, BodyCount: 40}})
+	db.Put(Record{System: galaxy.System{ID64: sol, EstimatedValue: 1000}})
+	db.Put(Record{System: galaxy.System{ID64: colonia, BodyCount: 10}})
+	if db.Garbage() == 0 || !db.ShouldCompact() {
+		t.Fatal("updated records are not counted as garbage")
+	}
+
+	if err := db.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if db.Garbage() != 0 || db.ShouldCompact() || db.Len() != 2 {
+		t.Errorf("got %d bytes of garbage and %d systems after compacting", db.Garbage(), db.Len())
+	}
+	r, ok, err := db.Get(sol)
+	if err != nil || !ok || r.System.Name != "Sol" || r.System.BodyCount != 40 || r.System.EstimatedValue != 1000 {
+		t.Errorf("got %+v, %v after compacting, wanted all updates of Sol", r.System, err)
+	}
+	db.Put(Record{System: galaxy.System{ID64: shinrara, Name: "Shinrarta Dezhra"}})
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, dataFile+".tmp")); !os.IsNotExist(err) {
+		t.Errorf("temporary data file left behind: %v", err)
+	}
+
+	db, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if db.Len() != 3 {
+		t.Errorf("got %d systems after reopening, wanted 3", db.Len())
+	}
+	if r, ok, _ := db.Get(colonia); !ok || r.System.Name != "Colonia" {
+		t.Errorf("got Colonia %+v after reopening", r.System)
+	}
+}
+
+func TestOpenRebuildsIndex(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Put(Record{System: galaxy.System{ID64: sol, Name: "Sol"}}, Record{System: galaxy.System{ID64: colonia, Name: "Colonia"}})
+	db.Put(Record{System: galaxy.System{ID64: sol, BodyCount: 40}})
+	db.Close()
+
+	// A compaction stopped after removing the index, or the index is unreadable
+	for _, index := range []func() error{
+		func() error { return os.Remove(filepath.Join(dir, indexFile)) },
+		func() error { return os.WriteFile(filepath.Join(dir, indexFile), []byte("garbage"), 0644) },
+	} {
+		if err := index(); err != nil {
+			t.Fatal(err)
+		}
+		db, err := Open(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r, ok, _ := db.Get(sol); !ok || r.System.Name != "Sol" || r.System.BodyCount != 40 {
+			t.Errorf("got Sol %+v from the rebuilt index, wanted its last record", r.System)
+		}
+		if db.Len() != 2 || db.Garbage() == 0 {
+			t.Errorf("got %d systems and %d bytes of garbage, wanted 2 systems and the replaced record", db.Len(), db.Garbage())
+		}
+		db.Close()
+	}
+}
+
+func TestOpenInUse(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.Put(Record{System: galaxy.System{ID64: sol, Name: "Sol"}})
+
+	if _, err := Open(dir); err == nil {
+		t.Fatal("opened a database that is in use")
+	}
+	// The index is kept for the one using it
+	if r, ok, _ := db.Get(sol); !ok || r.System.Name != "Sol" {
+		t.Errorf("got Sol %+v after opening it again failed", r.System)
+	}
+	if _, err := os.Stat(filepath.Join(dir, indexFile)); err != nil {
+		t.Errorf("index removed: %v", err)
+	}
+}
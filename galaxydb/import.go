@@ -0,0 +1,257 @@
+package galaxydb
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pellux-network/EDxDC/galaxy"
+	"github.com/pellux-network/EDxDC/logging"
+	"github.com/pellux-network/EDxDC/spansh"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	checkpointFile = "import.json"
+	// batchSize is the number of dump entries collected before they are written to the database
+	batchSize = 10000
+	// checkpointInterval is the number of dump entries between saved checkpoints
+	checkpointInterval = 100000
+)
+
+// DumpKind is the format of a dump file
+type DumpKind string
+
+const (
+	// KindSpansh dumps are the galaxy dumps of Spansh, with a system per entry
+	KindSpansh DumpKind = "spansh"
+	// KindEDSMBodies dumps are the bodies dumps of EDSM, such as bodies7days, with a body per entry
+	KindEDSMBodies DumpKind = "edsm-bodies"
+	// KindEDSMStations dumps are the stations dump of EDSM, with a station per entry
+	KindEDSMStations DumpKind = "edsm-stations"
+)
+
+// checkpoint is the progress of an import, saved to continue it after an interruption
+type checkpoint struct {
+	File    string    `json:"file"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Entries int64     `json:"entries"`
+}
+
+// edsmBody is an entry of the EDSM bodies dumps
+type edsmBody struct {
+	galaxy.Body
+	VolcanismType string `json:"volcanismType"`
+	SystemID64    int64  `json:"systemId64"`
+	SystemName    string `json:"systemName"`
+}
+
+// edsmStation is an entry of the EDSM stations dump
+type edsmStation struct {
+	galaxy.Station
+	SystemID64 int64  `json:"systemId64"`
+	SystemName string `json:"systemName"`
+}
+
+// Import reads a dump file of Spansh or EDSM into the database, gzipped or not. The format is found
+// from the first entry. An import of the same file that was interrupted continues where it stopped.
+// The progress function, if set, is called now and then with the number of entries read.
+func (db *DB) Import(ctx context.Context, path string, progress func(entries int64)) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	abs, _ := filepath.Abs(path)
+	current := checkpoint{File: abs, Size: info.Size(), ModTime: info.ModTime().UTC()}
+	skip := db.resumeFrom(current)
+	if skip > 0 {
+		log.Info().Str("file", logging.CleanPath(path)).Int64("entries", skip).Msg("Continuing interrupted galaxy import")
+	}
+
+	r := bufio.NewReaderSize(file, 1<<20)
+	var in io.Reader = r
+	if magic, _ := r.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return 0, err
+		}
+		defer gz.Close()
+		in = gz
+	}
+	dec := json.NewDecoder(in)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return 0, fmt.Errorf("%s is not a dump file: it does not start with a list", filepath.Base(path))
+	}
+
+	var (
+		kind    DumpKind
+		entries int64
+		batch   []Record
+	)
+	save := func() error {
+		if err := db.Put(batch...); err != nil {
+			return err
+		}
+		batch = batch[:0]
+		if err := db.Flush(); err != nil {
+			return err
+		}
+		current.Entries = entries
+		return db.saveCheckpoint(current)
+	}
+
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return entries, errors.Join(fmt.Errorf("reading entry %d: %w", entries+1, err), save())
+		}
+		if kind == "" {
+			if kind, err = detectKind(raw); err != nil {
+				return 0, err
+			}
+			log.Info().Str("file", logging.CleanPath(path)).Str("kind", string(kind)).Msg("Importing galaxy dump")
+		}
+		entries++
+		if entries <= skip {
+			continue
+		}
+
+		record, err := decodeEntry(kind, raw)
+		if err != nil {
+			log.Warn().Err(err).Int64("entry", entries).Msg("Skipping unreadable dump entry")
+			continue
+		}
+		batch = append(batch, record)
+		if len(batch) >= batchSize {
+			if err := db.Put(batch...); err != nil {
+				return entries, err
+			}
+			batch = batch[:0]
+		}
+		if entries%checkpointInterval == 0 {
+			if err := save(); err != nil {
+				return entries, err
+			}
+			if progress != nil {
+				progress(entries)
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return entries, errors.Join(err, save())
+		}
+	}
+
+	if err := db.Put(batch...); err != nil {
+		return entries, err
+	}
+	if err := db.Flush(); err != nil {
+		return entries, err
+	}
+	if progress != nil {
+		progress(entries)
+	}
+	db.clearCheckpoint()
+	return entries, nil
+}
+
+// detectKind tells the format of the dump from an entry
+func detectKind(raw json.RawMessage) (DumpKind, error) {
+	var probe struct {
+		ID64       int64           `json:"id64"`
+		Bodies     json.RawMessage `json:"bodies"`
+		SystemID64 int64           `json:"systemId64"`
+		BodyID     *int64          `json:"bodyId"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return "", fmt.Errorf("reading first dump entry: %w", err)
+	}
+	switch {
+	case probe.SystemID64 != 0 && probe.BodyID != nil:
+		return KindEDSMBodies, nil
+	case probe.SystemID64 != 0:
+		return KindEDSMStations, nil
+	case probe.ID64 != 0:
+		return KindSpansh, nil
+	}
+	return "", errors.New("unknown dump format, expected a galaxy dump of Spansh or a bodies or stations dump of EDSM")
+}
+
+// decodeEntry turns a dump entry into the record of its system
+func decodeEntry(kind DumpKind, raw json.RawMessage) (Record, error) {
+	switch kind {
+	case KindSpansh:
+		var d spansh.Dump
+		if err := json.Unmarshal(raw, &d); err != nil {
+			return Record{}, err
+		}
+		return Record{System: galaxy.Merge(d.System(), d.Value()), Stations: d.StationList()}, nil
+	case KindEDSMBodies:
+		var b edsmBody
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return Record{}, err
+		}
+		b.Volcanism = b.VolcanismType
+		return Record{System: galaxy.System{
+			ID64:   uint64(b.SystemID64),
+			Name:   b.SystemName,
+			Bodies: []galaxy.Body{b.Body},
+		}}, nil
+	default:
+		var st edsmStation
+		if err := json.Unmarshal(raw, &st); err != nil {
+			return Record{}, err
+		}
+		return Record{
+			System:   galaxy.System{ID64: uint64(st.SystemID64), Name: st.SystemName},
+			Stations: []galaxy.Station{st.Station},
+		}, nil
+	}
+}
+
+// resumeFrom returns the number of entries of the file that were imported before, if the import of
+// the same file was interrupted
+func (db *DB) resumeFrom(current checkpoint) int64 {
+	data, err := os.ReadFile(filepath.Join(db.dir, checkpointFile))
+	if err != nil {
+		return 0
+	}
+	var saved checkpoint
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return 0
+	}
+	if saved.File != current.File || saved.Size != current.Size || !saved.ModTime.Equal(current.ModTime) {
+		return 0
+	}
+	return saved.Entries
+}
+
+func (db *DB) saveCheckpoint(c checkpoint) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(db.dir, checkpointFile)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (db *DB) clearCheckpoint() {
+	if err := os.Remove(filepath.Join(db.dir, checkpointFile)); err != nil && !os.IsNotExist(err) {
+		log.Warn().Err(err).Msg("Failed to remove galaxy import checkpoint")
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/pellux-network/EDxDC/conf"
+	"github.com/pellux-network/EDxDC/galaxydb"
+	"github.com/pellux-network/EDxDC/logging"
+	"github.com/rs/zerolog/log"
+)
+
+// galaxyDatabaseDir returns the folder of the offline galaxy database
+func galaxyDatabaseDir(baseDir string, cfg conf.Conf) string {
+	if cfg.Galaxy.Database != "" {
+		return cfg.Galaxy.Database
+	}
+	return filepath.Join(baseDir, "galaxy")
+}
+
+// runGalaxyImport imports dump files of Spansh or EDSM into the offline galaxy database. Importing a
+// newer dump updates the systems in it. An import that is interrupted continues when it is run again
+// with the same file.
+func runGalaxyImport(dir string, files []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := galaxydb.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	for _, file := range files {
+		entries, err := db.Import(ctx, file, func(entries int64) {
+			log.Info().Str("file", logging.CleanPath(file)).Int64("entries", entries).Msg("Importing galaxy dump")
+		})
+		if err != nil {
+			return err
+		}
+		log.Info().Str("file", logging.CleanPath(file)).Int64("entries", entries).Int("systems", db.Len()).Msg("Imported galaxy dump")
+	}
+	// Newer dumps replace the records of the systems in the older ones
+	if db.ShouldCompact() {
+		log.Info().Int64("garbage", db.Garbage()).Msg("Compacting galaxy database")
+		return db.Compact()
+	}
+	return nil
+}
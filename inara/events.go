@@ -0,0 +1,201 @@
+package inara
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pellux-network/EDxDC/edreader"
+)
+
+// ranks are the ranks of the Rank and Progress events. Inara uses the same names in lower case.
+var ranks = []string{"Combat", "Trade", "Explore", "Soldier", "Exobiologist", "CQC", "Federation", "Empire"}
+
+// journalEvent holds the fields of the journal events used for Inara
+type journalEvent struct {
+	Timestamp string `json:"timestamp"`
+
+	// FSDJump
+	StarSystem string
+	JumpDist   float64
+
+	// LoadGame, Loadout
+	Ship         string
+	ShipID       int64
+	ShipName     string
+	ShipIdent    string
+	HullValue    int64
+	ModulesValue int64
+	Rebuy        int64
+	Credits      int64
+	Loan         int64
+	Vessel       string
+	Inventory    []edreader.CargoLine
+}
+
+// RankData is the data of a setCommanderRankPilot event entry
+type RankData struct {
+	RankName     string  `json:"rankName"`
+	RankValue    int64   `json:"rankValue"`
+	RankProgress float64 `json:"rankProgress"`
+}
+
+// CargoData is the data of a setCommanderInventoryCargo event entry
+type CargoData struct {
+	ItemName  string `json:"itemName"`
+	ItemCount int    `json:"itemCount"`
+	IsStolen  bool   `json:"isStolen"`
+}
+
+// tracker remembers what Inara events need but is only found in earlier journal events
+type tracker struct {
+	ship   string
+	shipID int64
+
+	ranks    map[string]int64
+	progress map[string]float64
+}
+
+// events returns the Inara events for a journal event
+func (t *tracker) events(event string, line []byte, journalFolder string) ([]Event, error) {
+	var e journalEvent
+	switch event {
+	case "FSDJump", "LoadGame", "Loadout", "Cargo":
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, err
+		}
+	case "Rank", "Progress", "Promotion":
+		return t.rankEvents(event, line)
+	default:
+		return nil, nil
+	}
+
+	switch event {
+	case "FSDJump":
+		data := map[string]any{
+			"starsystemName": e.StarSystem,
+			"jumpDistance":   e.JumpDist,
+		}
+		if t.ship != "" {
+			data["shipType"] = t.ship
+			data["shipGameID"] = t.shipID
+		}
+		return []Event{{EventName: "addCommanderTravelFSDJump", EventTimestamp: e.Timestamp, EventData: data}}, nil
+	case "LoadGame":
+		t.ship, t.shipID = strings.ToLower(e.Ship), e.ShipID
+		return []Event{{
+			EventName:      "setCommanderCredits",
+			EventTimestamp: e.Timestamp,
+			EventData:      map[string]any{"commanderCredits": e.Credits, "commanderLoan": e.Loan},
+		}}, nil
+	case "Loadout":
+		t.ship, t.shipID = strings.ToLower(e.Ship), e.ShipID
+		return []Event{{
+			EventName:      "setCommanderShip",
+			EventTimestamp: e.Timestamp,
+			EventData: map[string]any{
+				"shipType":         t.ship,
+				"shipGameID":       e.ShipID,
+				"shipName":         e.ShipName,
+				"shipIdent":        e.ShipIdent,
+				"isCurrentShip":    true,
+				"shipHullValue":    e.HullValue,
+				"shipModulesValue": e.ModulesValue,
+				"shipRebuyCost":    e.Rebuy,
+			},
+		}}, nil
+	case "Cargo":
+		if e.Vessel != "" && e.Vessel != "Ship" {
+			// The SRV's cargo is not part of the commander's inventory on Inara
+			return nil, nil
+		}
+		inventory := e.Inventory
+		if inventory == nil {
+			// The game only writes the inventory to Cargo.json for most changes
+			data, err := os.ReadFile(filepath.Join(journalFolder, edreader.FileCargo))
+			if err != nil {
+				return nil, err
+			}
+			var cargo edreader.Cargo
+			if err := json.Unmarshal(data, &cargo); err != nil {
+				return nil, err
+			}
+			inventory = cargo.Inventory
+		}
+		return []Event{{EventName: "setCommanderInventoryCargo", EventTimestamp: e.Timestamp, EventData: cargoData(inventory)}}, nil
+	}
+	return nil, nil
+}
+
+// cargoData splits the inventory into the stolen and the legal items
+func cargoData(inventory []edreader.CargoLine) []CargoData {
+	data := []CargoData{}
+	for _, item := range inventory {
+		name := strings.ToLower(item.Name)
+		if legal := item.Count - item.Stolen; legal > 0 {
+			data = append(data, CargoData{ItemName: name, ItemCount: legal})
+		}
+		if item.Stolen > 0 {
+			data = append(data, CargoData{ItemName: name, ItemCount: item.Stolen, IsStolen: true})
+		}
+	}
+	return data
+}
+
+// rankEvents returns setCommanderRankPilot once both the ranks and the progress towards the next
+// ones are known
+func (t *tracker) rankEvents(event string, line []byte) ([]Event, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return nil, err
+	}
+	var timestamp string
+	if err := json.Unmarshal(fields["timestamp"], &timestamp); err != nil {
+		return nil, errors.New("event has no timestamp")
+	}
+	if t.ranks == nil {
+		t.ranks = map[string]int64{}
+		t.progress = map[string]float64{}
+	}
+
+	changed := map[string]bool{}
+	for _, rank := range ranks {
+		raw, ok := fields[rank]
+		if !ok {
+			continue
+		}
+		var value int64
+		if err := json.Unmarshal(raw, &value); err != nil {
+			continue
+		}
+		switch event {
+		case "Rank":
+			t.ranks[rank] = value
+		case "Progress":
+			t.progress[rank] = float64(value) / 100
+		case "Promotion":
+			t.ranks[rank] = value
+			t.progress[rank] = 0
+		}
+		changed[rank] = true
+	}
+	if event == "Rank" {
+		// Wait for the Progress event written right after it
+		return nil, nil
+	}
+
+	data := []RankData{}
+	for _, rank := range ranks {
+		value, known := t.ranks[rank]
+		if !changed[rank] || !known {
+			continue
+		}
+		data = append(data, RankData{RankName: strings.ToLower(rank), RankValue: value, RankProgress: t.progress[rank]})
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return []Event{{EventName: "setCommanderRankPilot", EventTimestamp: timestamp, EventData: data}}, nil
+}
@@ -0,0 +1,257 @@
+package inara
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pellux-network/EDxDC/conf"
+	"github.com/pellux-network/EDxDC/edreader"
+	"github.com/pellux-network/EDxDC/outbox"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+ Module keeping the commander's Inara profile up to date with the Inara API, as described on
+ https://inara.cz/elite/inara-api-docs/
+
+ Events are queued in an outbox file and sent in batches, as Inara asks clients not to send requests
+ more often than needed.
+*/
+
+const (
+	// DefaultURL is the Inara API endpoint
+	DefaultURL = "https://inara.cz/inapi/v1/"
+	// AppName identifies EDxDC to Inara
+	AppName = "EDxDC"
+
+	batchSize      = 100
+	linger         = 30 * time.Second
+	requestTimeout = 30 * time.Second
+	// outboxLimit bounds the outbox while Inara is unreachable for a long time
+	outboxLimit = 5000
+
+	// eventStatus values in Inara responses
+	statusOK    = 200
+	statusError = 400
+)
+
+// Event is a single event of an Inara request
+type Event struct {
+	EventName      string `json:"eventName"`
+	EventTimestamp string `json:"eventTimestamp"`
+	EventData      any    `json:"eventData"`
+}
+
+// Header identifies the app and the commander of a request
+type Header struct {
+	AppName             string `json:"appName"`
+	AppVersion          string `json:"appVersion"`
+	IsBeingDeveloped    bool   `json:"isBeingDeveloped"`
+	APIKey              string `json:"APIkey"`
+	CommanderName       string `json:"commanderName"`
+	CommanderFrontierID string `json:"commanderFrontierID,omitempty"`
+}
+
+// Request is the body of a request to the Inara API
+type Request struct {
+	Header Header  `json:"header"`
+	Events []Event `json:"events"`
+}
+
+// Response is the body of the Inara API response
+type Response struct {
+	Header struct {
+		EventStatus     int    `json:"eventStatus"`
+		EventStatusText string `json:"eventStatusText"`
+	} `json:"header"`
+	Events []struct {
+		EventStatus     int    `json:"eventStatus"`
+		EventStatusText string `json:"eventStatusText"`
+	} `json:"events"`
+}
+
+// queuedEvent is an event waiting in the outbox, with the commander it belongs to
+type queuedEvent struct {
+	Commander  string `json:"commander"`
+	FrontierID string `json:"fid"`
+	Event      Event  `json:"event"`
+}
+
+// Uploader sends commander data to Inara
+type Uploader struct {
+	url string
+	// cfg holds the API keys, which may be set for each commander
+	cfg           conf.Conf
+	appVersion    string
+	journalFolder string
+	client        *http.Client
+	sender        *outbox.Sender
+
+	// trackers holds the tracker of each commander, it is only used from the reader routine
+	trackers map[string]*tracker
+}
+
+// New creates an uploader reading Cargo.json from the journal folder of the config and queueing events
+// in the outbox file
+func New(cfg conf.Conf, outboxPath, appVersion string) (*Uploader, error) {
+	if !hasAPIKey(cfg) {
+		return nil, fmt.Errorf("Inara upload needs an API key")
+	}
+	queue, err := outbox.Open(outboxPath, outboxLimit)
+	if err != nil {
+		return nil, err
+	}
+	u := &Uploader{
+		url:           cfg.Inara.URL,
+		cfg:           cfg,
+		appVersion:    appVersion,
+		journalFolder: cfg.ExpandJournalFolderPath(),
+		client:        &http.Client{Timeout: requestTimeout},
+		trackers:      map[string]*tracker{},
+	}
+	if u.url == "" {
+		u.url = DefaultURL
+	}
+	u.sender = &outbox.Sender{
+		Name:      "Inara",
+		Queue:     queue,
+		BatchSize: batchSize,
+		Linger:    linger,
+		Send:      u.upload,
+	}
+	return u, nil
+}
+
+// hasAPIKey tells if an API key is set for all commanders or for one of them
+func hasAPIKey(cfg conf.Conf) bool {
+	if cfg.Inara.APIKey != "" {
+		return true
+	}
+	for _, c := range cfg.Commanders {
+		if c.InaraAPIKey != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Start starts sending new journal events of the reader, and any events left in the outbox. The
+// events are queued to the outbox as the reader passes them on, and sent from the routine of the
+// sender, so that the reader does not wait for Inara.
+func (u *Uploader) Start(reader *edreader.Reader) {
+	log.Info().Int("queued", u.sender.Queue.Len()).Msg("Starting Inara upload")
+	u.sender.Start()
+	reader.AddJournalListener(u.HandleEvent)
+}
+
+// Close stops sending. Events not yet sent are kept for the next start.
+func (u *Uploader) Close() {
+	u.sender.Close()
+}
+
+// HandleEvent queues the Inara events for a journal event
+func (u *Uploader) HandleEvent(event string, line []byte, state edreader.Journalstate) {
+	t, ok := u.trackers[state.Commander]
+	if !ok {
+		// The ship and ranks of one commander must not end up in the events of another
+		t = &tracker{}
+		u.trackers[state.Commander] = t
+	}
+	events, err := t.events(event, line, u.journalFolder)
+	if err != nil {
+		log.Debug().Err(err).Str("event", event).Msg("Not sending event to Inara")
+		return
+	}
+	if len(events) == 0 || state.Commander == "" {
+		return
+	}
+	cfg := u.cfg.ForCommander(state.Commander).Inara
+	if cfg.APIKey == "" || (cfg.Commander != "" && !strings.EqualFold(state.Commander, cfg.Commander)) {
+		return
+	}
+	if strings.Contains(strings.ToLower(state.GameVersion), "beta") {
+		// Inara does not take data from beta versions of the game
+		return
+	}
+
+	queued := make([]any, len(events))
+	for i, e := range events {
+		queued[i] = queuedEvent{Commander: state.Commander, FrontierID: state.CommanderFID, Event: e}
+	}
+	if err := u.sender.Push(queued...); err != nil {
+		log.Error().Err(err).Str("event", event).Msg("Failed to queue Inara events")
+	}
+}
+
+// upload sends a batch of queued events, one request per commander in the batch
+func (u *Uploader) upload(messages []json.RawMessage) error {
+	var request *Request
+	flush := func() error {
+		if request == nil {
+			return nil
+		}
+		err := u.post(*request)
+		request = nil
+		return err
+	}
+	for _, message := range messages {
+		var queued queuedEvent
+		if err := json.Unmarshal(message, &queued); err != nil {
+			log.Warn().Err(err).Msg("Dropping unreadable Inara outbox entry")
+			continue
+		}
+		if request != nil && request.Header.CommanderName != queued.Commander {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if request == nil {
+			request = &Request{Header: Header{
+				AppName:             AppName,
+				AppVersion:          u.appVersion,
+				APIKey:              u.cfg.ForCommander(queued.Commander).Inara.APIKey,
+				CommanderName:       queued.Commander,
+				CommanderFrontierID: queued.FrontierID,
+			}}
+		}
+		request.Events = append(request.Events, queued.Event)
+	}
+	return flush()
+}
+
+// post sends a single request
+func (u *Uploader) post(request Request) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return outbox.Permanent(err)
+	}
+	resp, err := u.client.Post(u.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Inara request failed: %s", resp.Status)
+	}
+	var response Response
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("parsing Inara response: %w", err)
+	}
+
+	if response.Header.EventStatus >= statusError {
+		// Usually a wrong API key; keep the events until the config is fixed
+		log.Error().Int("status", response.Header.EventStatus).Str("msg", response.Header.EventStatusText).Msg("Inara refused the request, check the API key")
+		return fmt.Errorf("Inara refused request: %d %s", response.Header.EventStatus, response.Header.EventStatusText)
+	}
+	for i, event := range response.Events {
+		if event.EventStatus != statusOK && i < len(request.Events) {
+			log.Debug().Int("status", event.EventStatus).Str("msg", event.EventStatusText).Str("event", request.Events[i].EventName).Msg("Inara did not accept event")
+		}
+	}
+	log.Debug().Int("events", len(request.Events)).Msg("Sent events to Inara")
+	return nil
+}
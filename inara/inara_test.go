@@ -0,0 +1,183 @@
+package inara
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pellux-network/EDxDC/conf"
+	"github.com/pellux-network/EDxDC/edreader"
+)
+
+// fakeInara records the requests sent to it, and refuses those with the wrong API key
+type fakeInara struct {
+	*httptest.Server
+	requests chan Request
+}
+
+func newFakeInara(t *testing.T) *fakeInara {
+	f := &fakeInara{requests: make(chan Request, 10)}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request Request
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("invalid request: %v", err)
+			return
+		}
+		if request.Header.APIKey != "secret" {
+			w.Write([]byte(`{"header":{"eventStatus":400,"eventStatusText":"Invalid API key"}}`))
+			return
+		}
+		f.requests <- request
+		w.Write([]byte(`{"header":{"eventStatus":200},"events":[]}`))
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeInara) receive(t *testing.T) Request {
+	t.Helper()
+	select {
+	case request := <-f.requests:
+		return request
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing sent")
+		return Request{}
+	}
+}
+
+func newTestUploader(t *testing.T, server *fakeInara, apiKey string) *Uploader {
+	t.Helper()
+	return newTestUploaderConf(t, server, conf.Conf{Inara: conf.InaraConf{Enabled: true, APIKey: apiKey}})
+}
+
+func newTestUploaderConf(t *testing.T, server *fakeInara, cfg conf.Conf) *Uploader {
+	t.Helper()
+	cfg.JournalsFolder = t.TempDir()
+	cfg.Inara.URL = server.URL
+	u, err := New(cfg, filepath.Join(t.TempDir(), "outbox.json"), "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.sender.Linger = 10 * time.Millisecond
+	return u
+}
+
+func commander(name string) edreader.Journalstate {
+	state := edreader.Journalstate{Commander: name, CommanderFID: "F123"}
+	state.GameVersion = "4.0.0.1904"
+	return state
+}
+
+func TestUploadBatch(t *testing.T) {
+	server := newFakeInara(t)
+	u := newTestUploader(t, server, "secret")
+
+	lines := [][]string{
+		{"LoadGame", `{ "timestamp":"2025-01-01T00:00:00Z", "event":"LoadGame", "Commander":"Jameson", "Ship":"CobraMkIII", "ShipID":7, "Credits":1000, "Loan":0 }`},
+		{"Rank", `{ "timestamp":"2025-01-01T00:00:01Z", "event":"Rank", "Combat":2, "Trade":5 }`},
+		{"Progress", `{ "timestamp":"2025-01-01T00:00:01Z", "event":"Progress", "Combat":50, "Trade":10 }`},
+		{"Music", `{ "timestamp":"2025-01-01T00:00:02Z", "event":"Music", "MusicTrack":"NoTrack" }`},
+		{"FSDJump", `{ "timestamp":"2025-01-01T00:01:00Z", "event":"FSDJump", "StarSystem":"Sol", "JumpDist":8.5 }`},
+		{"Cargo", `{ "timestamp":"2025-01-01T00:02:00Z", "event":"Cargo", "Vessel":"Ship", "Count":5, "Inventory":[ { "Name":"Gold", "Count":5, "Stolen":2 } ] }`},
+	}
+	for _, line := range lines {
+		u.HandleEvent(line[0], []byte(line[1]), commander("Jameson"))
+	}
+	// Events of a second commander are sent in their own request
+	u.HandleEvent("FSDJump", []byte(`{ "timestamp":"2025-01-01T00:03:00Z", "event":"FSDJump", "StarSystem":"Achenar", "JumpDist":9 }`), commander("Someone"))
+	u.sender.Start()
+	defer u.Close()
+
+	request := server.receive(t)
+	if request.Header.CommanderName != "Jameson" || request.Header.CommanderFrontierID != "F123" || request.Header.AppName != AppName {
+		t.Errorf("got header %+v", request.Header)
+	}
+	want := []string{"setCommanderCredits", "setCommanderRankPilot", "addCommanderTravelFSDJump", "setCommanderInventoryCargo"}
+	if len(request.Events) != len(want) {
+		t.Fatalf("got %d events, wanted %v", len(request.Events), want)
+	}
+	for i, name := range want {
+		if request.Events[i].EventName != name {
+			t.Errorf("event %d: got %s, wanted %s", i, request.Events[i].EventName, name)
+		}
+	}
+
+	jump := request.Events[2].EventData.(map[string]any)
+	if jump["starsystemName"] != "Sol" || jump["shipType"] != "cobramkiii" || jump["shipGameID"] != float64(7) {
+		t.Errorf("got jump %v", jump)
+	}
+	ranks := request.Events[1].EventData.([]any)
+	if len(ranks) != 2 || ranks[0].(map[string]any)["rankProgress"] != 0.5 {
+		t.Errorf("got ranks %v", ranks)
+	}
+	cargo := request.Events[3].EventData.([]any)
+	if len(cargo) != 2 || cargo[1].(map[string]any)["isStolen"] != true || cargo[1].(map[string]any)["itemCount"] != float64(2) {
+		t.Errorf("got cargo %v", cargo)
+	}
+
+	if request := server.receive(t); request.Header.CommanderName != "Someone" || len(request.Events) != 1 {
+		t.Errorf("got %+v, wanted the jump of the second commander", request)
+	}
+}
+
+func TestRefusedRequestIsKept(t *testing.T) {
+	server := newFakeInara(t)
+	u := newTestUploader(t, server, "wrong")
+	u.sender.MinBackoff = time.Hour
+	u.sender.Start()
+	defer u.Close()
+
+	u.HandleEvent("FSDJump", []byte(`{ "timestamp":"2025-01-01T00:01:00Z", "event":"FSDJump", "StarSystem":"Sol", "JumpDist":8.5 }`), commander("Jameson"))
+	time.Sleep(200 * time.Millisecond)
+	if n := u.sender.Queue.Len(); n != 1 {
+		t.Errorf("got %d queued events, wanted the refused one kept", n)
+	}
+}
+
+func TestCommanderAPIKey(t *testing.T) {
+	server := newFakeInara(t)
+	u := newTestUploaderConf(t, server, conf.Conf{
+		Inara:      conf.InaraConf{Enabled: true},
+		Commanders: map[string]conf.CommanderConf{"jameson": {InaraAPIKey: "secret"}},
+	})
+	u.sender.Start()
+	defer u.Close()
+
+	jump := []byte(`{ "timestamp":"2025-01-01T00:01:00Z", "event":"FSDJump", "StarSystem":"Sol", "JumpDist":8.5 }`)
+	u.HandleEvent("FSDJump", jump, commander("Someone"))
+	u.HandleEvent("FSDJump", jump, commander("Jameson"))
+	if request := server.receive(t); request.Header.CommanderName != "Jameson" || len(request.Events) != 1 {
+		t.Errorf("got %+v, wanted only the jump of the commander with an API key", request)
+	}
+
+	if _, err := New(conf.Conf{Inara: conf.InaraConf{Enabled: true}}, filepath.Join(t.TempDir(), "outbox.json"), "1.0"); err == nil {
+		t.Error("no error without any API key")
+	}
+}
+
+func TestTrackerPerCommander(t *testing.T) {
+	server := newFakeInara(t)
+	u := newTestUploaderConf(t, server, conf.Conf{
+		Inara:      conf.InaraConf{Enabled: true},
+		Commanders: map[string]conf.CommanderConf{"someone": {InaraAPIKey: "secret"}},
+	})
+
+	u.HandleEvent("LoadGame", []byte(`{ "timestamp":"2025-01-01T00:00:00Z", "event":"LoadGame", "Commander":"Jameson", "Ship":"CobraMkIII", "ShipID":7 }`), commander("Jameson"))
+	u.HandleEvent("Rank", []byte(`{ "timestamp":"2025-01-01T00:00:01Z", "event":"Rank", "Combat":2, "Trade":5 }`), commander("Jameson"))
+	// The second commander's Progress and jump must not use the rank or ship of the first
+	u.HandleEvent("Progress", []byte(`{ "timestamp":"2025-01-01T00:01:00Z", "event":"Progress", "Combat":50, "Trade":10 }`), commander("Someone"))
+	u.HandleEvent("FSDJump", []byte(`{ "timestamp":"2025-01-01T00:01:01Z", "event":"FSDJump", "StarSystem":"Sol", "JumpDist":8.5 }`), commander("Someone"))
+	u.sender.Start()
+	defer u.Close()
+
+	request := server.receive(t)
+	if request.Header.CommanderName != "Someone" || len(request.Events) != 1 {
+		t.Fatalf("got %+v, wanted only the jump of the second commander", request)
+	}
+	if jump := request.Events[0].EventData.(map[string]any); jump["shipType"] != nil {
+		t.Errorf("got jump %v with the ship of another commander", jump)
+	}
+}
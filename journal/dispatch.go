@@ -0,0 +1,62 @@
+package journal
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Dispatcher passes events to the functions subscribed to them, in the order they subscribed. S is
+// what the functions work on besides the event, such as the state they update.
+type Dispatcher[S any] struct {
+	lock     sync.RWMutex
+	handlers map[string][]func(S, Event)
+	all      []func(S, Event)
+}
+
+// NewDispatcher returns a dispatcher without subscribers
+func NewDispatcher[S any]() *Dispatcher[S] {
+	return &Dispatcher[S]{handlers: map[string][]func(S, Event){}}
+}
+
+// On subscribes the function to the events of type T, which must be a registered event struct
+func On[T Event, S any](d *Dispatcher[S], fn func(S, T)) {
+	t := reflect.TypeFor[T]()
+	name, ok := nameOf(t)
+	if !ok {
+		panic(fmt.Sprintf("journal: %v is not a registered event", t))
+	}
+	d.OnName(name, func(s S, e Event) {
+		if event, ok := e.(T); ok {
+			fn(s, event)
+		}
+	})
+}
+
+// OnName subscribes the function to the events with the name, which may be events without a struct
+func (d *Dispatcher[S]) OnName(name string, fn func(S, Event)) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.handlers[name] = append(d.handlers[name], fn)
+}
+
+// OnAll subscribes the function to all events, including the unknown ones
+func (d *Dispatcher[S]) OnAll(fn func(S, Event)) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.all = append(d.all, fn)
+}
+
+// Dispatch calls the functions subscribed to the event, then the ones subscribed to all events
+func (d *Dispatcher[S]) Dispatch(s S, e Event) {
+	d.lock.RLock()
+	handlers := d.handlers[e.EventName()]
+	all := d.all
+	d.lock.RUnlock()
+	for _, fn := range handlers {
+		fn(s, e)
+	}
+	for _, fn := range all {
+		fn(s, e)
+	}
+}
@@ -0,0 +1,339 @@
+package journal
+
+import "time"
+
+// Position is where the player is, as told by the events about arriving somewhere
+type Position struct {
+	StarSystem    string
+	SystemAddress int64
+	// StarPos holds the galactic coordinates of the system, when the event has them
+	StarPos []float64
+
+	Body     string
+	BodyID   int64
+	BodyType string
+	Docked   bool
+
+	// Latitude and Longitude are set when the player is on the surface
+	Latitude  *float64
+	Longitude *float64
+}
+
+// GameInfo identifies the game client
+type GameInfo struct {
+	GameVersion string `json:"gameversion"`
+	Build       string `json:"build"`
+	Horizons    *bool
+	Odyssey     *bool
+}
+
+// Fileheader is the first event of every journal file
+type Fileheader struct {
+	Header
+	GameInfo
+	Part     int `json:"part"`
+	Language string
+}
+
+// Commander is written when the game loads, before LoadGame
+type Commander struct {
+	Header
+	FID  string
+	Name string
+}
+
+// LoadGame is written when the game loads the commander
+type LoadGame struct {
+	Header
+	GameInfo
+	FID       string
+	Commander string
+	Ship      string
+	ShipID    int64
+	ShipName  string
+	ShipIdent string
+	GameMode  string
+	Credits   int64
+	Loan      int64
+}
+
+// Location is written when the game loads, and after a resurrection
+type Location struct {
+	Header
+	Position
+	StationName string
+	StationType string
+	MarketID    int64
+}
+
+// FSDJump is written when arriving in a system after a hyperspace jump
+type FSDJump struct {
+	Header
+	Position
+	JumpDist  float64
+	FuelUsed  float64
+	FuelLevel float64
+}
+
+// CarrierJump is written when the fleet carrier the player is docked at jumps
+type CarrierJump struct {
+	Header
+	Position
+	StationName string
+	StationType string
+	MarketID    int64
+}
+
+// SupercruiseEntry is written when entering supercruise
+type SupercruiseEntry struct {
+	Header
+	StarSystem    string
+	SystemAddress int64
+}
+
+// SupercruiseExit is written when dropping out of supercruise
+type SupercruiseExit struct {
+	Header
+	Position
+}
+
+// Touchdown is written when landing on a planet
+type Touchdown struct {
+	Header
+	StarSystem       string
+	SystemAddress    int64
+	Body             string
+	BodyID           int64
+	Latitude         float64
+	Longitude        float64
+	PlayerControlled bool
+}
+
+// Liftoff is written when taking off from a planet
+type Liftoff struct {
+	Header
+	StarSystem       string
+	SystemAddress    int64
+	Body             string
+	BodyID           int64
+	PlayerControlled bool
+}
+
+// FSDTarget is written when selecting the next system to jump to
+type FSDTarget struct {
+	Header
+	Name          string
+	SystemAddress int64
+	StarClass     string
+	// RemainingJumpsInRoute is set when the target is on a plotted route
+	RemainingJumpsInRoute *int
+}
+
+// ApproachBody is written when approaching a planet in supercruise
+type ApproachBody struct {
+	Header
+	StarSystem    string
+	SystemAddress int64
+	Body          string
+	BodyID        int64
+}
+
+// ApproachSettlement is written when approaching a planetary settlement
+type ApproachSettlement struct {
+	Header
+	Name          string
+	MarketID      int64
+	SystemAddress int64
+	BodyID        int64
+	BodyName      string
+	Latitude      *float64
+	Longitude     *float64
+}
+
+// Loadout describes the current ship
+type Loadout struct {
+	Header
+	Ship      string
+	ShipID    int64
+	ShipName  string
+	ShipIdent string
+	// CargoCapacity is missing in older journals
+	CargoCapacity *int
+}
+
+// NavRouteClear is written when the plotted route is cleared
+type NavRouteClear struct {
+	Header
+}
+
+// ReceiveText is written when a message is received
+type ReceiveText struct {
+	Header
+	From             string
+	Message          string
+	MessageLocalised string `json:"Message_Localised"`
+	Channel          string
+}
+
+// Rank is written when the game loads, with the ranks of the commander
+type Rank struct {
+	Header
+	Ranks
+}
+
+// Promotion is written when the commander is promoted. Only the ranks that changed are set.
+type Promotion struct {
+	Header
+	Combat       *int
+	Trade        *int
+	Explore      *int
+	Soldier      *int
+	Exobiologist *int
+	Empire       *int
+	Federation   *int
+	CQC          *int
+}
+
+// Ranks are the ranks of a commander, as indexes into the rank names
+type Ranks struct {
+	Combat       int
+	Trade        int
+	Explore      int
+	Soldier      int
+	Exobiologist int
+	Empire       int
+	Federation   int
+	CQC          int
+}
+
+// Missions is written when the game loads, with the missions of the commander
+type Missions struct {
+	Header
+	Active   []MissionSummary
+	Failed   []MissionSummary
+	Complete []MissionSummary
+}
+
+// MissionSummary is a mission as listed in the Missions event
+type MissionSummary struct {
+	MissionID        int64
+	Name             string
+	PassengerMission bool
+	// Expires is the number of seconds left
+	Expires int64
+}
+
+// MissionAccepted is written when accepting a mission
+type MissionAccepted struct {
+	Header
+	MissionID          int64
+	Name               string
+	LocalisedName      string
+	Faction            string
+	DestinationSystem  string
+	DestinationStation string
+	Expiry             *time.Time
+}
+
+// MissionCompleted is written when a mission is handed in
+type MissionCompleted struct {
+	Header
+	MissionID int64
+	Name      string
+	Reward    int64
+}
+
+// MissionFailed is written when a mission fails
+type MissionFailed struct {
+	Header
+	MissionID int64
+	Name      string
+}
+
+// MissionAbandoned is written when the commander abandons a mission
+type MissionAbandoned struct {
+	Header
+	MissionID int64
+	Name      string
+}
+
+// Materials is written when the game loads, with the materials the commander has
+type Materials struct {
+	Header
+	Raw          []MaterialCount
+	Manufactured []MaterialCount
+	Encoded      []MaterialCount
+}
+
+// MaterialCount is a material and how many of it the commander has
+type MaterialCount struct {
+	Name  string
+	Count int
+}
+
+// MaterialCollected is written when collecting a material
+type MaterialCollected struct {
+	Header
+	Category string
+	Name     string
+	Count    int
+}
+
+// MaterialDiscarded is written when discarding a material
+type MaterialDiscarded struct {
+	Header
+	Category string
+	Name     string
+	Count    int
+}
+
+// Docked is written when docking at a station
+type Docked struct {
+	Header
+	StationName       string
+	StationType       string
+	StarSystem        string
+	SystemAddress     int64
+	MarketID          int64
+	DistFromStarLS    float64
+	StationAllegiance string
+}
+
+// Undocked is written when leaving a station
+type Undocked struct {
+	Header
+	StationName string
+	StationType string
+	MarketID    int64
+}
+
+func init() {
+	Register("Fileheader", func() Event { return &Fileheader{} })
+	Register("Commander", func() Event { return &Commander{} })
+	Register("LoadGame", func() Event { return &LoadGame{} })
+	Register("Location", func() Event { return &Location{} })
+	Register("FSDJump", func() Event { return &FSDJump{} })
+	Register("CarrierJump", func() Event { return &CarrierJump{} })
+	Register("SupercruiseEntry", func() Event { return &SupercruiseEntry{} })
+	Register("SupercruiseExit", func() Event { return &SupercruiseExit{} })
+	Register("Touchdown", func() Event { return &Touchdown{} })
+	Register("Liftoff", func() Event { return &Liftoff{} })
+	Register("FSDTarget", func() Event { return &FSDTarget{} })
+	Register("ApproachBody", func() Event { return &ApproachBody{} })
+	Register("ApproachSettlement", func() Event { return &ApproachSettlement{} })
+	Register("Loadout", func() Event { return &Loadout{} })
+	Register("NavRouteClear", func() Event { return &NavRouteClear{} })
+	Register("ReceiveText", func() Event { return &ReceiveText{} })
+	Register("Rank", func() Event { return &Rank{} })
+	Register("Promotion", func() Event { return &Promotion{} })
+	Register("Missions", func() Event { return &Missions{} })
+	Register("MissionAccepted", func() Event { return &MissionAccepted{} })
+	Register("MissionCompleted", func() Event { return &MissionCompleted{} })
+	Register("MissionFailed", func() Event { return &MissionFailed{} })
+	Register("MissionAbandoned", func() Event { return &MissionAbandoned{} })
+	Register("Materials", func() Event { return &Materials{} })
+	Register("MaterialCollected", func() Event { return &MaterialCollected{} })
+	Register("MaterialDiscarded", func() Event { return &MaterialDiscarded{} })
+	Register("Docked", func() Event { return &Docked{} })
+	Register("Undocked", func() Event { return &Undocked{} })
+}
@@ -0,0 +1,110 @@
+package journal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/buger/jsonparser"
+)
+
+/*
+ Module decoding the lines of the Elite Dangerous journal into typed events. Each known event has a
+ struct, registered under the name of the event. Events without a struct are decoded as Unknown, so
+ they still reach the subscribers of all events.
+*/
+
+// ErrNoEvent is returned for lines that are not a journal event
+var ErrNoEvent = errors.New("not a journal event")
+
+// Event is a decoded journal event. The event structs embed Header to implement it.
+type Event interface {
+	// EventName returns the name of the event, such as FSDJump
+	EventName() string
+	// Time returns the time the event happened
+	Time() time.Time
+	// Line returns the journal line the event was decoded from
+	Line() []byte
+
+	header() *Header
+}
+
+// Header holds the fields every event has
+type Header struct {
+	Timestamp time.Time `json:"timestamp"`
+	Event     string    `json:"event"`
+
+	line []byte
+}
+
+// EventName returns the name of the event
+func (h *Header) EventName() string {
+	return h.Event
+}
+
+// Time returns the time the event happened
+func (h *Header) Time() time.Time {
+	return h.Timestamp
+}
+
+// Line returns the journal line the event was decoded from
+func (h *Header) Line() []byte {
+	return h.line
+}
+
+func (h *Header) header() *Header {
+	return h
+}
+
+// Unknown is an event without a struct of its own. The fields can be read from the line.
+type Unknown struct {
+	Header
+}
+
+var (
+	registryLock sync.RWMutex
+	decoders     = map[string]func() Event{}
+	names        = map[reflect.Type]string{}
+)
+
+// Register adds the struct decoding an event. The function returns a new pointer to the struct.
+// Registering a name again replaces the struct.
+func Register(name string, newEvent func() Event) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	decoders[name] = newEvent
+	names[reflect.TypeOf(newEvent())] = name
+}
+
+// Decode decodes a journal line into the struct registered for its event, or into Unknown
+func Decode(line []byte) (Event, error) {
+	name, err := jsonparser.GetString(line, "event")
+	if err != nil || name == "" {
+		return nil, ErrNoEvent
+	}
+	registryLock.RLock()
+	newEvent, ok := decoders[name]
+	registryLock.RUnlock()
+
+	var e Event = &Unknown{}
+	if ok {
+		e = newEvent()
+	}
+	if err := json.Unmarshal(line, e); err != nil {
+		return nil, fmt.Errorf("decoding %s event: %w", name, err)
+	}
+	e.header().line = bytes.Clone(line)
+	return e, nil
+}
+
+// nameOf returns the event name registered for the struct type
+func nameOf(t reflect.Type) (string, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	name, ok := names[t]
+	return name, ok
+}
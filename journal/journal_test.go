@@ -0,0 +1,85 @@
+package journal
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const fsdJump = `{ "timestamp":"2024-05-01T12:30:00Z", "event":"FSDJump", "StarSystem":"Shinrarta Dezhra", "SystemAddress":3932277478106, "StarPos":[55.71875,17.59375,27.15625], "Body":"Shinrarta Dezhra A", "BodyID":1, "BodyType":"Star", "JumpDist":12.5 }`
+
+func TestDecode(t *testing.T) {
+	e, err := Decode([]byte(fsdJump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jump, ok := e.(*FSDJump)
+	if !ok {
+		t.Fatalf("got %T, wanted *FSDJump", e)
+	}
+	if jump.EventName() != "FSDJump" || !jump.Time().Equal(time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)) {
+		t.Errorf("got header %q %v", jump.EventName(), jump.Time())
+	}
+	if jump.StarSystem != "Shinrarta Dezhra" || jump.SystemAddress != 3932277478106 || len(jump.StarPos) != 3 || jump.JumpDist != 12.5 {
+		t.Errorf("got %+v", jump)
+	}
+	if jump.Latitude != nil {
+		t.Error("latitude set for an event without it")
+	}
+	if string(jump.Line()) != fsdJump {
+		t.Errorf("got line %s", jump.Line())
+	}
+}
+
+func TestDecodeUnknown(t *testing.T) {
+	e, err := Decode([]byte(`{ "timestamp":"2024-05-01T12:30:00Z", "event":"Music", "MusicTrack":"Exploration" }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := e.(*Unknown); !ok || e.EventName() != "Music" {
+		t.Errorf("got %T %q, wanted an unknown Music event", e, e.EventName())
+	}
+
+	for _, line := range []string{"", "not json", `{ "timestamp":"2024-05-01T12:30:00Z" }`} {
+		if _, err := Decode([]byte(line)); !errors.Is(err, ErrNoEvent) {
+			t.Errorf("got error %v for %q", err, line)
+		}
+	}
+	if _, err := Decode([]byte(`{ "event":"FSDJump", "SystemAddress":"Sol" }`)); err == nil {
+		t.Error("no error for a field of the wrong type")
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	d := NewDispatcher[*[]string]()
+	d.OnAll(func(calls *[]string, e Event) { *calls = append(*calls, "all "+e.EventName()) })
+	On(d, func(calls *[]string, e *FSDJump) { *calls = append(*calls, "jump "+e.StarSystem) })
+	d.OnName("Music", func(calls *[]string, e Event) { *calls = append(*calls, "music") })
+	On(d, func(calls *[]string, e *FSDJump) { *calls = append(*calls, "jump again") })
+
+	var calls []string
+	for _, line := range []string{fsdJump, `{ "event":"Music" }`, `{ "event":"Docked" }`} {
+		e, err := Decode([]byte(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.Dispatch(&calls, e)
+	}
+	want := []string{"jump Shinrarta Dezhra", "jump again", "all FSDJump", "music", "all Music", "all Docked"}
+	if len(calls) != len(want) {
+		t.Fatalf("got calls %q, wanted %q", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("got calls %q, wanted %q", calls, want)
+			break
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic subscribing to an unregistered struct")
+		}
+	}()
+	On(d, func(_ *[]string, e *struct{ Header }) {})
+}
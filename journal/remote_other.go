@@ -0,0 +1,11 @@
+//go:build !windows
+
+package journal
+
+import "strings"
+
+// remoteFolder tells if the folder is on a network share. Only UNC paths are recognized; folders
+// mounted from a share need the poll mode to be set.
+func remoteFolder(folder string) bool {
+	return strings.HasPrefix(folder, `\\`) || strings.HasPrefix(folder, "//")
+}
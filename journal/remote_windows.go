@@ -0,0 +1,20 @@
+package journal
+
+import (
+	"path/filepath"
+
+	"golang.org/x/sys/windows"
+)
+
+// remoteFolder tells if the folder is on a network share, including mapped network drives
+func remoteFolder(folder string) bool {
+	volume := filepath.VolumeName(folder)
+	if volume == "" {
+		return false
+	}
+	root, err := windows.UTF16PtrFromString(volume + `\`)
+	if err != nil {
+		return false
+	}
+	return windows.GetDriveType(root) == windows.DRIVE_REMOTE
+}
@@ -0,0 +1,255 @@
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// maxLineLength bounds a journal line, so that a broken file without line breaks does not use up the
+// memory. Longer lines are skipped.
+var maxLineLength = 64 << 20
+
+// ErrLineTooLong is returned for lines that are too long to be a journal event
+var ErrLineTooLong = errors.New("journal line too long")
+
+// File is a journal file in the journal folder
+type File struct {
+	Path string
+	// Time is the time in the file name, when the game started writing the file
+	Time time.Time
+	// Part counts the files of a game session, as the game starts a new one when a file gets large
+	Part int
+}
+
+// fileName matches the journal file names, which have the time in one of two formats
+var fileName = regexp.MustCompile(`^Journal\.(\d{4}-\d\d-\d\dT\d{6}|\d{12})\.(\d+)\.log$`)
+
+// ParseFileName returns the journal file with the time and part from its name, or false if it is not
+// the name of a journal file
+func ParseFileName(path string) (File, bool) {
+	m := fileName.FindStringSubmatch(filepath.Base(path))
+	if m == nil {
+		return File{}, false
+	}
+	layout := "060102150405"
+	if len(m[1]) > 12 {
+		layout = "2006-01-02T150405"
+	}
+	t, err := time.Parse(layout, m[1])
+	if err != nil {
+		return File{}, false
+	}
+	part, _ := strconv.Atoi(m[2])
+	return File{Path: path, Time: t, Part: part}, true
+}
+
+// newer tells if the file was started after the other one
+func (f File) newer(o File) bool {
+	if !f.Time.Equal(o.Time) {
+		return f.Time.After(o.Time)
+	}
+	return f.Part > o.Part
+}
+
+// Files lists the journal files in the folder, newest first. The files are ordered by the time in
+// their names, as the modification times tell which file was written last rather than which one the
+// game is writing now.
+func Files(folder string) ([]File, error) {
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return nil, err
+	}
+	var files []File
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if f, ok := ParseFileName(filepath.Join(folder, entry.Name())); ok {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].newer(files[j]) })
+	return files, nil
+}
+
+// FilePosition is how far a journal file was read
+type FilePosition struct {
+	// Offset is the number of bytes of the complete lines read
+	Offset int64
+	// Line is the number of lines read
+	Line int
+}
+
+// Line is a complete line of a journal file
+type Line struct {
+	File string
+	// Number is the number of the line in the file, starting at 1
+	Number int
+	// Data is the line without the line break. It is a copy, so it may be kept after the callback.
+	Data []byte
+}
+
+// ReadLines passes the complete lines of the file to fn. A line the game is still writing is left
+// out. It returns how far the file was read.
+func ReadLines(path string, fn func(Line)) (FilePosition, error) {
+	return readLines(path, FilePosition{}, fn)
+}
+
+// Tailer reads the lines the game appends to the journal files. Only complete lines are passed on: a
+// line the game is still writing is read once its line break is written.
+//
+// Tailer does not watch the folder itself. Read is called when the folder may have changed, whether
+// fsnotify told so or it was found by polling. Tailer is not safe for concurrent use.
+type Tailer struct {
+	folder string
+	files  map[string]*FilePosition
+}
+
+// NewTailer returns a tailer for the journal files in the folder
+func NewTailer(folder string) *Tailer {
+	return &Tailer{folder: folder, files: map[string]*FilePosition{}}
+}
+
+// Newest returns the newest journal file by the time in its name, or "" if there is none
+func (t *Tailer) Newest() string {
+	files, err := Files(t.folder)
+	if err != nil || len(files) == 0 {
+		return ""
+	}
+	return files[0].Path
+}
+
+// Files returns the journal files to read: the ones followed so far, which may still grow while
+// several game clients are running, and the newest one, which is last
+func (t *Tailer) Files() []string {
+	newest := t.Newest()
+	var followed []File
+	for path := range t.files {
+		if path == newest {
+			continue
+		}
+		f, ok := ParseFileName(path)
+		if !ok {
+			f = File{Path: path}
+		}
+		followed = append(followed, f)
+	}
+	sort.Slice(followed, func(i, j int) bool { return followed[j].newer(followed[i]) })
+	files := make([]string, 0, len(followed)+1)
+	for _, f := range followed {
+		files = append(files, f.Path)
+	}
+	if newest != "" {
+		files = append(files, newest)
+	}
+	return files
+}
+
+// Follow follows the file from the position, such as one saved by an earlier run
+func (t *Tailer) Follow(path string, pos FilePosition) {
+	t.files[path] = &pos
+}
+
+// Positions returns how far each followed file was read
+func (t *Tailer) Positions() map[string]FilePosition {
+	positions := make(map[string]FilePosition, len(t.files))
+	for path, pos := range t.files {
+		positions[path] = *pos
+	}
+	return positions
+}
+
+// Read passes the new complete lines of the files returned by Files to fn, file by file
+func (t *Tailer) Read(fn func(Line)) error {
+	var errs []error
+	for _, path := range t.Files() {
+		if err := t.ReadFile(path, fn); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ReadFile passes the complete lines appended to the file since it was last read to fn, and follows
+// the file from now on. A file that got shorter is read again from the start.
+func (t *Tailer) ReadFile(path string, fn func(Line)) error {
+	pos, ok := t.files[path]
+	if !ok {
+		pos = &FilePosition{}
+		t.files[path] = pos
+	}
+	read, err := readLines(path, *pos, fn)
+	*pos = read
+	return err
+}
+
+// readLines passes the complete lines of the file after the position to fn
+func readLines(path string, pos FilePosition, fn func(Line)) (FilePosition, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return pos, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return pos, err
+	}
+	if info.Size() < pos.Offset {
+		// Not the file that was read before
+		pos = FilePosition{}
+	}
+	if info.Size() == pos.Offset {
+		return pos, nil
+	}
+	if _, err := file.Seek(pos.Offset, io.SeekStart); err != nil {
+		return pos, err
+	}
+
+	var errs []error
+	reader := bufio.NewReaderSize(file, 64*1024)
+	lineStart := pos.Offset
+	// long holds the start of a line longer than the buffer
+	var long []byte
+	tooLong := false
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		pos.Offset += int64(len(chunk))
+		if err == bufio.ErrBufferFull {
+			if !tooLong && len(long)+len(chunk) <= maxLineLength {
+				long = append(long, chunk...)
+			} else {
+				long, tooLong = nil, true
+			}
+			continue
+		}
+		if err != nil {
+			// The rest of the line is not written yet, it is read again next time
+			pos.Offset = lineStart
+			if err != io.EOF {
+				errs = append(errs, err)
+			}
+			return pos, errors.Join(errs...)
+		}
+		pos.Line++
+		lineStart = pos.Offset
+		// chunk is only valid until the next read, the line is copied out of the reader's buffer
+		data := slices.Concat(long, chunk)
+		long = nil
+		if tooLong || len(data) > maxLineLength {
+			errs = append(errs, fmt.Errorf("%s line %d: %w", filepath.Base(path), pos.Line, ErrLineTooLong))
+			tooLong = false
+			continue
+		}
+		fn(Line{File: path, Number: pos.Line, Data: bytes.TrimRight(data, "\r\n")})
+	}
+}
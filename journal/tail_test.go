@@ -0,0 +1,240 @@
+package journal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readAll(t *testing.T, tailer *Tailer) []string {
+	t.Helper()
+	var lines []string
+	if err := tailer.Read(func(l Line) { lines = append(lines, string(l.Data)) }); err != nil {
+		t.Fatal(err)
+	}
+	return lines
+}
+
+func TestTailerPartialLines(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Journal.2025-01-01T100000.01.log")
+	tailer := NewTailer(dir)
+
+	appendFile(t, path, `{ "event":"Fileheader" }`+"\n"+`{ "event":"Loca`)
+	if lines := readAll(t, tailer); len(lines) != 1 || lines[0] != `{ "event":"Fileheader" }` {
+		t.Errorf("got %q, wanted only the complete line", lines)
+	}
+	appendFile(t, path, `tion" }`+"\r\n")
+	if lines := readAll(t, tailer); len(lines) != 1 || lines[0] != `{ "event":"Location" }` {
+		t.Errorf("got %q, wanted the line once completed", lines)
+	}
+	if lines := readAll(t, tailer); len(lines) != 0 {
+		t.Errorf("got %q again", lines)
+	}
+	if pos := tailer.Positions()[path]; pos.Line != 2 || pos.Offset != int64(len(`{ "event":"Fileheader" }`+"\n"+`{ "event":"Location" }`+"\r\n")) {
+		t.Errorf("got position %+v", pos)
+	}
+
+	// A file written again from the start is read again
+	if err := os.WriteFile(path, []byte(`{ "event":"Music" }`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if lines := readAll(t, tailer); len(lines) != 1 || lines[0] != `{ "event":"Music" }` {
+		t.Errorf("got %q after truncating", lines)
+	}
+}
+
+func TestTailerLongLines(t *testing.T) {
+	defer func(max int) { maxLineLength = max }(maxLineLength)
+	maxLineLength = 300 * 1024
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Journal.2025-01-01T100000.01.log")
+	tailer := NewTailer(dir)
+	long := `{ "event":"Loadout", "Modules":"` + strings.Repeat("x", 200*1024) + `" }`
+	tooLong := strings.Repeat("y", 400*1024)
+
+	appendFile(t, path, long[:100*1024])
+	if lines := readAll(t, tailer); len(lines) != 0 {
+		t.Errorf("got %d lines for a line being written", len(lines))
+	}
+	appendFile(t, path, long[100*1024:]+"\n"+tooLong+"\n"+`{ "event":"Music" }`+"\n")
+	var lines []Line
+	err := tailer.Read(func(l Line) { lines = append(lines, l) })
+	if !errors.Is(err, ErrLineTooLong) {
+		t.Errorf("got error %v, wanted the too long line reported", err)
+	}
+	if len(lines) != 2 || string(lines[0].Data) != long || lines[1].Number != 3 {
+		t.Errorf("got %d lines, wanted the long line and the one after the too long one", len(lines))
+	}
+}
+
+func TestReadLinesKeepsData(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Journal.2025-01-01T100000.01.log")
+	// More lines than fit into the buffer of the reader at once
+	var want []string
+	for i := range 5000 {
+		want = append(want, fmt.Sprintf(`{ "event":"Music", "MusicTrack":"Track%d" }`, i))
+	}
+	appendFile(t, path, strings.Join(want, "\n")+"\n")
+
+	var lines []Line
+	if _, err := ReadLines(path, func(l Line) { lines = append(lines, l) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, wanted %d", len(lines), len(want))
+	}
+	for i, l := range lines {
+		if string(l.Data) != want[i] {
+			t.Fatalf("line %d changed after reading on: got %q, wanted %q", i+1, l.Data, want[i])
+		}
+	}
+}
+
+func TestTailerNewestByName(t *testing.T) {
+	dir := t.TempDir()
+	older := filepath.Join(dir, "Journal.240101100000.01.log")
+	newer := filepath.Join(dir, "Journal.2025-01-01T100000.01.log")
+	part := filepath.Join(dir, "Journal.2025-01-01T100000.02.log")
+	appendFile(t, older, "{}\n")
+	appendFile(t, newer, "{}\n")
+	appendFile(t, filepath.Join(dir, "Status.json"), "{}\n")
+	// The older file was written last, such as by a second game client
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(older, future, future)
+
+	tailer := NewTailer(dir)
+	if got := tailer.Newest(); got != newer {
+		t.Errorf("got newest %s", got)
+	}
+	tailer.ReadFile(older, func(Line) {})
+	appendFile(t, part, "{}\n")
+	if got := tailer.Files(); len(got) != 2 || got[0] != older || got[1] != part {
+		t.Errorf("got files %q, wanted the followed file and the next part", got)
+	}
+}
+
+// TestTailerConcurrentWriters reads journals while game clients write them in chunks that split the
+// lines anywhere, and start new files
+func TestTailerConcurrentWriters(t *testing.T) {
+	dir := t.TempDir()
+	const clients = 3
+	const lines = 2000
+
+	var wg sync.WaitGroup
+	for c := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rnd := rand.New(rand.NewPCG(uint64(c), 1))
+			var pending []byte
+			file := ""
+			for n := range lines {
+				if n%(lines/2) == 0 {
+					// A new session or part, started later than the files before
+					file = filepath.Join(dir, fmt.Sprintf("Journal.2025-01-0%dT10%02d00.%02d.log", c+1, n/(lines/2), n/(lines/2)+1))
+				}
+				pad := rnd.IntN(100)
+				if rnd.IntN(100) == 0 {
+					pad = 100*1024 + rnd.IntN(100*1024)
+				}
+				line := fmt.Sprintf(`{ "event":"Test", "client":%d, "seq":%d, "pad":"%s" }`+"\n", c, n, strings.Repeat("p", pad))
+				pending = append(pending, line...)
+				// Write a random part of what is pending, ending anywhere in a line
+				cut := rnd.IntN(len(pending) + 1)
+				if n%(lines/2) == lines/2-1 {
+					cut = len(pending)
+				}
+				f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				f.Write(pending[:cut])
+				f.Close()
+				pending = pending[cut:]
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	tailer := NewTailer(dir)
+	// next is the next line expected in each file. The files of a client are written one after the
+	// other, but may be read in one go.
+	next := map[string]int{}
+	total := map[int]int{}
+	read := func() {
+		for _, f := range filesIn(t, dir) {
+			err := tailer.ReadFile(f, func(l Line) {
+				var client, seq int
+				if _, err := fmt.Sscanf(string(l.Data), `{ "event":"Test", "client":%d, "seq":%d,`, &client, &seq); err != nil {
+					t.Fatalf("%s line %d: garbled line %.60q", filepath.Base(l.File), l.Number, l.Data)
+				}
+				file, _ := ParseFileName(l.File)
+				if want := (file.Part-1)*lines/2 + next[l.File]; seq != want {
+					t.Fatalf("client %d: got line %d, wanted %d", client, seq, want)
+				}
+				if !bytes.HasSuffix(l.Data, []byte(`" }`)) {
+					t.Fatalf("client %d line %d is cut off", client, seq)
+				}
+				next[l.File]++
+				total[client]++
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+			time.Sleep(time.Millisecond)
+		}
+		read()
+	}
+	for c := range clients {
+		if total[c] != lines {
+			t.Errorf("client %d: got %d lines, wanted %d", c, total[c], lines)
+		}
+	}
+}
+
+// filesIn returns the journal files in the folder, oldest first
+func filesIn(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := Files(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[len(files)-1-i] = f.Path
+	}
+	return paths
+}
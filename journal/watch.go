@@ -0,0 +1,320 @@
+package journal
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watch modes
+const (
+	// WatchAuto polls folders on network shares and uses change notifications otherwise
+	WatchAuto = "auto"
+	// WatchNotify uses change notifications, unless they cannot be set up for the folder
+	WatchNotify = "notify"
+	// WatchPoll looks for changes at an interval
+	WatchPoll = "poll"
+)
+
+// defaultPollInterval is how often the folder is polled if the interval is not set
+const defaultPollInterval = time.Second
+
+// WatchOptions sets how the journal folder is watched
+type WatchOptions struct {
+	// Mode is one of the watch modes, WatchAuto if empty
+	Mode string
+	// Interval is how often the folder is polled, and Jitter is the most added to it at random, so that
+	// several readers of a share do not all poll at once
+	Interval time.Duration
+	Jitter   time.Duration
+}
+
+// FolderChange tells which files in the folder changed
+type FolderChange struct {
+	// Files are the names of the changed files
+	Files map[string]bool
+	// All is set when changes may have been missed, so any file may have changed
+	All bool
+}
+
+// Has tells if the file with the name may have changed
+func (c FolderChange) Has(name string) bool {
+	return c.All || c.Files[name]
+}
+
+// Journals tells if any journal file may have changed
+func (c FolderChange) Journals() bool {
+	if c.All {
+		return true
+	}
+	for name := range c.Files {
+		if _, ok := ParseFileName(name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Any tells if any file changed
+func (c FolderChange) Any() bool {
+	return c.All || len(c.Files) > 0
+}
+
+// Watcher tells when the files in the journal folder may have changed. Change notifications do not
+// work reliably on network shares, such as when the journals of the gaming PC are read from a second
+// one, so the folder is polled there instead. Should notifications fail while watching, the watcher
+// goes on polling.
+type Watcher struct {
+	folder   string
+	interval time.Duration
+	jitter   time.Duration
+	notify   *fsnotify.Watcher
+	polling  atomic.Bool
+	changes  chan struct{}
+	// changed are the changes not taken yet
+	changed   FolderChange
+	changedMu sync.Mutex
+	errors    chan error
+	closing   chan struct{}
+	done      chan struct{}
+	close     sync.Once
+}
+
+// Watch starts watching the folder. It only fails for an unknown mode: if notifications cannot be
+// set up, the folder is polled and the reason passed on to Errors.
+func Watch(folder string, opts WatchOptions) (*Watcher, error) {
+	w := &Watcher{
+		folder:   folder,
+		interval: opts.Interval,
+		jitter:   max(opts.Jitter, 0),
+		changes:  make(chan struct{}, 1),
+		errors:   make(chan error, 8),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if w.interval <= 0 {
+		w.interval = defaultPollInterval
+	}
+
+	poll := false
+	switch opts.Mode {
+	case "", WatchAuto:
+		poll = remoteFolder(folder)
+	case WatchNotify:
+	case WatchPoll:
+		poll = true
+	default:
+		return nil, fmt.Errorf("unknown watch mode %q", opts.Mode)
+	}
+	if !poll {
+		if err := w.startNotify(); err != nil {
+			w.error(fmt.Errorf("watching %s for changes: %w, polling it instead", folder, err))
+			poll = true
+		}
+	}
+	w.polling.Store(poll)
+	if poll {
+		// Changes from now on are told, even if the routine starts polling later
+		files, err := w.scan()
+		go w.runPoll(files, err)
+	} else {
+		go w.run()
+	}
+	return w, nil
+}
+
+// Changes receives a value when files in the folder changed, which Changed tells. Changes made until
+// they are taken are told once.
+func (w *Watcher) Changes() <-chan struct{} {
+	return w.changes
+}
+
+// Changed returns the changes since it was last called
+func (w *Watcher) Changed() FolderChange {
+	w.changedMu.Lock()
+	defer w.changedMu.Unlock()
+	changed := w.changed
+	w.changed = FolderChange{}
+	return changed
+}
+
+// Errors receives the errors of watching the folder. The watcher goes on after them.
+func (w *Watcher) Errors() <-chan error {
+	return w.errors
+}
+
+// Mode returns WatchNotify or WatchPoll, depending on how the folder is watched now
+func (w *Watcher) Mode() string {
+	if w.polling.Load() {
+		return WatchPoll
+	}
+	return WatchNotify
+}
+
+// Close stops watching the folder
+func (w *Watcher) Close() error {
+	w.close.Do(func() { close(w.closing) })
+	<-w.done
+	return nil
+}
+
+func (w *Watcher) startNotify() error {
+	notify, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := notify.Add(w.folder); err != nil {
+		notify.Close()
+		return err
+	}
+	w.notify = notify
+	return nil
+}
+
+// run passes the change notifications on, and polls if they fail
+func (w *Watcher) run() {
+	if !w.runNotify() {
+		close(w.done)
+		return
+	}
+	w.polling.Store(true)
+	files, err := w.scan()
+	w.runPoll(files, err)
+}
+
+// runNotify passes the change notifications on until the watcher is closed, or returns true if the
+// notifications failed
+func (w *Watcher) runNotify() bool {
+	defer w.notify.Close()
+	for {
+		select {
+		case event, ok := <-w.notify.Events:
+			if !ok {
+				return true
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+				w.change(filepath.Base(event.Name))
+			}
+		case err, ok := <-w.notify.Errors:
+			if !ok {
+				return true
+			}
+			w.error(fmt.Errorf("watching %s for changes: %w, polling it from now on", w.folder, err))
+			// Changes may have been missed
+			w.change("")
+			return true
+		case <-w.closing:
+			return false
+		}
+	}
+}
+
+// fileStamp tells if a file changed between polls
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// runPoll looks for changed files at the interval until the watcher is closed, starting from the
+// files found by the last scan
+func (w *Watcher) runPoll(files map[string]fileStamp, lastErr error) {
+	defer close(w.done)
+	for {
+		wait := w.interval
+		if w.jitter > 0 {
+			wait += rand.N(w.jitter)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-w.closing:
+			timer.Stop()
+			return
+		}
+
+		scanned, err := w.scan()
+		if err != nil {
+			// The share may be gone for a while; only tell when the error is new
+			if lastErr == nil || err.Error() != lastErr.Error() {
+				w.error(err)
+			}
+			lastErr = err
+			continue
+		}
+		lastErr = nil
+		for _, name := range changedFiles(files, scanned) {
+			w.change(name)
+		}
+		files = scanned
+	}
+}
+
+// scan returns the size and modification time of the files in the folder
+func (w *Watcher) scan() (map[string]fileStamp, error) {
+	entries, err := os.ReadDir(w.folder)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]fileStamp, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// Removed since the folder was read
+			continue
+		}
+		files[entry.Name()] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+	}
+	return files, nil
+}
+
+// changedFiles returns the names of the files that were created, changed or removed between the scans
+func changedFiles(before, after map[string]fileStamp) []string {
+	var names []string
+	for name, stamp := range after {
+		old, ok := before[name]
+		if !ok || old.size != stamp.size || !old.modTime.Equal(stamp.modTime) {
+			names = append(names, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// change adds the file to the changes and tells about them. Without a name, any file may have changed.
+func (w *Watcher) change(name string) {
+	w.changedMu.Lock()
+	if name == "" {
+		w.changed.All = true
+	} else {
+		if w.changed.Files == nil {
+			w.changed.Files = map[string]bool{}
+		}
+		w.changed.Files[name] = true
+	}
+	w.changedMu.Unlock()
+	select {
+	case w.changes <- struct{}{}:
+	default:
+		// A change not received yet covers this one
+	}
+}
+
+func (w *Watcher) error(err error) {
+	select {
+	case w.errors <- err:
+	default:
+	}
+}
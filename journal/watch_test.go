@@ -0,0 +1,109 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitChange fails the test unless the watcher tells about a change of the file in time
+func waitChange(t *testing.T, w *Watcher, name, what string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case <-w.Changes():
+			if w.Changed().Has(name) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no change of %s told after %s", name, what)
+		}
+	}
+}
+
+func TestWatchPoll(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Journal.2025-01-01T100000.01.log")
+	appendFile(t, path, "{}\n")
+	w, err := Watch(dir, WatchOptions{Mode: WatchPoll, Interval: 10 * time.Millisecond, Jitter: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if w.Mode() != WatchPoll {
+		t.Errorf("got mode %s", w.Mode())
+	}
+
+	select {
+	case <-w.Changes():
+		t.Error("change told without any")
+	case <-time.After(100 * time.Millisecond):
+	}
+	appendFile(t, path, "{}\n")
+	waitChange(t, w, filepath.Base(path), "appending")
+	appendFile(t, filepath.Join(dir, "Status.json"), "{}")
+	waitChange(t, w, "Status.json", "creating a file")
+	if changed := w.Changed(); changed.Any() {
+		t.Errorf("got changes %+v again", changed)
+	}
+}
+
+func TestWatchNotify(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Watch(dir, WatchOptions{Mode: WatchNotify})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if w.Mode() != WatchNotify {
+		t.Fatalf("got mode %s", w.Mode())
+	}
+	appendFile(t, filepath.Join(dir, "Journal.2025-01-01T100000.01.log"), "{}\n")
+	waitChange(t, w, "Journal.2025-01-01T100000.01.log", "creating a journal")
+}
+
+func TestWatchMissingFolder(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "share")
+	w, err := Watch(dir, WatchOptions{Interval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if w.Mode() != WatchPoll {
+		t.Errorf("got mode %s, wanted polling when notifications cannot be set up", w.Mode())
+	}
+	select {
+	case <-w.Errors():
+	default:
+		t.Error("got no error for the missing folder")
+	}
+
+	// The share becomes available
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, filepath.Join(dir, "Journal.2025-01-01T100000.01.log"), "{}\n")
+	waitChange(t, w, "Journal.2025-01-01T100000.01.log", "the folder was created")
+}
+
+func TestWatchUnknownMode(t *testing.T) {
+	if _, err := Watch(t.TempDir(), WatchOptions{Mode: "inotify"}); err == nil {
+		t.Error("got no error for an unknown mode")
+	}
+}
+
+func TestFolderChange(t *testing.T) {
+	changed := FolderChange{Files: map[string]bool{"Status.json": true}}
+	if !changed.Has("Status.json") || changed.Has("Cargo.json") || changed.Journals() {
+		t.Errorf("got %+v wrong", changed)
+	}
+	changed.Files["Journal.2025-01-01T100000.01.log"] = true
+	if !changed.Journals() {
+		t.Error("journal change not told")
+	}
+	if all := (FolderChange{All: true}); !all.Has("Cargo.json") || !all.Journals() || !all.Any() {
+		t.Error("not every file changed with All")
+	}
+}
@@ -36,9 +36,12 @@ func onDeviceChanged(hdevice uintptr, added bool, context uintptr) uintptr {
 // The setActive flag indicates whether or not the new page is active (false if the profile page is set)
 func onPageChange(hdevice uintptr, page uint32, setActive bool, context uintptr) uintptr {
 	log.Trace().Uint32("page", page).Bool("setActive", setActive).Msg("onPageChange")
+	displayLock.Lock()
 	currentPage = page
 	pageActive = setActive
 	refreshDisplay()
+	displayLock.Unlock()
+	resetAutoScroll()
 
 	return S_OK
 }
@@ -48,8 +51,11 @@ func onSoftButton(hdevice uintptr, buttons uint32, context uintptr) uintptr {
 	log.Trace().Uint32("buttons", buttons).Msg("onSoftbutton")
 	switch buttons {
 	case softButton_Select:
-		if buttonCallback != nil {
-			buttonCallback()
+		displayLock.Lock()
+		page, active := currentPage, pageActive
+		displayLock.Unlock()
+		if buttonCallback != nil && active {
+			buttonCallback(page)
 		}
 	case softButton_Up:
 		decrementLine()
@@ -0,0 +1,190 @@
+package mfd
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+/*
+ The X52 Pro MFD does not show unicode text. It uses a HD44780 style character ROM (A00), which has
+ printable ASCII in its lower half, with the exception of the backslash and tilde, and a few latin,
+ greek and math symbols in its upper half. Text is translated to characters the display can show
+ before it is rendered, and then encoded to ROM codes when it is sent to the device.
+*/
+
+// FallbackGlyph is shown in place of characters the display has no glyph or transliteration for
+const FallbackGlyph = '?'
+
+// Special glyphs the display can show, for use in page text
+const (
+	GlyphArrowRight = '→'
+	GlyphArrowLeft  = '←'
+	GlyphDegree     = '°'
+	GlyphBlock      = '█'
+)
+
+// romGlyphs maps the non-ASCII characters the display can show to their code in the character ROM
+var romGlyphs = map[rune]uint16{
+	GlyphArrowRight: 0x7E,
+	GlyphArrowLeft:  0x7F,
+	'·':             0xA5,
+	'¥':             0x5C,
+	GlyphDegree:     0xDF,
+	'α':             0xE0,
+	'ä':             0xE1,
+	'β':             0xE2,
+	'ε':             0xE3,
+	'μ':             0xE4,
+	'σ':             0xE5,
+	'ρ':             0xE6,
+	'√':             0xE8,
+	'ñ':             0xEE,
+	'ö':             0xEF,
+	'θ':             0xF2,
+	'∞':             0xF3,
+	'Ω':             0xF4,
+	'ü':             0xF5,
+	'Σ':             0xF6,
+	'π':             0xF7,
+	'÷':             0xFD,
+	GlyphBlock:      0xFF,
+}
+
+// transliterations replaces characters without a glyph of their own. Characters not listed here are
+// reduced to their base letter if they carry an accent.
+var transliterations = map[rune]string{
+	// ROM positions that hold a different glyph than in ASCII
+	'\\': "/",
+	'~':  "-",
+
+	// Punctuation
+	'\u00a0': " ",
+	'‘':      "'",
+	'’':      "'",
+	'‚':      "'",
+	'‛':      "'",
+	'′':      "'",
+	'“':      "\"",
+	'”':      "\"",
+	'„':      "\"",
+	'″':      "\"",
+	'«':      "\"",
+	'»':      "\"",
+	'‐':      "-",
+	'‑':      "-",
+	'–':      "-",
+	'—':      "-",
+	'−':      "-",
+	'…':      "...",
+	'•':      "·",
+	'×':      "x",
+
+	// Symbols that map to another glyph
+	'µ': "μ",
+	'⇒': string(GlyphArrowRight),
+	'►': string(GlyphArrowRight),
+	'▶': string(GlyphArrowRight),
+	'⇐': string(GlyphArrowLeft),
+	'◄': string(GlyphArrowLeft),
+	'◀': string(GlyphArrowLeft),
+	'↑': "^",
+	'↓': "v",
+	'º': string(GlyphDegree),
+	'˚': string(GlyphDegree),
+
+	// Letters without a decomposition
+	'ß': "ss",
+	'ẞ': "SS",
+	'æ': "ae",
+	'Æ': "AE",
+	'œ': "oe",
+	'Œ': "OE",
+	'ø': "o",
+	'Ø': "O",
+	'đ': "d",
+	'Đ': "D",
+	'ð': "d",
+	'Ð': "D",
+	'ł': "l",
+	'Ł': "L",
+	'þ': "th",
+	'Þ': "Th",
+	'ı': "i",
+	'ħ': "h",
+	'Ħ': "H",
+}
+
+// Translate returns the text with every character replaced by one the display can show
+func Translate(s string) string {
+	if isPlainASCII(s) {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		b.WriteString(translateRune(r))
+	}
+	return b.String()
+}
+
+func translateRune(r rune) string {
+	if t, ok := transliterations[r]; ok {
+		return t
+	}
+	if isDisplayable(r) {
+		return string(r)
+	}
+	// Strip accents by decomposing the character and dropping the combining marks
+	base := strings.Builder{}
+	for _, d := range norm.NFD.String(string(r)) {
+		if unicode.Is(unicode.Mn, d) {
+			continue
+		}
+		if !isDisplayable(d) {
+			return string(FallbackGlyph)
+		}
+		base.WriteRune(d)
+	}
+	if base.Len() == 0 {
+		return string(FallbackGlyph)
+	}
+	return base.String()
+}
+
+// isDisplayable reports whether the display has a glyph for the character
+func isDisplayable(r rune) bool {
+	if r >= 0x20 && r < 0x7F {
+		_, replaced := transliterations[r]
+		return !replaced
+	}
+	_, ok := romGlyphs[r]
+	return ok
+}
+
+func isPlainASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c >= 0x7E || c == '\\' {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeROM converts translated text to the character codes sent to the device. Text that was not
+// translated first shows the fallback glyph for every character the display has no glyph for.
+func encodeROM(s string) []uint16 {
+	codes := make([]uint16, 0, len(s)+1)
+	for _, r := range s {
+		if code, ok := romGlyphs[r]; ok {
+			codes = append(codes, code)
+			continue
+		}
+		if !isDisplayable(r) {
+			r = FallbackGlyph
+		}
+		codes = append(codes, uint16(r))
+	}
+	return codes
+}
@@ -0,0 +1,76 @@
+package mfd
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Sol", "Sol"},
+		{"CMDR Jameson 42!", "CMDR Jameson 42!"},
+		{"Shinrarta Dezhra", "Shinrarta Dezhra"},
+		{"Beagle Point", "Beagle Point"},
+		{"Réaumur Orbital", "Reaumur Orbital"},
+		{"Čapek Ćity", "Capek City"},
+		{"Ångström", "Angström"},
+		{"Straße", "Strasse"},
+		{"Ørsted Æther", "Orsted AEther"},
+		{"Łódź", "Lodz"},
+		{"Señor", "Señor"},
+		{"Müller", "Müller"},
+		{"“Quoted” ‘text’", "\"Quoted\" 'text'"},
+		{"Dash – and — more", "Dash - and - more"},
+		{"Wait…", "Wait..."},
+		{"12°C", "12°C"},
+		{"A → B ← C", "A → B ← C"},
+		{"A ⇒ B", "A → B"},
+		{"C:\\Games", "C:/Games"},
+		{"~approx", "-approx"},
+		{"5µm", "5μm"},
+		{"no\u00a0break", "no break"},
+		{"東京", "??"},
+		{"Москва", "??????"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got := Translate(tt.in)
+		if got != tt.want {
+			t.Errorf("Translate(%q): got %q, wanted %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTranslateIsDisplayable(t *testing.T) {
+	in := "Ré “São” Paulo – 東 ~\\ 12°→ Straße…"
+	for _, r := range Translate(in) {
+		if !isDisplayable(r) {
+			t.Errorf("Translate(%q) contains %q, which the display cannot show", in, r)
+		}
+	}
+}
+
+func TestEncodeROM(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []uint16
+	}{
+		{"AB", []uint16{'A', 'B'}},
+		{"→←", []uint16{0x7E, 0x7F}},
+		{"20°", []uint16{'2', '0', 0xDF}},
+		{Translate("ü~"), []uint16{0xF5, '-'}},
+		// Untranslated text is not translated again
+		{"é", []uint16{FallbackGlyph}},
+		{"東", []uint16{FallbackGlyph}},
+		{Translate("é"), []uint16{'e'}},
+	}
+	for _, tt := range tests {
+		got := encodeROM(tt.in)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("encodeROM(%q): got %v, wanted %v", tt.in, got, tt.want)
+		}
+	}
+}
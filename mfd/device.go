@@ -2,10 +2,14 @@ package mfd
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/rs/zerolog/log"
 )
 
+// The number of lines the display can show at once
+const displayLines = 3
+
 // The current device handle
 var device uintptr = 0
 
@@ -15,8 +19,8 @@ var devicePages uint32 = 0
 // Whether or not the device has been loaded yet
 var loaded = false
 
-// User-defined callback function for the soft button click
-var buttonCallback func()
+// User-defined callback function for the soft button click. It receives the page the click happened on.
+var buttonCallback func(page uint32)
 
 // The current text content to display
 var currentDisplay Display
@@ -30,8 +34,13 @@ var pageActive bool
 // The line index for each page
 var currentLines []uint32
 
-// InitDevice sets up the device for use
-func InitDevice(pages uint32, softButtonCallback func()) error {
+// displayLock guards the display state above, which is accessed from the device callbacks as well as
+// from the routines updating the display
+var displayLock sync.Mutex
+
+// InitDevice sets up the device for use. softButtonCallback is invoked with the active page whenever the
+// soft button is clicked.
+func InitDevice(pages uint32, softButtonCallback func(page uint32)) error {
 	log.Info().Uint32("pages", pages).Msg("Initializing device driver")
 	if pages < 1 {
 		return fmt.Errorf("pages parameter must be a positive integer")
@@ -49,11 +58,19 @@ func InitDevice(pages uint32, softButtonCallback func()) error {
 	registerDeviceCallback()
 	log.Debug().Msg("Searching for device")
 	enumerate()
+
+	startAutoScroll()
+	startMarquee()
 	return nil
 }
 
 // DeInitDevice unregisters the device driver interaction. Should be called before terminating the program
 func DeInitDevice() {
+	stopAutoScroll()
+	stopMarquee()
+	if files != nil {
+		files.flush()
+	}
 	deinitialize()
 }
 
@@ -63,6 +80,11 @@ func UpdateDisplay(display Display) error {
 	if len(display.Pages) != int(devicePages) {
 		return fmt.Errorf("provided display has %d pages. Must have %d", len(display.Pages), devicePages)
 	}
+	displayLock.Lock()
+	defer displayLock.Unlock()
+	for p := range display.Pages {
+		currentLines[p] = scrollLine(currentDisplay.Pages[p], display.Pages[p], currentLines[p])
+	}
 	currentDisplay = display
 	refreshDisplay()
 	return nil
@@ -79,47 +101,51 @@ func initPages() {
 		for p := uint32(0); p < devicePages; p++ {
 			addPage(p, p == 0)
 		}
+		displayLock.Lock()
 		pageActive = true
 		refreshDisplay()
+		displayLock.Unlock()
 		loaded = true
 		log.Debug().Msg("Device init complete")
 	}
 }
 
 func incrementLine() {
+	displayLock.Lock()
+	defer displayLock.Unlock()
 	page := currentDisplay.Pages[currentPage]
 	line := currentLines[currentPage]
-	pageLines := uint32(len(page.Lines))
-	currentLines[currentPage] = min(line+1, pageLines)
+	currentLines[currentPage] = min(line+1, maxLine(page))
 	refreshDisplay()
+	resetAutoScroll()
 }
 
 func decrementLine() {
+	displayLock.Lock()
+	defer displayLock.Unlock()
 	line := currentLines[currentPage]
 	if line > 0 {
 		currentLines[currentPage] = line - 1
 	}
 	refreshDisplay()
+	resetAutoScroll()
 }
 
-// refreshDisplay refreshes the display to show the current values for page, line and display variables
+// refreshDisplay refreshes the display to show the current values for page, line and display variables.
+// The caller must hold displayLock.
 func refreshDisplay() {
 	if loaded && device > 0 && pageActive {
 		log.Trace().Uint32("page", currentPage).Msg("Refreshing display")
 		page := currentDisplay.Pages[currentPage]
-		line := currentLines[currentPage]
-
-		if line >= uint32(len(page.Lines)) {
-			line = uint32(len(page.Lines)) - 1
-		}
+		line := min(currentLines[currentPage], maxLine(page))
 
-		for l := uint32(0); l < 3; l++ {
+		for l := uint32(0); l < displayLines; l++ {
 			shiftedLine := int(line + l)
 			text := ""
 			if shiftedLine < len(page.Lines) {
 				text = page.Lines[shiftedLine]
 			}
-			setString(currentPage, l, text)
+			setString(currentPage, l, marqueeText(l, Translate(text)))
 		}
 	}
 
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package mfd
+
+import "github.com/rs/zerolog/log"
+
+// DirectOutput is only available on Windows. On other platforms the device is never found, which
+// allows the rest of the package to be built and tested.
+
+const S_OK = 0x00000000
+
+func initialize() {
+	log.Warn().Msg("DirectOutput is not available on this platform, no device will be found")
+}
+
+func deinitialize() {}
+
+func enumerate() {}
+
+func registerDeviceCallback() {}
+
+func registerPageCallback(device uintptr) {}
+
+func registerSoftButtonCallback(device uintptr) {}
+
+func addPage(pageNumber uint32, active bool) {}
+
+func setString(page, lineIdx uint32, line string) {}
@@ -61,10 +61,12 @@ func addPage(pageNumber uint32, active bool) {
 	callProc("DirectOutput_AddPage", device, uintptr(pageNumber), flag)
 }
 
+// setString shows translated text on a line of a page
 func setString(page, lineIdx uint32, line string) {
-	linePtr, _ := syscall.UTF16PtrFromString(line)
-	lineLen := uintptr(len(line))
-	callProc("DirectOutput_SetString", device, uintptr(page), uintptr(lineIdx), lineLen, uintptr(unsafe.Pointer(linePtr)))
+	codes := encodeROM(line)
+	lineLen := uintptr(len(codes))
+	codes = append(codes, 0)
+	callProc("DirectOutput_SetString", device, uintptr(page), uintptr(lineIdx), lineLen, uintptr(unsafe.Pointer(&codes[0])))
 }
 
 func callProc(procname string, args ...uintptr) {
@@ -0,0 +1,165 @@
+package mfd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pellux-network/EDxDC/logging"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultDebounce is the quiet time before the display is written to files, if no other time is configured
+	DefaultDebounce = 250 * time.Millisecond
+	// DefaultMaxDelay is the longest a change waits to be written while the display keeps changing, if
+	// no other time is configured
+	DefaultMaxDelay = 2 * time.Second
+)
+
+// FileOutputOptions controls writing the display to files for other tools
+type FileOutputOptions struct {
+	Enabled bool
+	// Dir is the directory Filename and the page files are written to
+	Dir string
+	// PageFiles additionally writes the text of every page to page<N>.txt
+	PageFiles bool
+	// Debounce is the time to wait for further changes before writing
+	Debounce time.Duration
+	// MaxDelay is the longest a change waits to be written, for displays that never stop changing
+	MaxDelay time.Duration
+}
+
+// fileOutput writes the display to files once it stops changing for the debounce time
+type fileOutput struct {
+	opts FileOutputOptions
+
+	lock    sync.Mutex
+	pending *Display
+	// since is when the pending display first changed after the last write
+	since time.Time
+	timer *time.Timer
+
+	// writeLock keeps flushes in order, so an older display never overwrites a newer one
+	writeLock sync.Mutex
+}
+
+var files *fileOutput
+
+// SetFileOutputOptions enables or disables writing the display to files
+func SetFileOutputOptions(opts FileOutputOptions) {
+	if !opts.Enabled {
+		files = nil
+		return
+	}
+	files = newFileOutput(opts)
+	log.Info().Str("dir", logging.CleanPath(opts.Dir)).Bool("pagefiles", opts.PageFiles).Msg("Writing MFD to files")
+}
+
+func newFileOutput(opts FileOutputOptions) *fileOutput {
+	if opts.Debounce <= 0 {
+		opts.Debounce = DefaultDebounce
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = DefaultMaxDelay
+	}
+	return &fileOutput{opts: opts}
+}
+
+// submit schedules the display to be written, replacing any display still waiting to be written. The
+// display is written once it stops changing, but no later than the max delay after the first change.
+func (f *fileOutput) submit(display Display) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.pending == nil {
+		f.since = time.Now()
+	}
+	d := display.Copy()
+	f.pending = &d
+	wait := f.opts.Debounce
+	if left := time.Until(f.since.Add(f.opts.MaxDelay)); left < wait {
+		wait = left
+	}
+	if f.timer == nil {
+		f.timer = time.AfterFunc(wait, f.flush)
+	} else {
+		f.timer.Reset(wait)
+	}
+}
+
+// flush writes the pending display, if any
+func (f *fileOutput) flush() {
+	f.writeLock.Lock()
+	defer f.writeLock.Unlock()
+	f.lock.Lock()
+	display := f.pending
+	f.pending = nil
+	f.lock.Unlock()
+	if display == nil {
+		return
+	}
+
+	data, err := json.MarshalIndent(display, "", "  ")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to marshal MFD display")
+		return
+	}
+	if err := writeFileAtomic(filepath.Join(f.opts.Dir, Filename), data); err != nil {
+		log.Warn().Err(err).Msg("Failed to write MFD file")
+	}
+	if !f.opts.PageFiles {
+		return
+	}
+	for i, page := range display.Pages {
+		name := filepath.Join(f.opts.Dir, fmt.Sprintf("page%d.txt", i+1))
+		if err := writeFileAtomic(name, []byte(strings.Join(page.Lines, "\n"))); err != nil {
+			log.Warn().Err(err).Int("page", i+1).Msg("Failed to write MFD page file")
+		}
+	}
+	f.removePageFiles(len(display.Pages))
+}
+
+// removePageFiles removes the files of pages after the given number of pages, left from displays
+// with more pages
+func (f *fileOutput) removePageFiles(pages int) {
+	names, err := filepath.Glob(filepath.Join(f.opts.Dir, "page*.txt"))
+	if err != nil {
+		return
+	}
+	for _, name := range names {
+		var page int
+		if _, err := fmt.Sscanf(filepath.Base(name), "page%d.txt", &page); err != nil || page <= pages ||
+			filepath.Base(name) != fmt.Sprintf("page%d.txt", page) {
+			continue
+		}
+		if err := os.Remove(name); err != nil {
+			log.Warn().Err(err).Int("page", page).Msg("Failed to remove MFD page file")
+		}
+	}
+}
+
+// writeFileAtomic replaces the file with data, so readers never see a partially written file
+func writeFileAtomic(filename string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package mfd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func testDisplay(lines ...string) Display {
+	return Display{Pages: []Page{{ID: "a", Lines: lines}, {ID: "b", Lines: []string{"second"}}}}
+}
+
+func TestFileOutputDebounce(t *testing.T) {
+	dir := t.TempDir()
+	f := newFileOutput(FileOutputOptions{Enabled: true, Dir: dir, PageFiles: true, Debounce: 200 * time.Millisecond})
+
+	f.submit(testDisplay("first"))
+	f.submit(testDisplay("updated"))
+	f.submit(testDisplay("latest", "line"))
+
+	if _, err := os.Stat(filepath.Join(dir, Filename)); !os.IsNotExist(err) {
+		t.Fatalf("file written before the debounce time passed: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	var data []byte
+	for time.Now().Before(deadline) {
+		var err error
+		if data, err = os.ReadFile(filepath.Join(dir, Filename)); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if data == nil {
+		t.Fatal("file was never written")
+	}
+
+	var got Display
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if !got.Pages[0].Equal(testDisplay("latest", "line").Pages[0]) {
+		t.Errorf("got %v, wanted the latest display", got)
+	}
+
+	page1, err := os.ReadFile(filepath.Join(dir, "page1.txt"))
+	if err != nil || string(page1) != "latest\nline" {
+		t.Errorf("got page1.txt %q (%v), wanted %q", page1, err, "latest\nline")
+	}
+	page2, err := os.ReadFile(filepath.Join(dir, "page2.txt"))
+	if err != nil || string(page2) != "second" {
+		t.Errorf("got page2.txt %q (%v), wanted %q", page2, err, "second")
+	}
+}
+
+func TestFileOutputMaxDelay(t *testing.T) {
+	dir := t.TempDir()
+	f := newFileOutput(FileOutputOptions{Enabled: true, Dir: dir, Debounce: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond})
+
+	// A display that changes faster than the debounce time is still written
+	start := time.Now()
+	for time.Since(start) < time.Second {
+		f.submit(testDisplay(time.Now().String()))
+		if _, err := os.Stat(filepath.Join(dir, Filename)); err == nil {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("file was not written while the display kept changing")
+}
+
+func TestFileOutputRemovesStalePageFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"page3.txt", "page03.txt", "pages.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("old"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f := newFileOutput(FileOutputOptions{Enabled: true, Dir: dir, PageFiles: true})
+	f.pending = &Display{Pages: []Page{{Lines: []string{"first"}}, {Lines: []string{"second"}}}}
+	f.flush()
+	f.pending = &Display{Pages: []Page{{Lines: []string{"only"}}}}
+	f.flush()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	// Only the page files written by the output are removed
+	want := []string{Filename, "page03.txt", "page1.txt", "pages.txt"}
+	if !slices.Equal(names, want) {
+		t.Errorf("got files %v, wanted %v", names, want)
+	}
+}
+
+func TestWriteFileAtomicLeavesNoTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, Filename)
+	for _, content := range []string{"one", "two"} {
+		if err := writeFileAtomic(name, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, _ := os.ReadFile(name)
+	if string(data) != "two" {
+		t.Errorf("got %q, wanted %q", data, "two")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("got %d files in the directory, wanted 1", len(entries))
+	}
+}
@@ -0,0 +1,71 @@
+package mfd
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Layout helpers for page lines. The text is translated to the characters the display shows before it is
+// measured, so transliterations that expand a character (ß to ss) do not push a line past the display width.
+
+// SpaceBetween lays out the texts on one line of the display, with the remaining space spread between them.
+// The remainder goes to the rightmost gaps. A single text is padded on the right.
+func SpaceBetween(texts ...string) string {
+	return FillBetween(" ", texts...)
+}
+
+// FillBetween is SpaceBetween with fill instead of spaces between the texts
+func FillBetween(fill string, texts ...string) string {
+	translated := make([]string, len(texts))
+	length := 0
+	for i, text := range texts {
+		translated[i] = Translate(text)
+		length += utf8.RuneCountInString(translated[i])
+	}
+	if len(translated) == 0 {
+		return ""
+	}
+	free := displayWidth - length
+	if free <= 0 {
+		return strings.Join(translated, "")
+	}
+	gaps := len(translated) - 1
+	if gaps == 0 {
+		return translated[0] + strings.Repeat(fill, free)
+	}
+	var b strings.Builder
+	for i, text := range translated {
+		b.WriteString(text)
+		if i < gaps {
+			n := free / gaps
+			if i >= gaps-free%gaps {
+				n++
+			}
+			b.WriteString(strings.Repeat(fill, n))
+		}
+	}
+	return b.String()
+}
+
+// Center centers the text on one line of the display
+func Center(text string) string {
+	return FillAround(" ", text)
+}
+
+// FillAround centers the text on one line of the display with fill on both sides
+func FillAround(fill string, text string) string {
+	text = Translate(text)
+	free := displayWidth - utf8.RuneCountInString(text)
+	if free <= 0 {
+		return text
+	}
+	return strings.Repeat(fill, free/2) + text + strings.Repeat(fill, free-free/2)
+}
+
+// fitLine cuts translated text to the characters a line of the display can show
+func fitLine(text string) string {
+	if utf8.RuneCountInString(text) <= displayWidth {
+		return text
+	}
+	return string([]rune(text)[:displayWidth])
+}
@@ -0,0 +1,45 @@
+package mfd
+
+import "testing"
+
+func TestLayout(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"space between", SpaceBetween("Bodies:", "12"), "Bodies:       12"},
+		{"remainder to the right", SpaceBetween("A", "B", "C"), "A      B       C"},
+		{"single text", SpaceBetween("TGT BODY"), "TGT BODY        "},
+		{"too long", SpaceBetween("Shinrarta Dezhra", "A 1"), "Shinrarta DezhraA 1"},
+		// Expanding transliterations are measured after translating
+		{"transliterated", SpaceBetween("Straße", "12"), "Strasse       12"},
+		{"display glyphs", SpaceBetween("Temp", "20°"), "Temp         20°"},
+		{"fill around", FillAround("*", " NO CARGO "), "*** NO CARGO ***"},
+		{"fill around transliterated", FillAround("*", " Œuvre "), "**** OEuvre ****"},
+		{"center", Center("ROUTE"), "     ROUTE      "},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %q, wanted %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestFitLine(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Sol", "Sol"},
+		{"Exactly 16 chars", "Exactly 16 chars"},
+		{"Shinrarta Dezhra A 1", "Shinrarta Dezhra"},
+		{"Großer Bär 2 4 ABC", "Grosser Bär 2 4 "},
+		{"ü°üü°üü°üü°üü°üü°üü°", "ü°üü°üü°üü°üü°üü"},
+	}
+	for _, tt := range tests {
+		if got := fitLine(Translate(tt.in)); got != tt.want {
+			t.Errorf("fitLine(%q): got %q, wanted %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package mfd
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// The number of characters a single line of the display can show
+const displayWidth = 16
+
+const (
+	// DefaultMarqueeRate is the time between marquee steps, if no other rate is configured
+	DefaultMarqueeRate = 400 * time.Millisecond
+	// marqueePause is the number of steps a line rests at its start before scrolling
+	marqueePause = 4
+	// marqueeGap separates the end of a scrolling line from its start as it wraps around
+	marqueeGap = "   "
+)
+
+// MarqueeOptions controls horizontal scrolling of lines that are too long for the display
+type MarqueeOptions struct {
+	Enabled bool
+	// Rate is the time between moving a line by one character
+	Rate time.Duration
+}
+
+// marqueeRow is the scroll state of one line on the display
+type marqueeRow struct {
+	text []rune
+	step int
+}
+
+var (
+	marqueeOptions MarqueeOptions
+	marqueeRows    [displayLines]marqueeRow
+	marqueeWake    = make(chan struct{}, 1)
+	marqueeStop    chan struct{}
+)
+
+// SetMarqueeOptions sets the marquee behaviour. Must be called before InitDevice.
+func SetMarqueeOptions(opts MarqueeOptions) {
+	if opts.Rate <= 0 {
+		opts.Rate = DefaultMarqueeRate
+	}
+	marqueeOptions = opts
+}
+
+// marqueeText records the full text shown on a line of the display and returns the part of it to show now.
+// Without the marquee, text too long for the display is cut at the display width.
+// The caller must hold displayLock.
+func marqueeText(lineIdx uint32, text string) string {
+	if !marqueeOptions.Enabled {
+		return fitLine(text)
+	}
+	row := &marqueeRows[lineIdx]
+	runes := []rune(text)
+	if string(row.text) != text {
+		row.text = runes
+		row.step = 0
+	}
+	if len(runes) > displayWidth {
+		select {
+		case marqueeWake <- struct{}{}:
+		default:
+		}
+	}
+	return row.window()
+}
+
+// window returns the characters of the row visible at the current step
+func (r marqueeRow) window() string {
+	if len(r.text) <= displayWidth {
+		return string(r.text)
+	}
+	loop := append(append(append([]rune{}, r.text...), []rune(marqueeGap)...), r.text...)
+	cycle := len(r.text) + len(marqueeGap) + marqueePause
+	offset := r.step%cycle - marqueePause
+	if offset < 0 {
+		offset = 0
+	}
+	return string(loop[offset : offset+displayWidth])
+}
+
+func (r marqueeRow) overflows() bool {
+	return len(r.text) > displayWidth
+}
+
+func startMarquee() {
+	if !marqueeOptions.Enabled {
+		return
+	}
+	log.Debug().Dur("rate", marqueeOptions.Rate).Msg("Starting marquee")
+	marqueeStop = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(marqueeOptions.Rate)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if !marqueeStep() {
+					// Nothing to scroll, sleep until a long line is shown
+					ticker.Stop()
+					select {
+					case <-marqueeWake:
+						ticker.Reset(marqueeOptions.Rate)
+					case <-marqueeStop:
+						return
+					}
+				}
+			case <-marqueeStop:
+				return
+			}
+		}
+	}()
+}
+
+func stopMarquee() {
+	if marqueeStop != nil {
+		close(marqueeStop)
+		marqueeStop = nil
+	}
+}
+
+// marqueeStep moves all overflowing lines on the display by one character. It returns false if no
+// line is currently overflowing.
+func marqueeStep() bool {
+	displayLock.Lock()
+	defer displayLock.Unlock()
+	if !loaded || device == 0 || !pageActive {
+		return false
+	}
+	scrolling := false
+	for l := range marqueeRows {
+		row := &marqueeRows[l]
+		if !row.overflows() {
+			continue
+		}
+		scrolling = true
+		row.step++
+		setString(currentPage, uint32(l), row.window())
+	}
+	return scrolling
+}
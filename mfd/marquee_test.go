@@ -0,0 +1,62 @@
+package mfd
+
+import "testing"
+
+func TestMarqueeWindow(t *testing.T) {
+	long := "Shinrarta Dezhra A 1"
+	tests := []struct {
+		text string
+		step int
+		want string
+	}{
+		{"Sol", 0, "Sol"},
+		{"Sol", 10, "Sol"},
+		{"Exactly 16 chars", 5, "Exactly 16 chars"},
+		// A long line rests at its start before scrolling
+		{long, 0, "Shinrarta Dezhra"},
+		{long, marqueePause, "Shinrarta Dezhra"},
+		{long, marqueePause + 1, "hinrarta Dezhra "},
+		{long, marqueePause + 10, "Dezhra A 1   Shi"},
+		// and wraps around to its start
+		{long, marqueePause + len(long), "   Shinrarta Dez"},
+		{long, marqueePause + len(long) + len(marqueeGap), "Shinrarta Dezhra"},
+		{long, marqueePause + len(long) + len(marqueeGap) + marqueePause + 1, "hinrarta Dezhra "},
+		{"Čapek Ćity Orbital Station", marqueePause + 2, "pek Ćity Orbital"},
+	}
+	for _, tt := range tests {
+		row := marqueeRow{text: []rune(tt.text), step: tt.step}
+		if got := row.window(); got != tt.want {
+			t.Errorf("window of %q at step %d = %q, want %q", tt.text, tt.step, got, tt.want)
+		}
+	}
+}
+
+func TestMarqueeText(t *testing.T) {
+	defer SetMarqueeOptions(marqueeOptions)
+	SetMarqueeOptions(MarqueeOptions{})
+	long := "Shinrarta Dezhra A 1"
+	if got := marqueeText(0, long); got != "Shinrarta Dezhra" {
+		t.Errorf("marqueeText disabled = %q, want the line cut at the display width", got)
+	}
+
+	SetMarqueeOptions(MarqueeOptions{Enabled: true})
+	marqueeRows[0] = marqueeRow{}
+	if got := marqueeText(0, long); got != "Shinrarta Dezhra" {
+		t.Errorf("marqueeText = %q, want the start of the line", got)
+	}
+	marqueeRows[0].step = marqueePause + 3
+	if got := marqueeText(0, long); got != "nrarta Dezhra A " {
+		t.Errorf("marqueeText of the same line = %q, want the line scrolled on", got)
+	}
+	// Another line starts from its start
+	if got := marqueeText(0, "Jameson Memorial Station"); got != "Jameson Memorial" {
+		t.Errorf("marqueeText of a new line = %q, want its start", got)
+	}
+	marqueeRows[0] = marqueeRow{}
+	// Long lines wake the marquee up
+	select {
+	case <-marqueeWake:
+	default:
+		t.Error("marqueeText did not wake the marquee for a long line")
+	}
+}
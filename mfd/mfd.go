@@ -23,8 +23,20 @@ func (d Display) Copy() Display {
 	return dc
 }
 
+// Translated returns a copy of this Display with all text as the device shows it
+func (d Display) Translated() Display {
+	pc := []Page{}
+	for _, p := range d.Pages {
+		pc = append(pc, p.Translated())
+	}
+	return Display{Pages: pc}
+}
+
 // Page is a single page of information to show on the MFD
 type Page struct {
+	// ID identifies what the page is showing, such as a system, body or station. The scroll position
+	// of a page is kept for as long as its ID stays the same.
+	ID    string   `json:"id,omitempty"`
 	Lines []string `json:"lines"`
 }
 
@@ -42,10 +54,35 @@ func (p *Page) Add(s string, args ...interface{}) {
 func (p Page) Copy() Page {
 	nLines := make([]string, len(p.Lines))
 	copy(nLines, p.Lines)
-	return Page{Lines: nLines}
+	return Page{ID: p.ID, Lines: nLines}
+}
+
+// Translated returns a copy of this Page with all text as the device shows it
+func (p Page) Translated() Page {
+	nLines := make([]string, len(p.Lines))
+	for i, line := range p.Lines {
+		nLines[i] = Translate(line)
+	}
+	return Page{ID: p.ID, Lines: nLines}
 }
 
-// Write writes the MFD file
+// Equal reports whether both pages show the same lines
+func (p Page) Equal(o Page) bool {
+	if p.ID != o.ID || len(p.Lines) != len(o.Lines) {
+		return false
+	}
+	for i := range p.Lines {
+		if p.Lines[i] != o.Lines[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Write shows the display on the device and writes the MFD files, if enabled
 func Write(mfd Display) {
 	UpdateDisplay(mfd)
+	if files != nil {
+		files.submit(mfd)
+	}
 }
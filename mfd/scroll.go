@@ -0,0 +1,116 @@
+package mfd
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultDwell is the time a position is shown when auto scrolling, if no other dwell time is configured
+const DefaultDwell = 3 * time.Second
+
+// ScrollOptions controls how pages longer than the display are scrolled
+type ScrollOptions struct {
+	// ResetOnRefresh scrolls a page back to the top whenever its content changes, not only when it
+	// starts showing something else
+	ResetOnRefresh bool
+	// AutoScroll advances the active page by one line every Dwell, wrapping around at the end
+	AutoScroll bool
+	Dwell      time.Duration
+}
+
+var (
+	scrollOptions  ScrollOptions
+	autoScrollStop chan struct{}
+	autoScrollKick chan struct{}
+)
+
+// SetScrollOptions sets the scrolling behaviour. Must be called before InitDevice.
+func SetScrollOptions(opts ScrollOptions) {
+	if opts.Dwell <= 0 {
+		opts.Dwell = DefaultDwell
+	}
+	scrollOptions = opts
+}
+
+// maxLine returns the largest scroll offset for a page that still fills the display
+func maxLine(page Page) uint32 {
+	if len(page.Lines) <= displayLines {
+		return 0
+	}
+	return uint32(len(page.Lines) - displayLines)
+}
+
+// scrollLine returns the scroll offset to use for a page after it has been replaced by next.
+// The offset is kept as long as the page shows the same thing, otherwise the page starts from the top.
+func scrollLine(prev, next Page, line uint32) uint32 {
+	if prev.ID != next.ID {
+		return 0
+	}
+	if scrollOptions.ResetOnRefresh && !prev.Equal(next) {
+		return 0
+	}
+	return min(line, maxLine(next))
+}
+
+func startAutoScroll() {
+	if !scrollOptions.AutoScroll {
+		return
+	}
+	log.Debug().Dur("dwell", scrollOptions.Dwell).Msg("Starting auto scroll")
+	autoScrollStop = make(chan struct{})
+	autoScrollKick = make(chan struct{}, 1)
+	go func() {
+		timer := time.NewTimer(scrollOptions.Dwell)
+		defer timer.Stop()
+		for {
+			select {
+			case <-timer.C:
+				autoScrollStep()
+				timer.Reset(scrollOptions.Dwell)
+			case <-autoScrollKick:
+				timer.Reset(scrollOptions.Dwell)
+			case <-autoScrollStop:
+				return
+			}
+		}
+	}()
+}
+
+func stopAutoScroll() {
+	if autoScrollStop != nil {
+		close(autoScrollStop)
+		autoScrollStop = nil
+	}
+}
+
+// resetAutoScroll restarts the dwell time, so a manually scrolled position is shown for the full dwell time
+func resetAutoScroll() {
+	if autoScrollKick == nil {
+		return
+	}
+	select {
+	case autoScrollKick <- struct{}{}:
+	default:
+	}
+}
+
+// autoScrollStep advances the active page by a single line, starting over at the top once the end is reached
+func autoScrollStep() {
+	displayLock.Lock()
+	defer displayLock.Unlock()
+	if int(currentPage) >= len(currentDisplay.Pages) {
+		return
+	}
+	page := currentDisplay.Pages[currentPage]
+	last := maxLine(page)
+	if last == 0 {
+		return
+	}
+	line := currentLines[currentPage] + 1
+	if line > last {
+		line = 0
+	}
+	currentLines[currentPage] = line
+	refreshDisplay()
+}
@@ -0,0 +1,55 @@
+package mfd
+
+import "testing"
+
+// lines returns a page with the ID and n lines
+func lines(id string, n int) Page {
+	page := Page{ID: id, Lines: []string{}}
+	for i := range n {
+		page.Add("Line %d", i)
+	}
+	return page
+}
+
+func TestMaxLine(t *testing.T) {
+	tests := []struct {
+		lines int
+		want  uint32
+	}{
+		{0, 0},
+		{1, 0},
+		{displayLines, 0},
+		{displayLines + 1, 1},
+		{10, 10 - displayLines},
+	}
+	for _, tt := range tests {
+		if got := maxLine(lines("", tt.lines)); got != tt.want {
+			t.Errorf("maxLine of %d lines = %d, want %d", tt.lines, got, tt.want)
+		}
+	}
+}
+
+func TestScrollLine(t *testing.T) {
+	tests := []struct {
+		name           string
+		prev, next     Page
+		line           uint32
+		resetOnRefresh bool
+		want           uint32
+	}{
+		{"same page", lines("sol", 10), lines("sol", 10), 4, false, 4},
+		{"other page", lines("sol", 10), lines("achenar", 10), 4, false, 0},
+		{"page got shorter", lines("sol", 10), lines("sol", 5), 4, false, 5 - displayLines},
+		{"page fits the display", lines("sol", 10), lines("sol", displayLines), 4, false, 0},
+		{"same content with reset", lines("sol", 10), lines("sol", 10), 4, true, 4},
+		{"changed content with reset", lines("sol", 10), lines("sol", 11), 4, true, 0},
+		{"changed content without reset", lines("sol", 10), lines("sol", 11), 4, false, 4},
+	}
+	defer SetScrollOptions(scrollOptions)
+	for _, tt := range tests {
+		SetScrollOptions(ScrollOptions{ResetOnRefresh: tt.resetOnRefresh})
+		if got := scrollLine(tt.prev, tt.next, tt.line); got != tt.want {
+			t.Errorf("%s: scrollLine = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
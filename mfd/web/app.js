@@ -0,0 +1,168 @@
+// Virtual MFD. Mirrors the behaviour of the device in mfd/device.go, mfd/scroll.go and mfd/marquee.go.
+"use strict";
+
+const DISPLAY_LINES = 3;
+const DISPLAY_WIDTH = 16;
+const MARQUEE_RATE = 400;
+const MARQUEE_PAUSE = 4;
+const MARQUEE_GAP = "   ";
+
+let display = { pages: [] };
+let pageNames = [];
+let currentPage = 0;
+let currentLines = [];
+let marqueeRows = [];
+
+const rows = document.querySelectorAll("#lcd .row");
+
+function maxLine(page) {
+  return Math.max(0, page.lines.length - DISPLAY_LINES);
+}
+
+// Keep the scroll position while a page shows the same thing, start from the top otherwise
+function updateDisplay(next) {
+  next.pages.forEach((page, i) => {
+    const prev = display.pages[i];
+    if (!prev || prev.id !== page.id) {
+      currentLines[i] = 0;
+    } else {
+      currentLines[i] = Math.min(currentLines[i] || 0, maxLine(page));
+    }
+  });
+  currentLines.length = next.pages.length;
+  display = next;
+  if (currentPage >= display.pages.length) {
+    currentPage = 0;
+  }
+  render();
+}
+
+function marqueeWindow(row) {
+  const chars = Array.from(row.text);
+  if (chars.length <= DISPLAY_WIDTH) {
+    return row.text;
+  }
+  const loop = chars.concat(Array.from(MARQUEE_GAP), chars);
+  const cycle = chars.length + MARQUEE_GAP.length + MARQUEE_PAUSE;
+  const offset = Math.max(0, (row.step % cycle) - MARQUEE_PAUSE);
+  return loop.slice(offset, offset + DISPLAY_WIDTH).join("");
+}
+
+function render() {
+  const page = display.pages[currentPage];
+  document.getElementById("page-name").textContent = page
+    ? `${currentPage + 1}/${display.pages.length} ${pageNames[currentPage] || ""}`
+    : "No pages";
+  const line = page ? Math.min(currentLines[currentPage] || 0, maxLine(page)) : 0;
+  for (let l = 0; l < DISPLAY_LINES; l++) {
+    const text = (page && page.lines[line + l]) || "";
+    const row = marqueeRows[l] || { text: "", step: 0 };
+    if (row.text !== text) {
+      marqueeRows[l] = { text: text, step: 0 };
+    }
+    rows[l].textContent = marqueeWindow(marqueeRows[l]);
+  }
+}
+
+function marqueeStep() {
+  marqueeRows.forEach((row, l) => {
+    if (Array.from(row.text).length > DISPLAY_WIDTH) {
+      row.step++;
+      rows[l].textContent = marqueeWindow(row);
+    }
+  });
+}
+
+function changePage(delta) {
+  if (display.pages.length === 0) {
+    return;
+  }
+  currentPage = (currentPage + delta + display.pages.length) % display.pages.length;
+  render();
+}
+
+function scroll(delta) {
+  const page = display.pages[currentPage];
+  if (!page) {
+    return;
+  }
+  const line = (currentLines[currentPage] || 0) + delta;
+  currentLines[currentPage] = Math.max(0, Math.min(line, maxLine(page)));
+  render();
+}
+
+function select() {
+  fetch(`api/select?page=${currentPage}`, { method: "POST" });
+}
+
+async function refreshPreviews() {
+  const response = await fetch("api/pages?charset=device");
+  const previews = await response.json();
+  pageNames = previews.filter((p) => p.enabled).map((p) => p.displayName);
+
+  const container = document.getElementById("previews");
+  container.replaceChildren(
+    ...previews.map((preview) => {
+      const el = document.createElement("div");
+      el.className = preview.enabled ? "preview" : "preview disabled";
+      const title = document.createElement("h3");
+      title.textContent = preview.displayName;
+      const text = document.createElement("pre");
+      text.textContent = preview.page.lines.join("\n");
+      el.append(title, text);
+      return el;
+    })
+  );
+  render();
+}
+
+function connect() {
+  const status = document.getElementById("connection");
+  const protocol = location.protocol === "https:" ? "wss:" : "ws:";
+  const ws = new WebSocket(`${protocol}//${location.host}/api/ws?charset=device`);
+  ws.onopen = () => {
+    status.textContent = "online";
+    status.className = "online";
+  };
+  ws.onmessage = (event) => {
+    const update = JSON.parse(event.data);
+    updateDisplay(update.display);
+    refreshPreviews();
+  };
+  ws.onclose = () => {
+    status.textContent = "offline";
+    status.className = "offline";
+    setTimeout(connect, 2000);
+  };
+}
+
+document.getElementById("page-prev").onclick = () => changePage(-1);
+document.getElementById("page-next").onclick = () => changePage(1);
+document.getElementById("scroll-up").onclick = () => scroll(-1);
+document.getElementById("scroll-down").onclick = () => scroll(1);
+document.getElementById("select").onclick = select;
+document.addEventListener("keydown", (event) => {
+  switch (event.key) {
+    case "ArrowLeft":
+      changePage(-1);
+      break;
+    case "ArrowRight":
+      changePage(1);
+      break;
+    case "ArrowUp":
+      scroll(-1);
+      break;
+    case "ArrowDown":
+      scroll(1);
+      break;
+    case "Enter":
+      select();
+      break;
+    default:
+      return;
+  }
+  event.preventDefault();
+});
+
+setInterval(marqueeStep, MARQUEE_RATE);
+connect();
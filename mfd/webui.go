@@ -0,0 +1,20 @@
+package mfd
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed web
+var webFiles embed.FS
+
+// WebUI returns a handler serving the browser based virtual MFD. It expects the state stream of the
+// API server at /api/ws next to it.
+func WebUI() http.Handler {
+	sub, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServerFS(sub)
+}
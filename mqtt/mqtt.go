@@ -0,0 +1,208 @@
+package mqtt
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/pellux-network/EDxDC/conf"
+	"github.com/pellux-network/EDxDC/edreader"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+ Module publishing journal events and the derived game state to an MQTT broker.
+
+ Topics, below <prefix>/<commander>:
+   location     current location (retained)
+   destination  FSD target and local destination (retained)
+   cargo        cargo hold (retained)
+   status       Status.json (retained)
+   event/<name> every journal event as written by the game
+ <prefix>/online is "true" while EDxDC is connected, and "false" otherwise (retained).
+*/
+
+const (
+	// DefaultTopicPrefix is the topic prefix used if none is configured
+	DefaultTopicPrefix = "edxdc"
+	// unknownCommander is used in topics until the commander is known from the journal
+	unknownCommander = "unknown"
+
+	connectTimeout = 10 * time.Second
+	publishTimeout = 5 * time.Second
+)
+
+// Destination is the payload published to the destination topic
+type Destination struct {
+	FSDTarget   edreader.EDSMTarget  `json:"fsdTarget"`
+	Destination edreader.Destination `json:"destination"`
+}
+
+// Publisher publishes game events and state to an MQTT broker
+type Publisher struct {
+	client paho.Client
+	prefix string
+	qos    byte
+
+	// retained holds the last payload of every retained topic, to skip unchanged state and to
+	// publish the state again after reconnecting
+	retainedLock sync.Mutex
+	retained     map[string][]byte
+}
+
+// New creates a publisher from the config. It does not connect until Start is called.
+func New(cfg conf.MQTTConf) (*Publisher, error) {
+	p := &Publisher{
+		prefix:   cfg.TopicPrefix,
+		qos:      cfg.QoS,
+		retained: map[string][]byte{},
+	}
+	if p.prefix == "" {
+		p.prefix = DefaultTopicPrefix
+	}
+
+	clientID := cfg.ClientID
+	if clientID == "" {
+		clientID = fmt.Sprintf("edxdc-%d", os.Getpid())
+	}
+	opts := paho.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(clientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetMaxReconnectInterval(time.Minute).
+		SetWill(p.prefix+"/online", "false", p.qos, true).
+		SetOnConnectHandler(p.onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			log.Warn().Err(err).Msg("MQTT connection lost, reconnecting")
+		})
+
+	if cfg.TLS.Enabled {
+		tlsConfig, err := newTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	p.client = paho.NewClient(opts)
+	return p, nil
+}
+
+func newTLSConfig(cfg conf.MQTTTLSConf) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.CAFile != "" {
+		ca, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading MQTT CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in MQTT CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading MQTT client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// Start connects to the broker and starts publishing what the reader reads. The connection is retried
+// in the background if the broker cannot be reached.
+func (p *Publisher) Start(reader *edreader.Reader) {
+	log.Info().Str("prefix", p.prefix).Msg("Starting MQTT publisher")
+	token := p.client.Connect()
+	if !token.WaitTimeout(connectTimeout) {
+		log.Warn().Msg("MQTT broker not reachable yet, retrying in the background")
+	} else if token.Error() != nil {
+		log.Warn().Err(token.Error()).Msg("Failed to connect to MQTT broker")
+	}
+	reader.AddJournalListener(p.PublishEvent)
+	reader.AddStateListener(p.PublishState)
+}
+
+// Close marks EDxDC offline and disconnects from the broker
+func (p *Publisher) Close() {
+	if p.client.IsConnected() {
+		p.client.Publish(p.prefix+"/online", p.qos, true, "false").WaitTimeout(publishTimeout)
+	}
+	p.client.Disconnect(250)
+}
+
+func (p *Publisher) onConnect(client paho.Client) {
+	log.Info().Msg("Connected to MQTT broker")
+	client.Publish(p.prefix+"/online", p.qos, true, "true")
+
+	p.retainedLock.Lock()
+	defer p.retainedLock.Unlock()
+	for topic, payload := range p.retained {
+		client.Publish(topic, p.qos, true, payload)
+	}
+}
+
+// PublishEvent publishes a journal event as written by the game
+func (p *Publisher) PublishEvent(event string, line []byte, state edreader.Journalstate) {
+	p.publish(p.topic(state.Commander, "event/"+event), line, false)
+}
+
+// PublishState publishes the parts of the game state that changed since they were last published
+func (p *Publisher) PublishState(view edreader.View) {
+	cmdr := view.Journal.Commander
+	p.publishJSON(p.topic(cmdr, "location"), view.Journal.Location)
+	p.publishJSON(p.topic(cmdr, "destination"), Destination{
+		FSDTarget:   view.Journal.EDSMTarget,
+		Destination: view.Journal.Destination,
+	})
+	p.publishJSON(p.topic(cmdr, "cargo"), view.Cargo)
+	p.publishJSON(p.topic(cmdr, "status"), view.Status)
+}
+
+func (p *Publisher) publishJSON(topic string, v any) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		log.Error().Err(err).Str("topic", topic).Msg("Failed to marshal MQTT payload")
+		return
+	}
+	p.retainedLock.Lock()
+	unchanged := string(p.retained[topic]) == string(payload)
+	p.retained[topic] = payload
+	p.retainedLock.Unlock()
+	if !unchanged {
+		p.publish(topic, payload, true)
+	}
+}
+
+func (p *Publisher) publish(topic string, payload []byte, retained bool) {
+	if !p.client.IsConnectionOpen() {
+		// Retained state is published again on connect, events are dropped
+		return
+	}
+	token := p.client.Publish(topic, p.qos, retained, payload)
+	go func() {
+		if token.WaitTimeout(publishTimeout) && token.Error() != nil {
+			log.Warn().Err(token.Error()).Str("topic", topic).Msg("Failed to publish MQTT message")
+		}
+	}()
+}
+
+// topic returns the topic for the commander, with characters that are not allowed in topic names replaced
+func (p *Publisher) topic(cmdr, suffix string) string {
+	if cmdr == "" {
+		cmdr = unknownCommander
+	}
+	cmdr = strings.NewReplacer("/", "_", "+", "_", "#", "_").Replace(cmdr)
+	return p.prefix + "/" + cmdr + "/" + suffix
+}
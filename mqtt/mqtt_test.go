@@ -0,0 +1,169 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/pellux-network/EDxDC/conf"
+	"github.com/pellux-network/EDxDC/edreader"
+)
+
+// startBroker starts an embedded broker on a free port and returns its URL
+func startBroker(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	server := mochi.New(nil)
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.AddListener(listeners.NewTCP(listeners.Config{ID: "test", Address: addr})); err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+	t.Cleanup(func() { server.Close() })
+	return "tcp://" + addr
+}
+
+// collector records the messages received by a subscriber, including retained ones
+type collector struct {
+	lock     sync.Mutex
+	messages []paho.Message
+}
+
+func subscribe(t *testing.T, broker, filter string) *collector {
+	t.Helper()
+	c := &collector{}
+	client := paho.NewClient(paho.NewClientOptions().AddBroker(broker).SetClientID("test-subscriber"))
+	if token := client.Connect(); !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+		t.Fatalf("subscriber failed to connect: %v", token.Error())
+	}
+	t.Cleanup(func() { client.Disconnect(0) })
+	token := client.Subscribe(filter, 1, func(_ paho.Client, m paho.Message) {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		c.messages = append(c.messages, m)
+	})
+	if !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+		t.Fatalf("subscribe failed: %v", token.Error())
+	}
+	return c
+}
+
+// receive waits for the n-th message on the topic
+func (c *collector) receive(t *testing.T, topic string, n int) paho.Message {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c.lock.Lock()
+		seen := 0
+		for _, m := range c.messages {
+			if m.Topic() == topic {
+				seen++
+				if seen == n {
+					c.lock.Unlock()
+					return m
+				}
+			}
+		}
+		c.lock.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no message %d on %s", n, topic)
+	return nil
+}
+
+// count returns the number of messages received on the topic
+func (c *collector) count(topic string) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	n := 0
+	for _, m := range c.messages {
+		if m.Topic() == topic {
+			n++
+		}
+	}
+	return n
+}
+
+func TestPublisher(t *testing.T) {
+	broker := startBroker(t)
+	p, err := New(conf.MQTTConf{Broker: broker, ClientID: "edxdc-test", QoS: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := edreader.New(conf.Conf{}, edreader.Options{})
+	defer reader.Close()
+	p.Start(reader)
+	defer p.Close()
+
+	view := edreader.View{}
+	view.Journal.Commander = "Jameson"
+	view.Journal.StarSystem = "Sol"
+	view.Journal.Location.SystemAddress = 10477373803
+	view.Cargo.Count = 3
+	p.PublishState(view)
+
+	// Retained state is delivered to late subscribers
+	messages := subscribe(t, broker, "edxdc/#")
+	m := messages.receive(t, "edxdc/Jameson/location", 1)
+	if !m.Retained() {
+		t.Error("location was not retained")
+	}
+	var location edreader.Location
+	if err := json.Unmarshal(m.Payload(), &location); err != nil || location.StarSystem != "Sol" {
+		t.Errorf("got location %s (%v), wanted Sol", m.Payload(), err)
+	}
+	messages.receive(t, "edxdc/Jameson/cargo", 1)
+	if m := messages.receive(t, "edxdc/online", 1); string(m.Payload()) != "true" {
+		t.Errorf("got online %q, wanted true", m.Payload())
+	}
+
+	line := []byte(`{ "timestamp":"2025-01-01T00:00:00Z", "event":"FSDJump", "StarSystem":"Achenar" }`)
+	p.PublishEvent("FSDJump", line, view.Journal)
+	if m := messages.receive(t, "edxdc/Jameson/event/FSDJump", 1); string(m.Payload()) != string(line) {
+		t.Errorf("got event %s, wanted %s", m.Payload(), line)
+	}
+
+	// Unchanged state is not published again
+	p.PublishState(view)
+	view.Cargo.Count = 4
+	p.PublishState(view)
+	m = messages.receive(t, "edxdc/Jameson/cargo", 2)
+	var cargo edreader.Cargo
+	if err := json.Unmarshal(m.Payload(), &cargo); err != nil || cargo.Count != 4 {
+		t.Errorf("got cargo %s (%v), wanted the updated count", m.Payload(), err)
+	}
+	if n := messages.count("edxdc/Jameson/location"); n != 1 {
+		t.Errorf("got %d location messages, wanted 1", n)
+	}
+}
+
+func TestTopic(t *testing.T) {
+	p := &Publisher{prefix: "edxdc"}
+	tests := []struct {
+		cmdr string
+		want string
+	}{
+		{"Jameson", "edxdc/Jameson/status"},
+		{"", "edxdc/unknown/status"},
+		{"A/B+C#", "edxdc/A_B_C_/status"},
+	}
+	for _, tt := range tests {
+		if got := p.topic(tt.cmdr, "status"); got != tt.want {
+			t.Errorf("topic(%q): got %q, wanted %q", tt.cmdr, got, tt.want)
+		}
+	}
+}
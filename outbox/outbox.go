@@ -0,0 +1,113 @@
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pellux-network/EDxDC/logging"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+ Module providing a persistent queue of messages for uploads to online services, so nothing is lost
+ while the service or the network is unavailable, or when EDxDC is restarted.
+*/
+
+// Queue is a first-in first-out queue of JSON messages, saved to a file on every change
+type Queue struct {
+	path  string
+	limit int
+
+	lock  sync.Mutex
+	items []json.RawMessage
+}
+
+// Open loads the queue from the file, or creates an empty queue if the file does not exist. Once the
+// queue holds limit messages, the oldest ones are dropped. A limit of 0 means no limit.
+func Open(path string, limit int) (*Queue, error) {
+	q := &Queue{path: path, limit: limit}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading outbox: %w", err)
+	}
+	if err := json.Unmarshal(data, &q.items); err != nil {
+		// A corrupt outbox is not worth refusing to start over
+		log.Warn().Err(err).Str("path", logging.CleanPath(path)).Msg("Discarding unreadable outbox")
+		q.items = nil
+	}
+	return q, nil
+}
+
+// Push adds messages to the end of the queue
+func (q *Queue) Push(messages ...any) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for _, m := range messages {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		q.items = append(q.items, data)
+	}
+	if q.limit > 0 && len(q.items) > q.limit {
+		dropped := len(q.items) - q.limit
+		q.items = q.items[dropped:]
+		log.Warn().Int("dropped", dropped).Str("path", logging.CleanPath(q.path)).Msg("Outbox full, dropped oldest messages")
+	}
+	return q.save()
+}
+
+// Peek returns up to n messages from the front of the queue, without removing them
+func (q *Queue) Peek(n int) []json.RawMessage {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	n = min(n, len(q.items))
+	peeked := make([]json.RawMessage, n)
+	copy(peeked, q.items[:n])
+	return peeked
+}
+
+// Drop removes n messages from the front of the queue
+func (q *Queue) Drop(n int) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	n = min(n, len(q.items))
+	q.items = q.items[n:]
+	return q.save()
+}
+
+// Len returns the number of queued messages
+func (q *Queue) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.items)
+}
+
+// save writes the queue to its file. The caller must hold the lock.
+func (q *Queue) save() error {
+	data, err := json.Marshal(q.items)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(q.path), "."+filepath.Base(q.path)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), q.path)
+}
@@ -0,0 +1,83 @@
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQueuePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	q, err := Open(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Push(1, 2, 3, 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Drop(1); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := reopened.Peek(10)
+	want := []string{"3", "4"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, wanted %d", len(got), len(want))
+	}
+	for i := range want {
+		if string(got[i]) != want[i] {
+			t.Errorf("message %d: got %s, wanted %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSenderRetriesAndDrops(t *testing.T) {
+	q, err := Open(filepath.Join(t.TempDir(), "outbox.json"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lock sync.Mutex
+	var sent []string
+	failures := 2
+	s := &Sender{
+		Name:       "test",
+		Queue:      q,
+		MinBackoff: 10 * time.Millisecond,
+		Send: func(messages []json.RawMessage) error {
+			lock.Lock()
+			defer lock.Unlock()
+			if string(messages[0]) == `"rejected"` {
+				return Permanent(errors.New("bad message"))
+			}
+			if failures > 0 {
+				failures--
+				return errors.New("offline")
+			}
+			sent = append(sent, string(messages[0]))
+			return nil
+		},
+	}
+	s.Start()
+	defer s.Close()
+	if err := s.Push("first", "rejected", "last"); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for q.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if len(sent) != 2 || sent[0] != `"first"` || sent[1] != `"last"` {
+		t.Errorf("got %v sent, wanted first and last", sent)
+	}
+}
@@ -0,0 +1,150 @@
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultMinBackoff is the first delay before retrying a failed upload
+	DefaultMinBackoff = 5 * time.Second
+	// DefaultMaxBackoff is the longest delay between retries
+	DefaultMaxBackoff = 10 * time.Minute
+)
+
+// PermanentError marks a failed upload that will never succeed, such as a message rejected by the
+// service. The messages are dropped instead of retried.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent wraps err in a PermanentError
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+// Sender uploads the messages in a queue in the background, retrying with a growing delay while
+// uploads fail
+type Sender struct {
+	// Name identifies the service in log messages
+	Name  string
+	Queue *Queue
+	// BatchSize is the maximum number of messages passed to Send at once
+	BatchSize int
+	// Send uploads the messages, returning a PermanentError if they should be dropped
+	Send       func(messages []json.RawMessage) error
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Linger is the time to wait for more messages after the first one is queued, to send fewer batches
+	Linger time.Duration
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Start starts uploading in the background, beginning with any messages left from a previous run
+func (s *Sender) Start() {
+	if s.BatchSize <= 0 {
+		s.BatchSize = 1
+	}
+	if s.MinBackoff <= 0 {
+		s.MinBackoff = DefaultMinBackoff
+	}
+	if s.MaxBackoff < s.MinBackoff {
+		s.MaxBackoff = max(DefaultMaxBackoff, s.MinBackoff)
+	}
+	s.wake = make(chan struct{}, 1)
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run()
+}
+
+// Push queues messages and wakes the sender
+func (s *Sender) Push(messages ...any) error {
+	if err := s.Queue.Push(messages...); err != nil {
+		return err
+	}
+	s.Kick()
+	return nil
+}
+
+// Kick wakes the sender to upload queued messages without waiting for the retry delay
+func (s *Sender) Kick() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops the sender, waiting for an upload in progress. Queued messages stay in the outbox.
+func (s *Sender) Close() {
+	close(s.stop)
+	<-s.done
+}
+
+func (s *Sender) run() {
+	defer close(s.done)
+	backoff := time.Duration(0)
+	for {
+		if backoff > 0 {
+			select {
+			case <-s.stop:
+				return
+			case <-time.After(backoff):
+			}
+		}
+
+		batch := s.Queue.Peek(s.BatchSize)
+		if len(batch) == 0 {
+			backoff = 0
+			select {
+			case <-s.stop:
+				return
+			case <-s.wake:
+			}
+			if s.Linger > 0 {
+				select {
+				case <-s.stop:
+					return
+				case <-time.After(s.Linger):
+				}
+			}
+			continue
+		}
+
+		err := s.Send(batch)
+		var permanent *PermanentError
+		switch {
+		case err == nil:
+			backoff = 0
+		case errors.As(err, &permanent):
+			log.Warn().Err(err).Str("service", s.Name).Int("messages", len(batch)).Msg("Upload rejected, dropping messages")
+			backoff = 0
+		default:
+			backoff = min(max(2*backoff, s.MinBackoff), s.MaxBackoff)
+			log.Info().Err(err).Str("service", s.Name).Dur("retry", backoff).Int("queued", s.Queue.Len()).Msg("Upload failed, will retry")
+			continue
+		}
+		if err := s.Queue.Drop(len(batch)); err != nil {
+			log.Error().Err(err).Str("service", s.Name).Msg("Failed to save outbox")
+		}
+
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ncruces/zenity"
+	"github.com/pellux-network/EDxDC/edreader"
+	"github.com/pellux-network/EDxDC/spansh"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	plotTimeout = 5 * time.Minute
+
+	plotterNeutron = "Neutron plotter"
+	plotterRiches  = "Road to riches"
+	plotterExact   = "Exact plotter"
+)
+
+var spanshClient = spansh.New()
+
+// plotRoute asks for the route options, and plots the route with Spansh in the background
+func plotRoute(reader *edreader.Reader) {
+	title := zenity.Title("Plot Route")
+	from := reader.CurrentView().Journal.StarSystem
+	if from == "" {
+		_ = zenity.Error("The current system is not known yet.", title)
+		return
+	}
+
+	plotter, err := zenity.List("Plot a route from "+from+" with:", []string{plotterNeutron, plotterRiches, plotterExact},
+		title, zenity.DefaultItems(plotterNeutron))
+	if err != nil {
+		return
+	}
+	destinationText := "Destination system:"
+	if plotter == plotterRiches {
+		destinationText = "Destination system (optional):"
+	}
+	to, err := zenity.Entry(destinationText, title)
+	if err != nil {
+		return
+	}
+	if to == "" && plotter != plotterRiches {
+		_ = zenity.Error("A destination system is needed.", title)
+		return
+	}
+
+	var jumpRange float64
+	if plotter != plotterExact {
+		text, err := zenity.Entry("Jump range in light years:", title)
+		if err != nil {
+			return
+		}
+		if jumpRange, err = strconv.ParseFloat(text, 64); err != nil || jumpRange <= 0 {
+			_ = zenity.Error("The jump range must be a positive number.", title)
+			return
+		}
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), plotTimeout)
+		defer cancel()
+		log.Info().Str("plotter", plotter).Str("from", from).Str("to", to).Msg("Plotting route with Spansh")
+		route, err := plot(ctx, reader, plotter, from, to, jumpRange)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to plot route")
+			_ = zenity.Error("The route could not be plotted:\n\n"+err.Error(), title)
+			return
+		}
+		reader.SetRoute(route)
+		_ = zenity.Notify(fmt.Sprintf("Route plotted with %d waypoints.", len(route.Waypoints)), zenity.Title("EDxDC"))
+	}()
+}
+
+func plot(ctx context.Context, reader *edreader.Reader, plotter, from, to string, jumpRange float64) (spansh.Route, error) {
+	switch plotter {
+	case plotterNeutron:
+		return spanshClient.NeutronRoute(ctx, spansh.NeutronRequest{From: from, To: to, Range: jumpRange, Efficiency: 60})
+	case plotterRiches:
+		return spanshClient.RichesRoute(ctx, spansh.RichesRequest{
+			From:            from,
+			To:              to,
+			Range:           jumpRange,
+			Radius:          25,
+			MaxResults:      100,
+			MaxDistance:     1000000,
+			MinValue:        100000,
+			UseMappingValue: true,
+		})
+	default:
+		loadout := reader.Loadout()
+		if loadout == nil {
+			return spansh.Route{}, errors.New("the ship is not known yet, open the outfitting screen once")
+		}
+		return spanshClient.ExactRoute(ctx, spansh.ExactRequest{From: from, To: to, ShipBuild: loadout, UseSupercharge: true, ExcludeSecondary: true})
+	}
+}
@@ -0,0 +1,232 @@
+package spansh
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/pellux-network/EDxDC/galaxy"
+)
+
+// valuableBodyValue is the mapping value from which a body is listed among the valuable bodies
+const valuableBodyValue = 500000
+
+// Dump is a system as the Spansh dump API returns it
+type Dump struct {
+	ID64      int64         `json:"id64"`
+	Name      string        `json:"name"`
+	BodyCount int           `json:"bodyCount"`
+	Bodies    []DumpBody    `json:"bodies"`
+	Stations  []DumpStation `json:"stations"`
+}
+
+// DumpBody is a body in a system dump
+type DumpBody struct {
+	ID64                  int64              `json:"id64"`
+	BodyID                int64              `json:"bodyId"`
+	Name                  string             `json:"name"`
+	Type                  string             `json:"type"`
+	SubType               string             `json:"subType"`
+	DistanceToArrival     float64            `json:"distanceToArrival"`
+	MainStar              bool               `json:"mainStar"`
+	IsLandable            bool               `json:"isLandable"`
+	Gravity               float64            `json:"gravity"`
+	VolcanismType         string             `json:"volcanismType"`
+	Materials             map[string]float64 `json:"materials"`
+	EstimatedScanValue    int64              `json:"estimatedScanValue"`
+	EstimatedMappingValue int64              `json:"estimatedMappingValue"`
+	Stations              []DumpStation      `json:"stations"`
+}
+
+// DumpStation is a station in a system dump
+type DumpStation struct {
+	ID                int64   `json:"id"`
+	MarketID          int64   `json:"marketId"`
+	Name              string  `json:"name"`
+	Type              string  `json:"type"`
+	DistanceToArrival float64 `json:"distanceToArrival"`
+	Allegiance        string  `json:"allegiance"`
+}
+
+// SystemDump returns everything Spansh knows about the system, or galaxy.ErrNotFound
+func (c *Client) SystemDump(ctx context.Context, id64 int64) (Dump, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/dump/%d", c.BaseURL, id64), nil)
+	if err != nil {
+		return Dump{}, err
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return Dump{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return Dump{}, galaxy.ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Dump{}, fmt.Errorf("Spansh request failed: %s", resp.Status)
+	}
+	var result struct {
+		System Dump `json:"system"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return Dump{}, fmt.Errorf("parsing Spansh system dump: %w", err)
+	}
+	if result.System.ID64 == 0 {
+		return Dump{}, galaxy.ErrNotFound
+	}
+	return result.System, nil
+}
+
+// Provider makes the Spansh system dumps a galaxy provider. Dumps are cached, including the systems
+// Spansh does not know.
+type Provider struct {
+	client *Client
+
+	lock  sync.Mutex
+	dumps map[int64]*Dump
+}
+
+// NewProvider returns a galaxy provider using the client
+func NewProvider(client *Client) *Provider {
+	return &Provider{client: client, dumps: map[int64]*Dump{}}
+}
+
+// Name is "spansh"
+func (p *Provider) Name() string {
+	return "spansh"
+}
+
+// ClearCache forgets the dumps fetched so far
+func (p *Provider) ClearCache() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.dumps = map[int64]*Dump{}
+}
+
+func (p *Provider) dump(id64 int64) (Dump, error) {
+	p.lock.Lock()
+	dump, ok := p.dumps[id64]
+	p.lock.Unlock()
+	if ok {
+		if dump == nil {
+			return Dump{}, galaxy.ErrNotFound
+		}
+		return *dump, nil
+	}
+
+	d, err := p.client.SystemDump(context.Background(), id64)
+	if err != nil && !errors.Is(err, galaxy.ErrNotFound) {
+		return Dump{}, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if err != nil {
+		p.dumps[id64] = nil
+		return Dump{}, err
+	}
+	p.dumps[id64] = &d
+	return d, nil
+}
+
+// Bodies returns the bodies of the system known to Spansh
+func (p *Provider) Bodies(id64 int64) (galaxy.System, error) {
+	d, err := p.dump(id64)
+	if err != nil {
+		return galaxy.System{}, err
+	}
+	return d.System(), nil
+}
+
+// Value adds up the estimated values of the bodies
+func (p *Provider) Value(id64 int64) (galaxy.System, error) {
+	d, err := p.dump(id64)
+	if err != nil {
+		return galaxy.System{}, err
+	}
+	return d.Value(), nil
+}
+
+// Stations returns the stations in space and on the bodies of the system
+func (p *Provider) Stations(id64 int64) ([]galaxy.Station, error) {
+	d, err := p.dump(id64)
+	if err != nil {
+		return nil, err
+	}
+	stations := d.StationList()
+	if len(stations) == 0 {
+		return nil, galaxy.ErrNotFound
+	}
+	return stations, nil
+}
+
+// System returns the system with its bodies
+func (d Dump) System() galaxy.System {
+	sys := galaxy.System{ID64: uint64(d.ID64), Name: d.Name, BodyCount: d.BodyCount}
+	for _, b := range d.Bodies {
+		body := galaxy.Body{
+			ID64:       uint64(b.ID64),
+			BodyID:     b.BodyID,
+			Name:       b.Name,
+			IsMainStar: b.MainStar,
+			Type:       b.Type,
+			SubType:    b.SubType,
+			Gravity:    b.Gravity,
+			Volcanism:  b.VolcanismType,
+			IsLandable: b.IsLandable,
+			Materials:  b.Materials,
+		}
+		if b.Type == "Star" {
+			body.IsScoopable = galaxy.IsScoopable(b.SubType)
+		}
+		sys.Bodies = append(sys.Bodies, body)
+	}
+	return sys
+}
+
+// Value returns the system with the estimated values of its bodies added up
+func (d Dump) Value() galaxy.System {
+	sys := galaxy.System{ID64: uint64(d.ID64), Name: d.Name, BodyCount: d.BodyCount}
+	for _, b := range d.Bodies {
+		mapped := max(b.EstimatedMappingValue, b.EstimatedScanValue)
+		sys.EstimatedValue += b.EstimatedScanValue
+		sys.EstimatedValueMapped += mapped
+		if mapped >= valuableBodyValue {
+			sys.ValuableBodies = append(sys.ValuableBodies, galaxy.ValuableBody{
+				BodyName: b.Name,
+				Distance: b.DistanceToArrival,
+				ValueMax: mapped,
+			})
+		}
+	}
+	sort.SliceStable(sys.ValuableBodies, func(i, j int) bool {
+		return sys.ValuableBodies[i].ValueMax > sys.ValuableBodies[j].ValueMax
+	})
+	return sys
+}
+
+// StationList returns the stations in space and on the bodies of the system
+func (d Dump) StationList() []galaxy.Station {
+	dumped := append([]DumpStation{}, d.Stations...)
+	for _, b := range d.Bodies {
+		dumped = append(dumped, b.Stations...)
+	}
+	stations := make([]galaxy.Station, 0, len(dumped))
+	for _, st := range dumped {
+		id := st.ID
+		if id == 0 {
+			id = st.MarketID
+		}
+		stations = append(stations, galaxy.Station{
+			ID:                id,
+			Name:              st.Name,
+			Type:              st.Type,
+			DistanceToArrival: st.DistanceToArrival,
+			Allegiance:        st.Allegiance,
+		})
+	}
+	return stations
+}
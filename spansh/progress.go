@@ -0,0 +1,76 @@
+package spansh
+
+import "strings"
+
+// Progress tracks the player along a route
+type Progress struct {
+	Route Route `json:"route"`
+	// Next is the index of the waypoint to fly to next
+	Next int `json:"next"`
+	// LegJumps is the number of jumps made since the last waypoint was reached
+	LegJumps int `json:"legJumps"`
+}
+
+// NewProgress starts following the route from the current system
+func NewProgress(route Route, system string, id64 int64) *Progress {
+	p := &Progress{Route: route}
+	if i := p.find(system, id64, 0); i >= 0 {
+		p.Next = i + 1
+	}
+	return p
+}
+
+// Jumped moves along the route after an FSD jump. Waypoints that were skipped are passed, jumps to
+// systems off the route count towards the current leg.
+func (p *Progress) Jumped(system string, id64 int64) {
+	if p.Done() {
+		return
+	}
+	if i := p.find(system, id64, p.Next); i >= 0 {
+		p.Next = i + 1
+		p.LegJumps = 0
+		return
+	}
+	p.LegJumps++
+}
+
+// Done tells if the last waypoint was reached
+func (p *Progress) Done() bool {
+	return p.Next >= len(p.Route.Waypoints)
+}
+
+// NextWaypoint returns the waypoint to fly to next, if the route is not done yet
+func (p *Progress) NextWaypoint() (Waypoint, bool) {
+	if p.Done() {
+		return Waypoint{}, false
+	}
+	return p.Route.Waypoints[p.Next], true
+}
+
+// JumpsLeft estimates the number of jumps to the end of the route
+func (p *Progress) JumpsLeft() int {
+	jumps := 0
+	for _, w := range p.Route.Waypoints[min(p.Next, len(p.Route.Waypoints)):] {
+		jumps += max(w.Jumps, 1)
+	}
+	return max(jumps-p.LegJumps, 0)
+}
+
+// DistanceLeft returns the distance from the last waypoint reached to the end of the route
+func (p *Progress) DistanceLeft() float64 {
+	if p.Done() || len(p.Route.Waypoints) == 0 {
+		return 0
+	}
+	return p.Route.Waypoints[max(p.Next-1, 0)].DistanceLeft
+}
+
+// find returns the index of the system on the route, starting at from, or -1
+func (p *Progress) find(system string, id64 int64, from int) int {
+	for i := from; i < len(p.Route.Waypoints); i++ {
+		w := p.Route.Waypoints[i]
+		if (id64 != 0 && w.ID64 == id64) || (system != "" && strings.EqualFold(w.System, system)) {
+			return i
+		}
+	}
+	return -1
+}
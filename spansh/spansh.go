@@ -0,0 +1,351 @@
+package spansh
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ Module plotting routes with the plotters of spansh.co.uk. A route request starts a job on the Spansh
+ server, which is polled until the route is ready. The system dumps of Spansh also provide galaxy
+ information for the pages.
+*/
+
+const (
+	// DefaultURL is the base URL of the Spansh API
+	DefaultURL = "https://spansh.co.uk/api"
+	// DefaultPollInterval is the time between checks whether a route is ready
+	DefaultPollInterval = 2 * time.Second
+
+	requestTimeout = 30 * time.Second
+
+	statusQueued = "queued"
+	statusOK     = "ok"
+)
+
+// RouteKind is the plotter a route was made with
+type RouteKind string
+
+const (
+	// KindNeutron routes use neutron stars to supercharge the FSD
+	KindNeutron RouteKind = "neutron"
+	// KindRiches routes visit systems with valuable bodies to scan ("road to riches")
+	KindRiches RouteKind = "riches"
+	// KindExact routes are plotted for the exact ship build, including fuel use
+	KindExact RouteKind = "exact"
+)
+
+// Waypoint is a system on a route
+type Waypoint struct {
+	System string `json:"system"`
+	ID64   int64  `json:"id64,omitempty"`
+	// Jumps is the number of jumps from the previous waypoint
+	Jumps int `json:"jumps"`
+	// DistanceLeft is the distance from this waypoint to the end of the route in light years
+	DistanceLeft float64 `json:"distanceLeft"`
+	Neutron      bool    `json:"neutron,omitempty"`
+	// Bodies lists the bodies worth scanning on road to riches routes
+	Bodies []string `json:"bodies,omitempty"`
+}
+
+// Route is a plotted route, starting with the system it was plotted from
+type Route struct {
+	Kind      RouteKind  `json:"kind"`
+	From      string     `json:"from"`
+	To        string     `json:"to"`
+	Waypoints []Waypoint `json:"waypoints"`
+}
+
+// NeutronRequest are the options of the neutron plotter
+type NeutronRequest struct {
+	From  string
+	To    string
+	Range float64
+	// Efficiency is how far the route may deviate from a straight line to use neutron stars, in percent
+	Efficiency int
+}
+
+// RichesRequest are the options of the road to riches plotter
+type RichesRequest struct {
+	From string
+	// To is optional, without it the route goes wherever the valuable systems are
+	To    string
+	Range float64
+	// Radius is the distance from the direct line that systems may be in
+	Radius          float64
+	MaxResults      int
+	MaxDistance     int
+	MinValue        int
+	UseMappingValue bool
+	Loop            bool
+}
+
+// ExactRequest are the options of the exact plotter. The ship is described by either ShipBuild, the
+// Loadout event of the journal, or the FSD and fuel values.
+type ExactRequest struct {
+	From      string
+	To        string
+	ShipBuild json.RawMessage
+
+	IsSupercharged   bool
+	UseSupercharge   bool
+	UseInjections    bool
+	ExcludeSecondary bool
+	FuelPower        float64
+	FuelMultiplier   float64
+	OptimalMass      float64
+	BaseMass         float64
+	TankSize         float64
+	InternalTankSize float64
+	MaxFuelPerJump   float64
+	RangeBoost       float64
+}
+
+// Client talks to the Spansh API
+type Client struct {
+	BaseURL      string
+	HTTP         *http.Client
+	PollInterval time.Duration
+}
+
+// New returns a client for the Spansh site
+func New() *Client {
+	return &Client{
+		BaseURL:      DefaultURL,
+		HTTP:         &http.Client{Timeout: requestTimeout},
+		PollInterval: DefaultPollInterval,
+	}
+}
+
+// jobResponse is the response to starting a job and to polling it
+type jobResponse struct {
+	Job    string          `json:"job"`
+	Status string          `json:"status"`
+	Error  string          `json:"error"`
+	Result json.RawMessage `json:"result"`
+}
+
+// NeutronRoute plots a route using neutron stars
+func (c *Client) NeutronRoute(ctx context.Context, r NeutronRequest) (Route, error) {
+	form := url.Values{
+		"from":       {r.From},
+		"to":         {r.To},
+		"range":      {formatFloat(r.Range)},
+		"efficiency": {strconv.Itoa(r.Efficiency)},
+	}
+	var result struct {
+		SystemJumps []struct {
+			System       string  `json:"system"`
+			ID64         int64   `json:"id64"`
+			Jumps        int     `json:"jumps"`
+			DistanceLeft float64 `json:"distance_left"`
+			NeutronStar  bool    `json:"neutron_star"`
+		} `json:"system_jumps"`
+	}
+	if err := c.plot(ctx, "/route", form, &result); err != nil {
+		return Route{}, err
+	}
+
+	route := Route{Kind: KindNeutron, From: r.From, To: r.To}
+	for _, j := range result.SystemJumps {
+		route.Waypoints = append(route.Waypoints, Waypoint{
+			System:       j.System,
+			ID64:         j.ID64,
+			Jumps:        j.Jumps,
+			DistanceLeft: j.DistanceLeft,
+			Neutron:      j.NeutronStar,
+		})
+	}
+	return route, validate(route)
+}
+
+// RichesRoute plots a route along systems with valuable bodies to scan
+func (c *Client) RichesRoute(ctx context.Context, r RichesRequest) (Route, error) {
+	form := url.Values{
+		"from":              {r.From},
+		"range":             {formatFloat(r.Range)},
+		"radius":            {formatFloat(r.Radius)},
+		"max_results":       {strconv.Itoa(r.MaxResults)},
+		"max_distance":      {strconv.Itoa(r.MaxDistance)},
+		"min_value":         {strconv.Itoa(r.MinValue)},
+		"use_mapping_value": {strconv.Itoa(boolInt(r.UseMappingValue))},
+		"loop":              {strconv.Itoa(boolInt(r.Loop))},
+	}
+	if r.To != "" {
+		form.Set("to", r.To)
+	}
+	var result []struct {
+		Name   string  `json:"name"`
+		ID64   int64   `json:"id64"`
+		Jumps  int     `json:"jumps"`
+		X      float64 `json:"x"`
+		Y      float64 `json:"y"`
+		Z      float64 `json:"z"`
+		Bodies []struct {
+			Name string `json:"name"`
+		} `json:"bodies"`
+	}
+	if err := c.plot(ctx, "/riches/route", form, &result); err != nil {
+		return Route{}, err
+	}
+
+	route := Route{Kind: KindRiches, From: r.From, To: r.To}
+	for _, s := range result {
+		w := Waypoint{System: s.Name, ID64: s.ID64, Jumps: s.Jumps}
+		for _, b := range s.Bodies {
+			w.Bodies = append(w.Bodies, b.Name)
+		}
+		route.Waypoints = append(route.Waypoints, w)
+	}
+	// The riches plotter does not tell the distance left, so add up the straight lines between systems
+	for i := len(result) - 2; i >= 0; i-- {
+		a, b := result[i], result[i+1]
+		leg := math.Sqrt((a.X-b.X)*(a.X-b.X) + (a.Y-b.Y)*(a.Y-b.Y) + (a.Z-b.Z)*(a.Z-b.Z))
+		route.Waypoints[i].DistanceLeft = route.Waypoints[i+1].DistanceLeft + leg
+	}
+	return route, validate(route)
+}
+
+// ExactRoute plots a route for the exact ship build
+func (c *Client) ExactRoute(ctx context.Context, r ExactRequest) (Route, error) {
+	form := url.Values{
+		"source":            {r.From},
+		"destination":       {r.To},
+		"is_supercharged":   {strconv.Itoa(boolInt(r.IsSupercharged))},
+		"use_supercharge":   {strconv.Itoa(boolInt(r.UseSupercharge))},
+		"use_injections":    {strconv.Itoa(boolInt(r.UseInjections))},
+		"exclude_secondary": {strconv.Itoa(boolInt(r.ExcludeSecondary))},
+	}
+	if len(r.ShipBuild) > 0 {
+		// Spansh takes the FSD and fuel values from the build, unset values would override them
+		form.Set("ship_build", string(r.ShipBuild))
+	} else {
+		form.Set("fuel_power", formatFloat(r.FuelPower))
+		form.Set("fuel_multiplier", formatFloat(r.FuelMultiplier))
+		form.Set("optimal_mass", formatFloat(r.OptimalMass))
+		form.Set("base_mass", formatFloat(r.BaseMass))
+		form.Set("tank_size", formatFloat(r.TankSize))
+		form.Set("internal_tank_size", formatFloat(r.InternalTankSize))
+		form.Set("max_fuel_per_jump", formatFloat(r.MaxFuelPerJump))
+		form.Set("range_boost", formatFloat(r.RangeBoost))
+	}
+	var result struct {
+		Jumps []struct {
+			Name                  string  `json:"name"`
+			ID64                  int64   `json:"id64"`
+			DistanceToDestination float64 `json:"distance_to_destination"`
+			HasNeutron            bool    `json:"has_neutron"`
+		} `json:"jumps"`
+	}
+	if err := c.plot(ctx, "/generic/route", form, &result); err != nil {
+		return Route{}, err
+	}
+
+	route := Route{Kind: KindExact, From: r.From, To: r.To}
+	for i, j := range result.Jumps {
+		route.Waypoints = append(route.Waypoints, Waypoint{
+			System:       j.Name,
+			ID64:         j.ID64,
+			Jumps:        min(i, 1),
+			DistanceLeft: j.DistanceToDestination,
+			Neutron:      j.HasNeutron,
+		})
+	}
+	return route, validate(route)
+}
+
+// plot starts a job and waits for its result
+func (c *Client) plot(ctx context.Context, path string, form url.Values, result any) error {
+	var job jobResponse
+	if err := c.do(ctx, http.MethodPost, path, form, &job); err != nil {
+		return err
+	}
+	if job.Job == "" {
+		return errors.New("Spansh did not start a job")
+	}
+
+	for {
+		switch job.Status {
+		case statusOK:
+			if err := json.Unmarshal(job.Result, result); err != nil {
+				return fmt.Errorf("parsing Spansh route: %w", err)
+			}
+			return nil
+		case statusQueued, "":
+		default:
+			return fmt.Errorf("Spansh job %s: %s", job.Status, job.Error)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.PollInterval):
+		}
+		id := job.Job
+		if err := c.do(ctx, http.MethodGet, "/results/"+url.PathEscape(id), nil, &job); err != nil {
+			return err
+		}
+		job.Job = id
+	}
+}
+
+// do sends a request and decodes the JSON response, including the error responses of the API
+func (c *Client) do(ctx context.Context, method, path string, form url.Values, response *jobResponse) error {
+	var body *strings.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	} else {
+		body = strings.NewReader("")
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	*response = jobResponse{}
+	decodeErr := json.NewDecoder(resp.Body).Decode(response)
+	if response.Error != "" {
+		return fmt.Errorf("Spansh: %s", response.Error)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("Spansh request failed: %s", resp.Status)
+	}
+	if decodeErr != nil {
+		return fmt.Errorf("parsing Spansh response: %w", decodeErr)
+	}
+	return nil
+}
+
+func validate(route Route) error {
+	if len(route.Waypoints) == 0 {
+		return errors.New("Spansh found no route")
+	}
+	return nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
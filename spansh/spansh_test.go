@@ -0,0 +1,256 @@
+package spansh
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pellux-network/EDxDC/galaxy"
+)
+
+// fakeSpansh answers route requests with a job that is queued for the first polls
+type fakeSpansh struct {
+	*httptest.Server
+
+	lock    sync.Mutex
+	polls   int
+	form    map[string]string
+	results map[string]string
+}
+
+func newFakeSpansh(t *testing.T, queuedPolls int) *fakeSpansh {
+	f := &fakeSpansh{form: map[string]string{}, results: map[string]string{
+		"/route": `{"system_jumps":[
+			{"system":"Sol","id64":10477373803,"jumps":0,"distance_left":22000,"neutron_star":false},
+			{"system":"Neutron A","id64":1,"jumps":4,"distance_left":21800,"neutron_star":true},
+			{"system":"Colonia","id64":2,"jumps":3,"distance_left":0,"neutron_star":false}]}`,
+		"/riches/route": `[
+			{"name":"Sol","id64":10477373803,"jumps":0,"x":0,"y":0,"z":0,"bodies":[]},
+			{"name":"Alpha","id64":1,"jumps":1,"x":3,"y":4,"z":0,"bodies":[{"name":"Alpha 1"},{"name":"Alpha 2 a"}]},
+			{"name":"Beta","id64":2,"jumps":2,"x":3,"y":4,"z":12,"bodies":[{"name":"Beta A 3"}]}]`,
+		"/generic/route": `{"jumps":[
+			{"name":"Sol","id64":10477373803,"distance_to_destination":10},
+			{"name":"Alpha Centauri","id64":3,"distance_to_destination":0}]}`,
+	}}
+	var job string
+	mux := http.NewServeMux()
+	for path := range f.results {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			r.ParseForm()
+			f.lock.Lock()
+			for key := range r.PostForm {
+				f.form[key] = r.PostForm.Get(key)
+			}
+			if r.PostForm.Get("from") == "Nowhere" || r.PostForm.Get("source") == "Nowhere" {
+				f.lock.Unlock()
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"Could not find starting system"}`))
+				return
+			}
+			job = path
+			f.lock.Unlock()
+			w.Write([]byte(`{"job":"F2B5-1","status":"queued"}`))
+		})
+	}
+	mux.HandleFunc("/results/F2B5-1", func(w http.ResponseWriter, r *http.Request) {
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		f.polls++
+		if f.polls <= queuedPolls {
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"job":"F2B5-1","status":"queued"}`))
+			return
+		}
+		w.Write([]byte(`{"job":"F2B5-1","status":"ok","result":` + f.results[job] + `}`))
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func newTestClient(f *fakeSpansh) *Client {
+	c := New()
+	c.BaseURL = f.URL
+	c.PollInterval = 10 * time.Millisecond
+	return c
+}
+
+func TestNeutronRoute(t *testing.T) {
+	f := newFakeSpansh(t, 2)
+	route, err := newTestClient(f).NeutronRoute(context.Background(), NeutronRequest{From: "Sol", To: "Colonia", Range: 52.5, Efficiency: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.polls != 3 {
+		t.Errorf("got %d polls, wanted 3", f.polls)
+	}
+	if f.form["range"] != "52.5" || f.form["efficiency"] != "60" || f.form["to"] != "Colonia" {
+		t.Errorf("got form %v", f.form)
+	}
+	if route.Kind != KindNeutron || len(route.Waypoints) != 3 {
+		t.Fatalf("got %+v", route)
+	}
+	if w := route.Waypoints[1]; w.System != "Neutron A" || !w.Neutron || w.Jumps != 4 || w.DistanceLeft != 21800 {
+		t.Errorf("got waypoint %+v", w)
+	}
+}
+
+func TestRichesRoute(t *testing.T) {
+	f := newFakeSpansh(t, 0)
+	route, err := newTestClient(f).RichesRoute(context.Background(), RichesRequest{From: "Sol", Range: 30, Radius: 25, MaxResults: 10, UseMappingValue: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.form["to"]; ok {
+		t.Error("sent an empty destination")
+	}
+	if f.form["use_mapping_value"] != "1" || f.form["loop"] != "0" {
+		t.Errorf("got form %v", f.form)
+	}
+	// 5 LY from Sol to Alpha, 12 LY from Alpha to Beta
+	if got := route.Waypoints[0].DistanceLeft; got != 17 {
+		t.Errorf("got distance left %v, wanted 17", got)
+	}
+	if got := route.Waypoints[1].Bodies; len(got) != 2 || got[1] != "Alpha 2 a" {
+		t.Errorf("got bodies %v", got)
+	}
+}
+
+func TestExactRoute(t *testing.T) {
+	f := newFakeSpansh(t, 1)
+	loadout := `{"event":"Loadout","Ship":"cobramkiii"}`
+	route, err := newTestClient(f).ExactRoute(context.Background(), ExactRequest{From: "Sol", To: "Alpha Centauri", ShipBuild: []byte(loadout)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.form["source"] != "Sol" || f.form["ship_build"] != loadout {
+		t.Errorf("got form %v", f.form)
+	}
+	if _, ok := f.form["fuel_power"]; ok {
+		t.Errorf("sent the fuel values with the ship build: %v", f.form)
+	}
+	if len(route.Waypoints) != 2 || route.Waypoints[0].Jumps != 0 || route.Waypoints[1].Jumps != 1 {
+		t.Errorf("got %+v", route)
+	}
+}
+
+func TestExactRouteFuelValues(t *testing.T) {
+	f := newFakeSpansh(t, 0)
+	_, err := newTestClient(f).ExactRoute(context.Background(), ExactRequest{From: "Sol", To: "Alpha Centauri", FuelPower: 2.45, OptimalMass: 1800, TankSize: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.form["fuel_power"] != "2.45" || f.form["optimal_mass"] != "1800" || f.form["tank_size"] != "32" {
+		t.Errorf("got form %v", f.form)
+	}
+	if _, ok := f.form["ship_build"]; ok {
+		t.Errorf("sent a ship build: %v", f.form)
+	}
+}
+
+func TestRouteError(t *testing.T) {
+	f := newFakeSpansh(t, 0)
+	_, err := newTestClient(f).NeutronRoute(context.Background(), NeutronRequest{From: "Nowhere", To: "Colonia", Range: 50})
+	if err == nil || err.Error() != "Spansh: Could not find starting system" {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestRouteCancelled(t *testing.T) {
+	f := newFakeSpansh(t, 1000)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := newTestClient(f).NeutronRoute(ctx, NeutronRequest{From: "Sol", To: "Colonia", Range: 50}); err == nil {
+		t.Error("got a route from a job that never finished")
+	}
+}
+
+func TestProgress(t *testing.T) {
+	route := Route{Kind: KindNeutron, To: "Colonia", Waypoints: []Waypoint{
+		{System: "Sol", ID64: 10, Jumps: 0, DistanceLeft: 100},
+		{System: "Neutron A", ID64: 11, Jumps: 4, DistanceLeft: 60},
+		{System: "Neutron B", ID64: 12, Jumps: 3, DistanceLeft: 20},
+		{System: "Colonia", ID64: 13, Jumps: 2, DistanceLeft: 0},
+	}}
+	p := NewProgress(route, "sol", 0)
+	if p.Next != 1 || p.JumpsLeft() != 9 || p.DistanceLeft() != 100 {
+		t.Fatalf("got next %d, %d jumps and %v LY left", p.Next, p.JumpsLeft(), p.DistanceLeft())
+	}
+
+	p.Jumped("Somewhere", 99)
+	if p.Next != 1 || p.JumpsLeft() != 8 {
+		t.Errorf("after a jump off the route: got next %d and %d jumps left", p.Next, p.JumpsLeft())
+	}
+
+	// Reaching a later waypoint skips the ones in between
+	p.Jumped("", 12)
+	if p.Next != 3 || p.JumpsLeft() != 2 || p.DistanceLeft() != 20 {
+		t.Errorf("after skipping a waypoint: got next %d, %d jumps and %v LY left", p.Next, p.JumpsLeft(), p.DistanceLeft())
+	}
+	if w, _ := p.NextWaypoint(); w.System != "Colonia" {
+		t.Errorf("got next waypoint %s", w.System)
+	}
+
+	p.Jumped("Colonia", 13)
+	if !p.Done() || p.JumpsLeft() != 0 {
+		t.Error("route not done after reaching the last waypoint")
+	}
+}
+
+func TestDumpProvider(t *testing.T) {
+	requests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/dump/7", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"system":{"id64":7,"name":"Gamma","bodyCount":2,
+			"bodies":[
+				{"id64":7,"bodyId":0,"name":"Gamma","type":"Star","subType":"F (White) Star","mainStar":true,"estimatedScanValue":1200},
+				{"id64":36028797018963975,"bodyId":1,"name":"Gamma 1","type":"Planet","subType":"Earth-like world","distanceToArrival":420,
+					"estimatedScanValue":270000,"estimatedMappingValue":1100000,"stations":[{"name":"Surface Port","type":"Planetary Port","marketId":5}]}],
+			"stations":[{"name":"Orbital","id":4,"type":"Coriolis Starport","allegiance":"Federation"}]}}`))
+	})
+	mux.HandleFunc("/dump/8", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"System not found"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := New()
+	client.BaseURL = server.URL
+	p := NewProvider(client)
+
+	sys, err := p.Bodies(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if star := sys.MainStar(); star.Name != "Gamma" || !star.IsScoopable || len(sys.Bodies) != 2 {
+		t.Errorf("got %+v", sys)
+	}
+	value, err := p.Value(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.EstimatedValue != 271200 || value.EstimatedValueMapped != 1101200 || len(value.ValuableBodies) != 1 {
+		t.Errorf("got value %+v", value)
+	}
+	stations, err := p.Stations(7)
+	if err != nil || len(stations) != 2 || stations[1].ID != 5 || stations[0].Allegiance != "Federation" {
+		t.Errorf("got stations %+v, %v", stations, err)
+	}
+	if _, err := p.Bodies(8); !errors.Is(err, galaxy.ErrNotFound) {
+		t.Errorf("got error %v for an unknown system", err)
+	}
+	p.Bodies(8)
+	if requests != 2 {
+		t.Errorf("got %d requests, wanted the dumps to be cached", requests)
+	}
+}